@@ -21,6 +21,10 @@ var (
 		"http://deb.debian.org/debian",
 		"URL to the debian mirror to use")
 
+	dist = flag.String("dist",
+		"sid",
+		"Debian distribution whose Sources and Packages files to compute the ranking from")
+
 	verbose = flag.Bool("verbose",
 		false,
 		"Print ranking information about every package")
@@ -31,7 +35,7 @@ var (
 )
 
 func mustLoadMirroredControlFile(name string) []godebiancontrol.Paragraph {
-	url := fmt.Sprintf("%s/dists/sid/main/%s", *mirrorUrl, name)
+	url := fmt.Sprintf("%s/dists/%s/main/%s", *mirrorUrl, *dist, name)
 	resp, err := http.Get(url)
 	if err != nil {
 		log.Fatal(err)
@@ -4,8 +4,9 @@
 // Notifications about new packages can be delivered via the /lookfor endpoint
 // on demand (e.g. by dcs-tail-fedmsg).
 //
-// Additionally, every hour, the “Sources” file will be downloaded and its
-// contents are compared to the contents of our index/source backends.
+// Additionally, every hour, the “Sources” files of all suites (see -dist) will
+// be downloaded and their contents are compared to the contents of our
+// index/source backends.
 package main
 
 import (
@@ -13,6 +14,7 @@ import (
 	"compress/gzip"
 	"context"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"math"
@@ -65,7 +67,7 @@ var (
 
 	dist = flag.String("dist",
 		"sid",
-		"Debian distribution to feed")
+		"comma-separated list of Debian distributions (suites) to feed, e.g. sid,testing,stable,stable-backports")
 
 	tlsCertPath = flag.String("tls_cert_path", "", "Path to a .pem file containing the TLS certificate.")
 
@@ -75,6 +77,10 @@ var (
 
 	dryRun = flag.Bool("dry_run", false, "Only print changes")
 
+	incrementalMerge = flag.Bool("incremental_merge", false, "Whether to merge new packages into a delta index instead of re-creating the full index on every merge. Full merges then happen every -full_merge_interval.")
+
+	fullMergeInterval = flag.Duration("full_merge_interval", 24*time.Hour, "With -incremental_merge, how often to re-create the full index (dropping the delta index)")
+
 	refeed = flag.String("refeed", "", "If non-empty, all packages will be imported again and the string determines the path to the state file that keeps track of which packages were fed")
 
 	packageImporters []*packageImporter
@@ -82,6 +88,9 @@ var (
 	mergeStates   = make(map[int]mergeState)
 	mergeStatesMu sync.Mutex
 
+	// lastFullMerge is only accessed by merge().
+	lastFullMerge = make(map[int]time.Time)
+
 	failedLookfor = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "lookfor_failed",
@@ -136,10 +145,17 @@ func merge() {
 				if *dryRun {
 					continue
 				}
-				if _, err := importer.Merge(context.Background(), &packageimporterpb.MergeRequest{}); err != nil {
+				incremental := *incrementalMerge &&
+					time.Since(lastFullMerge[shardIdx]) < *fullMergeInterval
+				if _, err := importer.Merge(context.Background(), &packageimporterpb.MergeRequest{
+					Incremental: incremental,
+				}); err != nil {
 					log.Printf("/merge for shard %s failed (retry in 10s): %v\n", importer.shard, err)
 					continue
 				}
+				if !incremental {
+					lastFullMerge[shardIdx] = time.Now()
+				}
 				delete(mergeStates, shardIdx)
 			}
 		}
@@ -344,50 +360,20 @@ func checkSources() {
 		log.Printf("shard %q has %d packages currently\n", importer.shard, len(resp.SourcePackage))
 	}
 
-	var sourcePackages []godebiancontrol.Paragraph
-	for _, section := range []string{"main", "contrib"} {
-		sourcesSuffix := "/dists/" + *dist + "/" + section + "/source/Sources.gz"
-		resp, err := http.Get(*mirrorUrl + sourcesSuffix)
-		if err != nil {
-			log.Printf("Could not get Sources.gz: %v\n", err)
-			return
-		}
-		defer resp.Body.Close()
-		reader, err := gzip.NewReader(resp.Body)
+	// For every source package (e.g. i3-wm_4.13-1) which is part of at least
+	// one suite, the suites which contain it:
+	suites := make(map[string][]string)
+	mostRecent := make(map[string]godebiancontrol.Paragraph)
+	for _, suite := range strings.Split(*dist, ",") {
+		suitePackages, err := mostRecentPackages(suite)
 		if err != nil {
-			log.Printf("Could not initialize gzip reader: %v\n", err)
+			log.Print(err)
 			return
 		}
-		defer reader.Close()
-
-		tmp, err := godebiancontrol.Parse(reader)
-		if err != nil {
-			log.Printf("Could not parse Sources.gz: %v\n", err)
-			return
-		}
-		sourcePackages = append(sourcePackages, tmp...)
-	}
-
-	// Only keep the most recent version for each source package:
-	mostRecent := make(map[string]godebiancontrol.Paragraph)
-	for _, pkg := range sourcePackages {
-		n := pkg["Package"]
-		if current, ok := mostRecent[n]; ok {
-			old, err := version.Parse(current["Version"])
-			if err != nil {
-				log.Printf("version %q: %v", current["Version"], err)
-				return
-			}
-			new, err := version.Parse(pkg["Version"])
-			if err != nil {
-				log.Printf("version %q: %v", pkg["Version"], err)
-				return
-			}
-			if version.Compare(new, old) > 0 {
-				mostRecent[n] = pkg
-			}
-		} else {
-			mostRecent[n] = pkg
+		for _, pkg := range suitePackages {
+			p := pkg["Package"] + "_" + pkg["Version"]
+			mostRecent[p] = pkg
+			suites[p] = append(suites[p], suite)
 		}
 	}
 
@@ -395,7 +381,7 @@ func checkSources() {
 	shardMu := make([]sync.Mutex, len(packageImporters))
 
 	// for every package, calculate who’d be responsible and see if it’s present on that shard.
-	for _, pkg := range mostRecent {
+	for p, pkg := range mostRecent {
 		if strings.HasSuffix(pkg["Package"], "-data") {
 			continue
 		}
@@ -405,7 +391,6 @@ func checkSources() {
 		if strings.HasPrefix(pkg["Package"], "sagemath-database-") {
 			continue
 		}
-		p := pkg["Package"] + "_" + pkg["Version"]
 		shardIdx := shardmapping.TaskIdxForPackage(p, len(packageImporters))
 		importer := packageImporters[shardIdx]
 		// Skip shards that are offline (= for which we have no package list).
@@ -485,6 +470,85 @@ func checkSources() {
 			successfulGarbageCollect.Inc()
 		}
 	}
+
+	// Tell every shard which suites its packages are part of.
+	reqs := make([]*packageimporterpb.SetSuitesRequest, len(packageImporters))
+	for idx := range reqs {
+		reqs[idx] = &packageimporterpb.SetSuitesRequest{}
+	}
+	for p, s := range suites {
+		shardIdx := shardmapping.TaskIdxForPackage(p, len(packageImporters))
+		reqs[shardIdx].Package = append(reqs[shardIdx].Package, &packageimporterpb.PackageSuites{
+			SourcePackage: p,
+			Suite:         s,
+		})
+	}
+	for shardIdx, importer := range packageImporters {
+		if _, online := packages[importer.shard]; !online {
+			continue
+		}
+		log.Printf("Setting suites of %d packages on shard %d (%s)\n", len(reqs[shardIdx].Package), shardIdx, importer.shard)
+		if *dryRun {
+			continue
+		}
+		if _, err := importer.SetSuites(context.Background(), reqs[shardIdx]); err != nil {
+			log.Printf("Could not set suites on shard %s: %v\n", importer.shard, err)
+		}
+	}
+}
+
+// mostRecentPackages returns the most recent version of each source package in
+// the main and contrib sections of suite.
+func mostRecentPackages(suite string) ([]godebiancontrol.Paragraph, error) {
+	var sourcePackages []godebiancontrol.Paragraph
+	for _, section := range []string{"main", "contrib"} {
+		sourcesSuffix := "/dists/" + suite + "/" + section + "/source/Sources.gz"
+		resp, err := http.Get(*mirrorUrl + sourcesSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get Sources.gz: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Could not get %s: %v", sourcesSuffix, resp.Status)
+		}
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Could not initialize gzip reader: %v", err)
+		}
+		defer reader.Close()
+
+		tmp, err := godebiancontrol.Parse(reader)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse Sources.gz: %v", err)
+		}
+		sourcePackages = append(sourcePackages, tmp...)
+	}
+
+	// Only keep the most recent version for each source package:
+	mostRecent := make(map[string]godebiancontrol.Paragraph)
+	for _, pkg := range sourcePackages {
+		n := pkg["Package"]
+		if current, ok := mostRecent[n]; ok {
+			old, err := version.Parse(current["Version"])
+			if err != nil {
+				return nil, fmt.Errorf("version %q: %v", current["Version"], err)
+			}
+			new, err := version.Parse(pkg["Version"])
+			if err != nil {
+				return nil, fmt.Errorf("version %q: %v", pkg["Version"], err)
+			}
+			if version.Compare(new, old) > 0 {
+				mostRecent[n] = pkg
+			}
+		} else {
+			mostRecent[n] = pkg
+		}
+	}
+	result := make([]godebiancontrol.Paragraph, 0, len(mostRecent))
+	for _, pkg := range mostRecent {
+		result = append(result, pkg)
+	}
+	return result, nil
 }
 
 var refeedMu sync.Mutex
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/exec"
@@ -16,12 +17,15 @@ import (
 	"runtime/debug"
 	"runtime/pprof"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Debian/dcs/grpcutil"
+	"github.com/Debian/dcs/internal/contentstore"
 	"github.com/Debian/dcs/internal/filter"
 	"github.com/Debian/dcs/internal/index"
+	"github.com/Debian/dcs/internal/sourcebackend"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
@@ -56,6 +60,10 @@ var (
 		false,
 		"Print log messages when files are skipped")
 
+	compressSources = flag.Bool("compress_sources",
+		false,
+		"Store the files of newly imported packages compressed in the store directory of -shard_path instead of unpacked in its src directory (pass the store directory to dcs-source-backend -compressed_path)")
+
 	tmpdir string
 
 	failedDpkgSourceExtracts = prometheus.NewCounter(
@@ -199,14 +207,18 @@ func (s *server) Import(stream packageimporterpb.PackageImporter_ImportServer) e
 }
 
 // Tries to start a merge and errors in case one is already in progress.
-func (s *server) Merge(context.Context, *packageimporterpb.MergeRequest) (*packageimporterpb.MergeReply, error) {
+func (s *server) Merge(ctx context.Context, req *packageimporterpb.MergeRequest) (*packageimporterpb.MergeReply, error) {
 	select {
 	case s.mergesem <- struct{}{}: // acquire
 	default:
 		return nil, fmt.Errorf("Merge already in progress, please try again later.")
 	}
 	defer func() { <-s.mergesem }() // release
-	if err := mergeToShard(); err != nil {
+	merge := mergeToShard
+	if req.GetIncremental() {
+		merge = mergeDeltaToShard
+	}
+	if err := merge(); err != nil {
 		return nil, err
 	}
 	return &packageimporterpb.MergeReply{}, nil
@@ -284,22 +296,44 @@ func (s *server) GarbageCollect(ctx context.Context, req *packageimporterpb.Garb
 		return nil, err
 	}
 
+	if err := os.Remove(contentstore.BlobPath(filepath.Join(*shardPath, "store"), pkg)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
 	successfulGarbageCollects.Inc()
 	return &packageimporterpb.GarbageCollectReply{}, nil
 }
 
-func cleanupUnsuccessfulMerges() error {
+// Records the Debian suites of all packages, replacing the previous suites.
+// The source backend picks up the new suites with its next query.
+func (s *server) SetSuites(ctx context.Context, req *packageimporterpb.SetSuitesRequest) (*packageimporterpb.SetSuitesReply, error) {
+	suites := make(sourcebackend.Suites, len(req.GetPackage()))
+	for _, pkg := range req.GetPackage() {
+		suites[pkg.GetSourcePackage()] = pkg.GetSuite()
+	}
+	if err := sourcebackend.WriteSuites(sourcebackend.SuitesPath(filepath.Join(*shardPath, "full")), suites); err != nil {
+		return nil, err
+	}
+	return &packageimporterpb.SetSuitesReply{}, nil
+}
+
+// cleanupUnsuccessfulMerges deletes all directories starting with name+"."
+// (e.g. full.1234), except for the one the name symlink points to.
+func cleanupUnsuccessfulMerges(name string) error {
 	fis, err := ioutil.ReadDir(*shardPath)
 	if err != nil {
 		return err
 	}
-	link, err := filepath.EvalSymlinks(filepath.Join(*shardPath, "full"))
+	link, err := filepath.EvalSymlinks(filepath.Join(*shardPath, name))
 	if err != nil {
-		return err
+		if !os.IsNotExist(err) {
+			return err
+		}
+		link = ""
 	}
 	var firstErr error
 	for _, fi := range fis {
-		if !strings.HasPrefix(fi.Name(), "full.") {
+		if !strings.HasPrefix(fi.Name(), name+".") {
 			continue
 		}
 		abs := filepath.Join(*shardPath, fi.Name())
@@ -332,7 +366,7 @@ func mergeToShard() error {
 		return fmt.Errorf("got %d index files, want at least 2", len(indexFiles))
 	}
 
-	if err := cleanupUnsuccessfulMerges(); err != nil {
+	if err := cleanupUnsuccessfulMerges("full"); err != nil {
 		log.Printf("cleanupUnsuccessfulMerges: %v", err)
 	}
 
@@ -389,6 +423,165 @@ func mergeToShard() error {
 	return nil
 }
 
+// deltaChanges determines which packages need to be part of a delta index on
+// top of the full index in base (created at baseTime), which docids of the
+// full index need to be marked as deleted and which names of the full index
+// need to be marked as deleted while other copies of their docid remain (see
+// index.WriteDelta).
+func deltaChanges(base *index.Index, baseTime time.Time, names []string) (changed []string, tombstones []uint32, deletedNames []string, _ error) {
+	current := make(map[string]bool, len(names))
+	for _, name := range names {
+		current[name] = true
+	}
+
+	pkgOf := func(name string) string {
+		if idx := strings.IndexByte(name, '/'); idx > -1 {
+			return name[:idx]
+		}
+		return name
+	}
+
+	inBase := make(map[string]bool)
+	deleted := make(map[string]bool)
+	// copies holds the names of the current docid (its document and its
+	// aliases, see index.Index.Aliases). The docid is only deleted if the
+	// packages of all copies are, otherwise only the deleted copies are.
+	var (
+		copies   []string
+		ownDocid = uint32(math.MaxUint32)
+	)
+	flush := func() {
+		var remaining int
+		for _, name := range copies {
+			if !deleted[pkgOf(name)] {
+				remaining++
+			}
+		}
+		switch {
+		case remaining == 0 && len(copies) > 0:
+			tombstones = append(tombstones, ownDocid)
+		case remaining < len(copies):
+			for _, name := range copies {
+				if deleted[pkgOf(name)] {
+					deletedNames = append(deletedNames, name)
+				}
+			}
+		}
+		copies = copies[:0]
+	}
+	err := base.Files(func(docid uint32, name string) error {
+		// Files passes the name of each document before its aliases.
+		if docid != ownDocid {
+			flush()
+			ownDocid = docid
+		}
+		copies = append(copies, name)
+		pkg := pkgOf(name)
+		if inBase[pkg] {
+			return nil
+		}
+		inBase[pkg] = true
+		// Packages which were garbage collected or re-imported since the
+		// full index was created are deleted from the full index.
+		fi, err := os.Stat(filepath.Join(*shardPath, "idx", pkg))
+		switch {
+		case os.IsNotExist(err):
+			deleted[pkg] = true
+		case err != nil:
+			return err
+		default:
+			deleted[pkg] = !current[pkg] || fi.ModTime().After(baseTime)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	flush()
+
+	for _, name := range names {
+		if !inBase[name] || deleted[name] {
+			changed = append(changed, name)
+		}
+	}
+	return changed, tombstones, deletedNames, nil
+}
+
+// Merges all packages which were imported (or garbage collected) since the
+// last full merge into a delta index, which the source backend queries in
+// addition to the full index. This makes new packages searchable much quicker
+// than a full merge would.
+func mergeDeltaToShard() error {
+	basePath, err := filepath.EvalSymlinks(filepath.Join(*shardPath, "full"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("no full index yet, merging all packages")
+			return mergeToShard()
+		}
+		return err
+	}
+	ts, err := strconv.ParseInt(strings.TrimPrefix(filepath.Base(basePath), "full."), 0, 64)
+	if err != nil {
+		return fmt.Errorf("cannot determine creation time of %s: %v", basePath, err)
+	}
+
+	names, err := packageNames()
+	if err != nil {
+		return err
+	}
+
+	base, err := index.Open(basePath)
+	if err != nil {
+		return err
+	}
+	changed, tombstones, deletedNames, err := deltaChanges(base, time.Unix(ts, 0), names)
+	base.Close()
+	if err != nil {
+		return err
+	}
+	log.Printf("%d packages changed since %s, %d docids and %d copies deleted", len(changed), basePath, len(tombstones), len(deletedNames))
+
+	if err := cleanupUnsuccessfulMerges("delta"); err != nil {
+		log.Printf("cleanupUnsuccessfulMerges: %v", err)
+	}
+
+	indexFiles := make([]string, len(changed))
+	for idx, name := range changed {
+		indexFiles[idx] = filepath.Join(*shardPath, "idx", name)
+	}
+	tmpIndexPath := filepath.Join(*shardPath, fmt.Sprintf("delta.%d", time.Now().Unix()))
+	t0 := time.Now()
+	if err := index.ConcatN(tmpIndexPath, indexFiles); err != nil {
+		log.Printf("ConcatN: %v", err)
+		return err
+	}
+	if err := index.WriteDelta(tmpIndexPath, basePath, tombstones, deletedNames); err != nil {
+		return err
+	}
+	log.Printf("merged delta index %s in %v\n", tmpIndexPath, time.Since(t0))
+
+	successfulMerges.Inc()
+
+	conn, err := grpcutil.DialTLS(*sourceBackendAddr, *tlsCertPath, *tlsKeyPath)
+	if err != nil {
+		log.Fatalf("could not connect to %q: %v", *sourceBackendAddr, err)
+	}
+	defer conn.Close()
+	sourceBackend := sourcebackendpb.NewSourceBackendClient(conn)
+
+	// Load the newly created delta index on top of the current index.
+	_, err = sourceBackend.ReplaceDelta(
+		context.Background(),
+		&sourcebackendpb.ReplaceDeltaRequest{
+			ReplacementPath: filepath.Base(tmpIndexPath),
+		})
+	if err != nil {
+		log.Printf("ReplaceDelta: %v", err)
+		return fmt.Errorf("indexBackend.ReplaceDelta(): %v", err)
+	}
+	return nil
+}
+
 func indexPackage(pkg string) error {
 	log.Printf("Indexing %s\n", pkg)
 	unpacked := filepath.Join(tmpdir, pkg, pkg)
@@ -406,6 +599,8 @@ func indexPackage(pkg string) error {
 	}
 	// +1 because of the / that should not be included in the index.
 	stripLen := len(filepath.Join(tmpdir, pkg)) + 1
+	// files are the indexed files relative to unpacked, for -compress_sources.
+	var files []string
 
 	if err := index.AddDir(
 		unpacked,
@@ -422,6 +617,10 @@ func indexPackage(pkg string) error {
 			return os.Remove(path)
 		},
 		func(path string, info os.FileInfo) error {
+			if *compressSources {
+				files = append(files, path[len(unpacked)+1:])
+				return nil
+			}
 			// Copy this file out of /tmp to our unpacked directory.
 			outputPath := filepath.Join(*shardPath, "src", path[stripLen:])
 			if err := os.MkdirAll(filepath.Dir(outputPath), os.FileMode(0755)); err != nil {
@@ -448,6 +647,16 @@ func indexPackage(pkg string) error {
 	if err := index.Flush(); err != nil {
 		return err
 	}
+	if *compressSources {
+		if err := contentstore.WritePackage(filepath.Join(*shardPath, "store"), pkg, unpacked, files); err != nil {
+			return err
+		}
+		// Remove any uncompressed files of a previous import, which would
+		// otherwise take precedence.
+		if err := os.RemoveAll(filepath.Join(*shardPath, "src", pkg)); err != nil {
+			return err
+		}
+	}
 
 	finalIndexPath := filepath.Join(*shardPath, "idx", pkg)
 	// Move the old index out of the way, if present
@@ -1,6 +1,14 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Debian/dcs/internal/index"
+	"github.com/google/go-cmp/cmp"
+)
 
 func TestIsTar(t *testing.T) {
 	t.Parallel()
@@ -22,3 +30,82 @@ func TestIsTar(t *testing.T) {
 		})
 	}
 }
+
+func TestDeltaChangesSharedCopies(t *testing.T) {
+	tmp := t.TempDir()
+	oldShardPath := *shardPath
+	*shardPath = tmp
+	defer func() { *shardPath = oldShardPath }()
+
+	// Package b contains a copy of a file of package a, so the full index
+	// stores b/y.c as an alias of a/x.c.
+	const shared = "int shared(void) { return 42; }\n"
+	var pkgIndexes []string
+	for _, pkg := range []struct {
+		name  string
+		files map[string]string
+	}{
+		{"a", map[string]string{"a.c": "int a;\n", "x.c": shared}},
+		{"b", map[string]string{"y.c": shared}},
+		{"c", map[string]string{"z.c": "int c;\n"}},
+	} {
+		pkgDir := filepath.Join(tmp, "idx", pkg.name)
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		w, err := index.Create(filepath.Join(tmp, "pkgidx", pkg.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fn := range []string{"a.c", "x.c", "y.c", "z.c"} {
+			content, ok := pkg.files[fn]
+			if !ok {
+				continue
+			}
+			path := filepath.Join(pkgDir, fn)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.AddFile(path, pkg.name+"/"+fn); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		pkgIndexes = append(pkgIndexes, filepath.Join(tmp, "pkgidx", pkg.name))
+	}
+	full := filepath.Join(tmp, "full")
+	if err := index.ConcatN(full, pkgIndexes); err != nil {
+		t.Fatal(err)
+	}
+	base, err := index.Open(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	// Package a is re-imported after the full index was created:
+	baseTime := time.Now().Add(-1 * time.Hour)
+	for _, pkg := range []string{"b", "c"} {
+		old := baseTime.Add(-1 * time.Hour)
+		if err := os.Chtimes(filepath.Join(tmp, "idx", pkg), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed, tombstones, deletedNames, err := deltaChanges(base, baseTime, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b/y.c keeps the docid of a/x.c, so only a needs to be re-added:
+	if diff := cmp.Diff([]string{"a"}, changed); diff != "" {
+		t.Errorf("deltaChanges: changed: unexpected diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]uint32{0}, tombstones); diff != "" {
+		t.Errorf("deltaChanges: tombstones: unexpected diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"a/x.c"}, deletedNames); diff != "" {
+		t.Errorf("deltaChanges: deletedNames: unexpected diff (-want +got):\n%s", diff)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/Debian/dcs/internal/sourcebackend"
 	"github.com/Debian/dcs/internal/version"
 	"github.com/Debian/dcs/ranking"
+	"github.com/Debian/dcs/regexp"
 	_ "github.com/Debian/dcs/varz"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
@@ -31,6 +32,9 @@ var (
 	unpackedPath = flag.String("unpacked_path",
 		"/dcs-ssd/unpacked/",
 		"Path to the unpacked sources")
+	compressedPath = flag.String("compressed_path",
+		"",
+		"Path to the compressed sources (see dcs-package-importer -compress_sources), used for files which are not in -unpacked_path")
 	rankingDataPath = flag.String("ranking_data_path",
 		"/var/dcs/ranking.json",
 		"Path to the JSON containing ranking data")
@@ -40,6 +44,22 @@ var (
 	usePositionalIndex = flag.Bool("use_positional_index",
 		false,
 		"use the pos and posrel index sections for identifier queries")
+
+	maxContextLines = flag.Int("max_context_lines",
+		sourcebackend.DefaultMaxContextLines,
+		"maximum number of context lines before and after each match which a query can request")
+
+	queryTimeout = flag.Duration("query_timeout",
+		2*time.Minute,
+		"time budget of a query: queries which take longer are aborted (0 disables the limit)")
+
+	allowLegacyIndex = flag.Bool("allow_legacy_index",
+		false,
+		"serve indexes without a manifest (written by older versions, before index format version 1) instead of refusing them as incomplete")
+
+	maxDFAStates = flag.Int("max_dfa_states",
+		regexp.MaxStates,
+		"maximum number of DFA states to cache per query: once exceeded, the cache is flushed (0 disables the limit)")
 )
 
 func main() {
@@ -56,6 +76,8 @@ func main() {
 	}
 
 	rand.Seed(time.Now().UnixNano())
+	regexp.MaxStates = *maxDFAStates
+	index.AllowLegacyFormat = *allowLegacyIndex
 	if !strings.HasSuffix(*unpackedPath, "/") {
 		*unpackedPath = *unpackedPath + "/"
 	}
@@ -82,9 +104,21 @@ func main() {
 		}
 	}
 
-	ix, err := index.Open(idx)
-	if err != nil {
-		log.Fatal(err)
+	var ix *index.Index
+	if deltaPath := sourcebackend.DeltaPath(*indexPath); *indexPath != "" {
+		if _, err := os.Stat(deltaPath); err == nil {
+			ix, err = index.OpenDelta(idx, deltaPath)
+			if err != nil {
+				log.Printf("Not using delta index: %v", err)
+			}
+		}
+	}
+	if ix == nil {
+		var err error
+		ix, err = index.Open(idx)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	srv := &sourcebackend.Server{
@@ -92,6 +126,9 @@ func main() {
 		UnpackedPath:       *unpackedPath,
 		IndexPath:          *indexPath,
 		UsePositionalIndex: *usePositionalIndex,
+		CompressedPath:     *compressedPath,
+		MaxContextLines:    *maxContextLines,
+		QueryTimeout:       *queryTimeout,
 	}
 
 	http.Handle("/metrics", promhttp.Handler())
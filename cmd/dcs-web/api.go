@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
@@ -101,27 +102,27 @@ func (rw *resultWriter) fromPointers(pointers []resultPointer) error {
 			rw.w.Write([]byte{','})
 		}
 		m := rw.msg.Match
-		contextBefore := make([]string, 0, 2)
-		if m.Ctxp2 != "" {
-			contextBefore = append(contextBefore, m.Ctxp2)
-		}
-		if m.Ctxp1 != "" {
-			contextBefore = append(contextBefore, m.Ctxp1)
-		}
-		contextAfter := make([]string, 0, 2)
-		if m.Ctxn1 != "" {
-			contextAfter = append(contextAfter, m.Ctxn1)
+		if err := addDuplicates(m, ptr, rw.backend); err != nil {
+			return err
 		}
-		if m.Ctxn2 != "" {
-			contextAfter = append(contextAfter, m.Ctxn2)
+		var ranges []api.Range
+		for _, r := range m.Ranges {
+			ranges = append(ranges, api.Range{
+				Start:     r.Start,
+				End:       r.End,
+				StartRune: r.StartRune,
+				EndRune:   r.EndRune,
+			})
 		}
 		if err := rw.enc.Encode(&api.SearchResult{
 			Package:       m.Package,
 			Path:          m.Path,
 			Line:          m.Line,
 			Context:       m.Context,
-			ContextBefore: contextBefore,
-			ContextAfter:  contextAfter,
+			Ranges:        ranges,
+			ContextBefore: m.ContextBefore,
+			ContextAfter:  m.ContextAfter,
+			Duplicates:    m.Duplicates,
 		}); err != nil {
 			return err
 		}
@@ -129,6 +130,10 @@ func (rw *resultWriter) fromPointers(pointers []resultPointer) error {
 	return nil
 }
 
+func (rw *resultWriter) backend(backendidx int) io.ReaderAt {
+	return bytes.NewReader(rw.perBackend[backendidx])
+}
+
 func (rw *resultWriter) Close() error {
 	rw.w.Write([]byte{']'})
 	for _, mapping := range rw.perBackend {
@@ -250,15 +255,23 @@ func (a *apiserver) common(w http.ResponseWriter, r *http.Request, writeResults
 	if literal == "" {
 		literal = "0"
 	}
+	identifier := r.FormValue("identifier")
 	switch r.FormValue("match_mode") {
 	case "literal":
 		literal = "1"
 	case "regex", "regexp":
 		literal = "0"
+	case "identifier":
+		identifier = "1"
 	}
 
 	// We encode a URL that contains _only_ the q parameter.
-	q := url.Values{"q": []string{query}}.Encode() + "&literal=" + literal
+	q := url.Values{"q": []string{query}}.Encode() + "&literal=" + literal +
+		contextParams(r.FormValue("context_before"), r.FormValue("context_after")) +
+		occurrencesParam(r.FormValue("all_occurrences")) +
+		identifierParam(identifier) +
+		whitespaceParams(r.FormValue("ignore_whitespace"), r.FormValue("ignore_line_breaks")) +
+		maxResultsParam(r.FormValue("max_results"))
 
 	// Uniquely (well, good enough) identify this query for a couple of minutes
 	// (as long as we want to cache results). We could try to normalize the
@@ -303,6 +316,7 @@ func (a *apiserver) common(w http.ResponseWriter, r *http.Request, writeResults
 		filesTotal += total
 	}
 	w.Header().Set("X-Codesearch-FilesTotal", strconv.Itoa(filesTotal))
+	w.Header().Set("X-Codesearch-Occurrences", strconv.Itoa(state.numOccurrences()))
 	startJsonResponse(w)
 
 	if err := writeResults(w, state); err != nil {
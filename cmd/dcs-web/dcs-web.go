@@ -2,6 +2,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
@@ -35,6 +36,7 @@ import (
 	"github.com/Debian/dcs/internal/index"
 	"github.com/Debian/dcs/internal/proto/dcspb"
 	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/Debian/dcs/internal/scope"
 	"github.com/Debian/dcs/internal/version"
 	dcsregexp "github.com/Debian/dcs/regexp"
 	_ "github.com/Debian/dcs/varz"
@@ -71,6 +73,10 @@ var (
 		"",
 		"32-byte hexadecimal key for HMAC-based secure cookie storage (block, i.e. for encryption)")
 
+	usePositionalIndex = flag.Bool("use_positional_index",
+		false,
+		"Whether the source backends use the positional index (dcs-source-backend -use_positional_index), which can answer literal queries too short to result in a trigram query.")
+
 	accessLog *os.File
 
 	resultsPathRe  = regexp.MustCompile(`^/results/([^/]+)/(perpackage_` + strconv.Itoa(resultsPerPackage) + `_)?page_([0-9]+).json$`)
@@ -102,16 +108,94 @@ func validateQuery(query string) error {
 	if err != nil {
 		return err
 	}
+	for _, name := range []string{"context_before", "context_after"} {
+		if _, err := contextLines(fakeUrl.Query(), name); err != nil {
+			return err
+		}
+	}
+	if _, err := maxResults(fakeUrl.Query()); err != nil {
+		return err
+	}
 	rewritten := search.RewriteQuery(*fakeUrl)
-	if rewritten.Query().Get("literal") == "1" {
+	and := rewritten.Query()["and"]
+	if len(and) > 0 && rewritten.Query().Get("q") == "" {
+		return fmt.Errorf("The and: keyword requires a search term")
+	}
+	if near := rewritten.Query().Get("near"); near != "" {
+		if n, err := strconv.Atoi(near); err != nil || n < 1 {
+			return fmt.Errorf("Invalid near: keyword %q, expected a positive number of lines", near)
+		}
+		if len(and) == 0 {
+			return fmt.Errorf("The near: keyword requires the and: keyword")
+		}
+	}
+	for _, name := range []string{"matchscope", "nmatchscope"} {
+		for _, v := range rewritten.Query()[name] {
+			if _, ok := scope.Parse(v); !ok {
+				return fmt.Errorf("Invalid scope: keyword %q, expected code, comment or string", v)
+			}
+		}
+	}
+	if rewritten.Query().Get("q") == "" {
+		// Queries consisting of keywords only search file names, which is
+		// only reasonably selective with a package: or path: keyword.
+		if rewritten.Query().Get("package") == "" && rewritten.Query().Get("path") == "" {
+			return fmt.Errorf("Empty query. To search for file names, use the package: or path: keywords")
+		}
+		return nil
+	}
+	literal := rewritten.Query().Get("literal") == "1"
+	identifier := rewritten.Query().Get("identifier") == "1"
+	// Identifiers take precedence, like in the source backends.
+	whitespace := !identifier && rewritten.Query().Get("ignore_whitespace") == "1"
+	if literal && !identifier && !whitespace && len(and) == 0 {
 		return nil // not a regular expression
 	}
+	compile := func(expr string) (*dcsregexp.Regexp, error) {
+		if literal {
+			expr = regexp.QuoteMeta(expr)
+		}
+		return dcsregexp.Compile(expr)
+	}
 	log.Printf("rewritten query = %q\n", rewritten.String())
-	re, err := dcsregexp.Compile(rewritten.Query().Get("q"))
-	if err != nil {
-		return err
+	var indexQuery *index.Query
+	if identifier {
+		re, err := index.IdentifierRegexp(rewritten.Query().Get("q"))
+		if err != nil {
+			return err
+		}
+		indexQuery = index.RegexpQuery(re)
+	} else if whitespace {
+		re, err := index.WhitespaceRegexp(rewritten.Query().Get("q"))
+		if err != nil {
+			return err
+		}
+		if _, _, ok := index.PositionalLiteral(re); ok && len(and) == 0 && *usePositionalIndex {
+			return nil // a single literal, answered using the positional index
+		}
+		indexQuery = index.WhitespaceQuery(rewritten.Query().Get("q"))
+	} else {
+		compiled, err := compile(rewritten.Query().Get("q"))
+		if err != nil {
+			return err
+		}
+		if _, _, ok := index.PositionalLiteral(compiled.Syntax); ok && len(and) == 0 && *usePositionalIndex {
+			// Literals of any length can be answered using the positional
+			// index, even if they are too short to result in a trigram query.
+			// Without it, the source backends would need to search all files.
+			return nil
+		}
+		indexQuery = index.RegexpQuery(compiled.Syntax)
+	}
+	for _, expr := range and {
+		// Files must match all parts, so any of them can narrow down the
+		// candidates.
+		re, err := compile(expr)
+		if err != nil {
+			return fmt.Errorf("and:%s: %v", expr, err)
+		}
+		indexQuery = indexQuery.And(index.RegexpQuery(re.Syntax))
 	}
-	indexQuery := index.RegexpQuery(re.Syntax)
 	log.Printf("trigram = %v, sub = %v", indexQuery.Trigram, indexQuery.Sub)
 	if len(indexQuery.Trigram) == 0 && len(indexQuery.Sub) == 0 {
 		return fmt.Errorf("Empty index query. See https://codesearch.debian.net/faq#emptyindex")
@@ -139,7 +223,11 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 	if literal == "" {
 		literal = "0"
 	}
-	q := "q=" + url.QueryEscape(query) + "&literal=" + literal
+	q := "q=" + url.QueryEscape(query) + "&literal=" + literal +
+		contextParams(r.FormValue("context_before"), r.FormValue("context_after")) +
+		occurrencesParam(r.FormValue("all_occurrences")) +
+		identifierParam(r.FormValue("identifier")) +
+		whitespaceParams(r.FormValue("ignore_whitespace"), r.FormValue("ignore_line_breaks"))
 
 	log.Printf("[%s] (events) Received query %q\n", src, q)
 	if err := validateQuery("?" + q); err != nil {
@@ -391,6 +479,14 @@ type server struct {
 	decoder *apikeys.Decoder
 }
 
+// optionalUint formats n, if set.
+func optionalUint(n *uint32) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*n), 10)
+}
+
 func (s *server) Search(req *dcspb.SearchRequest, stream dcspb.DCS_SearchServer) error {
 	ctx := stream.Context()
 	query := req.GetQuery()
@@ -405,7 +501,12 @@ func (s *server) Search(req *dcspb.SearchRequest, stream dcspb.DCS_SearchServer)
 	if req.GetLiteral() {
 		literal = "1"
 	}
-	q := "q=" + url.QueryEscape(query) + "&literal=" + literal
+	q := "q=" + url.QueryEscape(query) + "&literal=" + literal +
+		contextParams(optionalUint(req.ContextBefore), optionalUint(req.ContextAfter)) +
+		occurrencesParam(strconv.FormatBool(req.GetAllOccurrences())) +
+		identifierParam(strconv.FormatBool(req.GetIdentifier())) +
+		whitespaceParams(strconv.FormatBool(req.GetIgnoreWhitespace()), strconv.FormatBool(req.GetIgnoreLineBreaks())) +
+		maxResultsParam(strconv.FormatUint(uint64(req.GetMaxResults()), 10))
 
 	log.Printf("[%s] (events) Received query %q\n", src, q)
 	if err := validateQuery("?" + q); err != nil {
@@ -499,6 +600,11 @@ func (s *server) Results(req *dcspb.ResultsRequest, stream dcspb.DCS_ResultsServ
 		if msg.Type != sourcebackendpb.SearchReply_MATCH {
 			continue
 		}
+		if err := addDuplicates(msg.Match, ptr, func(backendidx int) io.ReaderAt {
+			return bytes.NewReader(perBackend[backendidx])
+		}); err != nil {
+			return err
+		}
 		if err := stream.Send(msg.Match); err != nil {
 			return err
 		}
@@ -523,6 +629,7 @@ func toEventProto(data []byte) (*dcspb.Event, error) {
 			FilesProcessed int
 			FilesTotal     int
 			Results        int
+			Occurrences    int
 		}
 		if err := json.Unmarshal(data, &p); err != nil {
 			return nil, err
@@ -534,6 +641,35 @@ func toEventProto(data []byte) (*dcspb.Event, error) {
 					FilesProcessed: int64(p.FilesProcessed),
 					FilesTotal:     int64(p.FilesTotal),
 					Results:        int64(p.Results),
+					Occurrences:    int64(p.Occurrences),
+				},
+			},
+		}, nil
+
+	case "error":
+		var e struct {
+			ErrorType    string
+			ErrorMessage string
+		}
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		errorTypes := map[string]dcspb.Error_ErrorType{
+			"cancelled":          dcspb.Error_CANCELLED,
+			"backendunavailable": dcspb.Error_BACKEND_UNAVAILABLE,
+			"failed":             dcspb.Error_FAILED,
+			"invalidquery":       dcspb.Error_INVALID_QUERY,
+			"querytimeout":       dcspb.Error_QUERY_TIMEOUT,
+		}
+		errorType, ok := errorTypes[e.ErrorType]
+		if !ok {
+			errorType = dcspb.Error_FAILED
+		}
+		return &dcspb.Event{
+			Data: &dcspb.Event_Error{
+				Error: &dcspb.Error{
+					Type:    errorType,
+					Message: e.ErrorMessage,
 				},
 			},
 		}, nil
@@ -542,6 +678,7 @@ func toEventProto(data []byte) (*dcspb.Event, error) {
 		var p struct {
 			QueryId     string
 			ResultPages int
+			Occurrences int
 		}
 		if err := json.Unmarshal(data, &p); err != nil {
 			return nil, err
@@ -551,6 +688,7 @@ func toEventProto(data []byte) (*dcspb.Event, error) {
 				Pagination: &dcspb.Pagination{
 					QueryId:     p.QueryId,
 					ResultPages: int64(p.ResultPages),
+					Occurrences: int64(p.Occurrences),
 				},
 			},
 		}, nil
@@ -685,6 +823,7 @@ func main() {
 	http.HandleFunc("/results/", ResultsHandler)
 	http.HandleFunc("/perpackage-results/", PerPackageResultsHandler)
 	http.HandleFunc("/queryz", QueryzHandler)
+	http.HandleFunc("/explain", ExplainHandler)
 	http.HandleFunc("/track", Track)
 
 	traced := http.NewServeMux()
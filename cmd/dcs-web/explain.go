@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/Debian/dcs/cmd/dcs-web/common"
+	"github.com/Debian/dcs/cmd/dcs-web/search"
+	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+)
+
+type backendExplanation struct {
+	Backend int    `json:"backend"`
+	Error   string `json:"error,omitempty"`
+
+	*sourcebackendpb.ExplainReply
+}
+
+type explanation struct {
+	RewrittenURL string `json:"rewritten_url"`
+
+	// Sums over all source backends:
+	PostingQueryFiles uint64 `json:"posting_query_files"`
+	FragmentFiles     uint64 `json:"fragment_files"`
+	KeywordFiles      uint64 `json:"keyword_files"`
+
+	Backends []backendExplanation `json:"backends"`
+}
+
+// ExplainHandler returns the trigram query plan and candidate file counts of
+// each source backend for the query (q=, literal=, identifier=,
+// ignore_whitespace= and ignore_line_breaks= parameters, like /search) as JSON,
+// see also dcs explain.
+func ExplainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("q") == "" {
+		http.Error(w, "q parameter missing", http.StatusBadRequest)
+		return
+	}
+	rewritten := search.RewriteQuery(*r.URL)
+	req := &sourcebackendpb.ExplainRequest{
+		Query:            rewritten.Query().Get("q"),
+		RewrittenUrl:     rewritten.String(),
+		Literal:          rewritten.Query().Get("literal") == "1",
+		Identifier:       rewritten.Query().Get("identifier") == "1",
+		IgnoreWhitespace: rewritten.Query().Get("ignore_whitespace") == "1",
+		IgnoreLineBreaks: rewritten.Query().Get("ignore_line_breaks") == "1",
+	}
+
+	result := explanation{
+		RewrittenURL: rewritten.String(),
+		Backends:     make([]backendExplanation, len(common.SourceBackendStubs)),
+	}
+	var wg sync.WaitGroup
+	for idx, backend := range common.SourceBackendStubs {
+		idx, backend := idx, backend // copy
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, err := backend.Explain(r.Context(), req)
+			result.Backends[idx].Backend = idx
+			if err != nil {
+				result.Backends[idx].Error = err.Error()
+				return
+			}
+			result.Backends[idx].ExplainReply = reply
+		}()
+	}
+	wg.Wait()
+	for _, b := range result.Backends {
+		if b.ExplainReply == nil {
+			continue
+		}
+		result.PostingQueryFiles += b.PostingQueryFiles
+		result.FragmentFiles += b.FragmentFiles
+		result.KeywordFiles += b.KeywordFiles
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -27,9 +27,12 @@ import (
 	"github.com/Debian/dcs/dpkgversion"
 	"github.com/Debian/dcs/internal/frequency"
 	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/Debian/dcs/ranking"
 	"github.com/Debian/dcs/stringpool"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/xerrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -58,6 +61,10 @@ var (
 	headroomPercentage = flag.Float64("headroom_percentage",
 		0.2,
 		"How much space should be kept free on the file system containing -query_results_path in order to be able to write query state. Default: 0.2, i.e. 20% of the total space should be kept free. Set to 0 to disable")
+
+	maxContextLines = flag.Int("max_context_lines",
+		10,
+		"Maximum number of context lines before and after each search result which queries can request (context_before= and context_after= parameters). Must not exceed -max_context_lines of dcs-source-backend")
 )
 
 const (
@@ -75,8 +82,13 @@ type Error struct {
 	// This is set to “error” to distinguish the message type on the client.
 	Type string
 
-	// Currently only “backendunavailable”
+	// One of “backendunavailable”, “querytimeout” (a source backend aborted
+	// the query after exceeding its time budget), “invalidquery” (a source
+	// backend refused the query), “cancelled” or “failed”
 	ErrorType string
+
+	// Set for “invalidquery” only: why the query was refused.
+	ErrorMessage string
 }
 
 type ProgressUpdate struct {
@@ -85,6 +97,7 @@ type ProgressUpdate struct {
 	FilesProcessed int
 	FilesTotal     int
 	Results        int
+	Occurrences    int
 }
 
 func (p *ProgressUpdate) EventType() string {
@@ -108,6 +121,13 @@ type resultPointer struct {
 
 	// Used for per-package results. Points into a stringpool.StringPool
 	packageName *string
+
+	// Identifies the line of the file contents (0 if the content hash is
+	// unknown), so that results in identical files can be collapsed.
+	dupKey uint64
+
+	// Results which were collapsed into this one, see collapseDuplicates.
+	duplicates []resultPointer
 }
 
 type pointerByRanking []resultPointer
@@ -136,7 +156,15 @@ type perBackendState struct {
 	tempFileOffset int64
 	packagePool    *stringpool.StringPool
 	resultPointers []resultPointer
-	allPackages    map[string]bool
+	allPackages    map[string][]string // package → suites
+
+	// Number of occurrences within resultPointers (and their duplicates).
+	occurrences int
+
+	// With max_results, the matches are kept in memory until all backends
+	// are done, and only the best of them are written to tempFile, see
+	// writeBest.
+	matches []*sourcebackendpb.Match
 }
 
 type queryState struct {
@@ -164,13 +192,25 @@ type queryState struct {
 
 	allPackagesSorted []string
 
+	// If non-zero, only the best maxResults results are kept, see
+	// writeToDisk.
+	maxResults int
+
 	FirstPathRank float32
 }
 
 func (qs *queryState) numResults() int {
 	var result int
 	for _, bstate := range qs.perBackend {
-		result += len(bstate.resultPointers)
+		result += len(bstate.resultPointers) + len(bstate.matches)
+	}
+	return result
+}
+
+func (qs *queryState) numOccurrences() int {
+	var result int
+	for _, bstate := range qs.perBackend {
+		result += bstate.occurrences
 	}
 	return result
 }
@@ -184,6 +224,8 @@ func queryBackend(ctx context.Context, queryid, src string, backend sourcebacken
 	// When exiting this function, check that all results were processed. If
 	// not, the backend query must have failed for some reason. Send a progress
 	// update to prevent the query from running forever.
+	errorType := "backendunavailable"
+	var errorMessage string
 	defer func() {
 		stateMu.RLock()
 		filesTotal := state[queryid].filesTotal[backendidx]
@@ -204,8 +246,9 @@ func queryBackend(ctx context.Context, queryid, src string, backend sourcebacken
 		})
 
 		addEventMarshal(queryid, &Error{
-			Type:      "error",
-			ErrorType: "backendunavailable",
+			Type:         "error",
+			ErrorType:    errorType,
+			ErrorMessage: errorMessage,
 		})
 	}()
 
@@ -218,6 +261,7 @@ func queryBackend(ctx context.Context, queryid, src string, backend sourcebacken
 
 	stateMu.RLock()
 	bstate := state[queryid].perBackend[backendidx]
+	maxResults := state[queryid].maxResults
 	stateMu.RUnlock()
 	tempFileWriter := bstate.tempFileWriter
 	orderlyFinished := false
@@ -231,17 +275,29 @@ func queryBackend(ctx context.Context, queryid, src string, backend sourcebacken
 		}
 		if err != nil {
 			log.Printf("[%s] [src:%s] Error decoding result stream: %v\n", queryid, src, err)
+			switch status.Code(err) {
+			case codes.DeadlineExceeded:
+				errorType = "querytimeout"
+			case codes.InvalidArgument:
+				errorType = "invalidquery"
+				errorMessage = status.Convert(err).Message()
+			}
 			return
 		}
 
-		b, err := proto.Marshal(msg)
-		if err != nil {
-			log.Printf("[%s] [src:%s] Error encoding proto: %v\n", queryid, src, err)
-			return
-		}
-		if _, err := tempFileWriter.Write(b); err != nil {
-			log.Printf("[%s] [src:%s] Error writing proto: %v\n", queryid, src, err)
-			return
+		var b []byte
+		if maxResults == 0 {
+			// With max_results, only the best matches of all backends are
+			// written, see writeBest.
+			b, err = proto.Marshal(msg)
+			if err != nil {
+				log.Printf("[%s] [src:%s] Error encoding proto: %v\n", queryid, src, err)
+				return
+			}
+			if _, err := tempFileWriter.Write(b); err != nil {
+				log.Printf("[%s] [src:%s] Error writing proto: %v\n", queryid, src, err)
+				return
+			}
 		}
 
 		switch msg.Type {
@@ -252,7 +308,10 @@ func queryBackend(ctx context.Context, queryid, src string, backend sourcebacken
 			orderlyFinished = msg.ProgressUpdate.FilesProcessed == msg.ProgressUpdate.FilesTotal
 		}
 
-		bstate.tempFileOffset += int64(len(b))
+		if maxResults == 0 {
+			// With max_results, writeBest owns the temporary file.
+			bstate.tempFileOffset += int64(len(b))
+		}
 		stateMu.RLock()
 		done = state[queryid].done
 		stateMu.RUnlock()
@@ -323,6 +382,94 @@ func startQuery(queryid string, querystate queryState) error {
 // query is not a great idea. Best fix may be to make getEvent() use a
 // querystate instead of the string identifier.
 
+// contextParams returns the URL parameters (e.g. “&context_before=5”) for the
+// requested number of context lines before and after each search result. Empty
+// values are left out, so that queries using the default number of context
+// lines keep their query identifier.
+func contextParams(before, after string) string {
+	var params string
+	if before != "" {
+		params += "&context_before=" + url.QueryEscape(before)
+	}
+	if after != "" {
+		params += "&context_after=" + url.QueryEscape(after)
+	}
+	return params
+}
+
+// occurrencesParam returns the URL parameter which requests one result per
+// occurrence (instead of one per line) if v is true (e.g. “1”).
+func occurrencesParam(v string) string {
+	if all, _ := strconv.ParseBool(v); all {
+		return "&all_occurrences=1"
+	}
+	return ""
+}
+
+// identifierParam returns the URL parameter which interprets the query as an
+// identifier in any identifier style (see index.IdentifierRegexp) if v is true
+// (e.g. “1”).
+func identifierParam(v string) string {
+	if identifier, _ := strconv.ParseBool(v); identifier {
+		return "&identifier=1"
+	}
+	return ""
+}
+
+// whitespaceParams returns the URL parameters which make every run of
+// whitespace in the (literal) query match any run of whitespace (see
+// index.WhitespaceRegexp), including line breaks if lineBreaks is true (e.g.
+// “1”). Line breaks imply whitespace.
+func whitespaceParams(whitespace, lineBreaks string) string {
+	if ignore, _ := strconv.ParseBool(lineBreaks); ignore {
+		return "&ignore_whitespace=1&ignore_line_breaks=1"
+	}
+	if ignore, _ := strconv.ParseBool(whitespace); ignore {
+		return "&ignore_whitespace=1"
+	}
+	return ""
+}
+
+// maxResultsParam returns the URL parameter which limits the query to the best
+// v results. Empty values (and 0, i.e. no limit) are left out.
+func maxResultsParam(v string) string {
+	if v == "" || v == "0" {
+		return ""
+	}
+	return "&max_results=" + url.QueryEscape(v)
+}
+
+// maxResults returns the number of results requested by the max_results URL
+// parameter, or 0 (all results) if the parameter is not set.
+func maxResults(query url.Values) (uint32, error) {
+	v := query.Get("max_results")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_results parameter %q", v)
+	}
+	return uint32(n), nil
+}
+
+// contextLines returns the number of context lines requested by the URL
+// parameter name, or nil if the parameter is not set.
+func contextLines(query url.Values, name string) (*uint32, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter %q", name, v)
+	}
+	if n > uint64(*maxContextLines) {
+		return nil, fmt.Errorf("%s must not exceed %d", name, *maxContextLines)
+	}
+	return proto.Uint32(uint32(n)), nil
+}
+
 // maybeStartQuery starts a specified query if that query does not already
 // exist. Returns whether the query existed and any errors during query
 // creation.
@@ -366,7 +513,7 @@ func maybeStartQuery(ctx context.Context, queryid, src, query string) (bool, err
 			packagePool:    stringpool.NewStringPool(),
 			tempFile:       f,
 			tempFileWriter: bufio.NewWriterSize(f, 65536),
-			allPackages:    make(map[string]bool),
+			allPackages:    make(map[string][]string),
 		}
 	}
 	log.Printf("querystate = %v\n", querystate)
@@ -377,10 +524,27 @@ func maybeStartQuery(ctx context.Context, queryid, src, query string) (bool, err
 		log.Fatal(err)
 	}
 	rewritten := search.RewriteQuery(*fakeUrl)
+	// The context and max_results parameters were verified by validateQuery.
+	contextBefore, _ := contextLines(rewritten.Query(), "context_before")
+	contextAfter, _ := contextLines(rewritten.Query(), "context_after")
+	limit, _ := maxResults(rewritten.Query())
+	querystate.maxResults = int(limit)
+	if limit > 0 {
+		// The source backends select their best results by the final
+		// ranking, see ranking.MaxResultsFirstPathRank.
+		querystate.FirstPathRank = ranking.MaxResultsFirstPathRank
+	}
 	searchRequest := &sourcebackendpb.SearchRequest{
-		Query:        rewritten.Query().Get("q"),
-		RewrittenUrl: rewritten.String(),
-		Literal:      rewritten.Query().Get("literal") == "1",
+		Query:            rewritten.Query().Get("q"),
+		RewrittenUrl:     rewritten.String(),
+		Literal:          rewritten.Query().Get("literal") == "1",
+		ContextBefore:    contextBefore,
+		ContextAfter:     contextAfter,
+		AllOccurrences:   rewritten.Query().Get("all_occurrences") == "1",
+		Identifier:       rewritten.Query().Get("identifier") == "1",
+		IgnoreWhitespace: rewritten.Query().Get("ignore_whitespace") == "1",
+		IgnoreLineBreaks: rewritten.Query().Get("ignore_line_breaks") == "1",
+		MaxResults:       limit,
 	}
 	log.Printf("[%s] querying for %+v\n", queryid, searchRequest)
 	if err := startQuery(queryid, querystate); err != nil {
@@ -465,6 +629,7 @@ func sendPaginationUpdate(queryid string, s queryState) {
 		Type        string
 		QueryId     string
 		ResultPages int
+		Occurrences int
 	}
 
 	if s.resultPages > 0 {
@@ -472,6 +637,7 @@ func sendPaginationUpdate(queryid string, s queryState) {
 			Type:        "pagination",
 			QueryId:     queryid,
 			ResultPages: s.resultPages,
+			Occurrences: s.numOccurrences(),
 		})
 	}
 }
@@ -483,15 +649,15 @@ func storeResult(queryid string, backendidx int, result *sourcebackendpb.Match,
 	s := state[queryid]
 	stateMu.RUnlock()
 
+	raw := result
+	if s.maxResults > 0 {
+		// The ranking is computed again when writing, see writeFromPointers.
+		raw = proto.Clone(result).(*sourcebackendpb.Match)
+	}
+
 	if s.FirstPathRank > 0 {
 		// Now store the combined ranking of PathRanking (pre) and Ranking (post).
-		// We add the values because they are both percentages.
-		// To make the Ranking (post) less significant, we multiply it with
-		// 1/10 * FirstPathRank. We used to use maxPathRanking here, but
-		// requiring that means delaying the search until all results are
-		// there. Instead, FirstPathRank is a good enough approximation (but
-		// different enough for each query that we can’t hardcode it).
-		result.Ranking = result.Pathrank + ((s.FirstPathRank * 0.1) * result.Ranking)
+		result.Ranking = ranking.FinalRank(result.Pathrank, result.Ranking, s.FirstPathRank)
 	} else {
 		// This code path (and lock acquisition) gets executed only on the
 		// first result.
@@ -537,14 +703,152 @@ func storeResult(queryid string, backendidx int, result *sourcebackendpb.Match,
 	}
 
 	bstate := s.perBackend[backendidx]
-	bstate.resultPointers = append(bstate.resultPointers, resultPointer{
-		backendidx:  backendidx,
-		ranking:     result.Ranking,
-		offset:      bstate.tempFileOffset,
-		length:      resultLen,
-		pathHash:    h.Sum64(),
-		packageName: bstate.packagePool.Get(result.Package)})
-	bstate.allPackages[result.Package] = true
+	if s.maxResults > 0 {
+		bstate.matches = append(bstate.matches, raw)
+	} else {
+		bstate.resultPointers = append(bstate.resultPointers, resultPointer{
+			backendidx:  backendidx,
+			ranking:     result.Ranking,
+			offset:      bstate.tempFileOffset,
+			length:      resultLen,
+			pathHash:    h.Sum64(),
+			packageName: bstate.packagePool.Get(result.Package),
+			dupKey:      duplicateKey(result)})
+	}
+	bstate.allPackages[result.Package] = result.Suites
+	// Each of the identical files contains the occurrences, too.
+	bstate.occurrences += max(len(result.Ranges), 1) * (1 + len(result.Duplicates))
+}
+
+// writeBest writes the best s.maxResults results of a query with max_results
+// (and the results in files with identical contents, see collapseDuplicates)
+// to the temporary files of the backends which sent them. Each backend sent
+// only its best s.maxResults matches, which are merged in memory. Caller
+// needs to hold stateMu.
+func writeBest(s queryState) error {
+	var pointers []resultPointer
+	for backendidx, bstate := range s.perBackend {
+		for idx, match := range bstate.matches {
+			h := fnv.New64()
+			io.WriteString(h, match.Path)
+			pointers = append(pointers, resultPointer{
+				backendidx:  backendidx,
+				ranking:     ranking.FinalRank(match.Pathrank, match.Ranking, s.FirstPathRank),
+				offset:      int64(idx), // into bstate.matches until written
+				pathHash:    h.Sum64(),
+				packageName: bstate.packagePool.Get(match.Package),
+				dupKey:      duplicateKey(match),
+			})
+		}
+		// Only the packages and occurrences of the best results count.
+		bstate.allPackages = make(map[string][]string)
+		bstate.occurrences = 0
+	}
+	sort.Sort(pointerByRanking(pointers))
+	best := collapseDuplicates(pointers)
+	if len(best) > s.maxResults {
+		best = best[:s.maxResults]
+	}
+
+	write := func(pointer resultPointer) error {
+		bstate := s.perBackend[pointer.backendidx]
+		match := bstate.matches[pointer.offset]
+		b, err := proto.Marshal(&sourcebackendpb.SearchReply{
+			Type:  sourcebackendpb.SearchReply_MATCH,
+			Match: match,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := bstate.tempFileWriter.Write(b); err != nil {
+			return err
+		}
+		pointer.offset = bstate.tempFileOffset
+		pointer.length = len(b)
+		pointer.duplicates = nil // collapsed again by writeToDisk
+		bstate.tempFileOffset += int64(len(b))
+		bstate.resultPointers = append(bstate.resultPointers, pointer)
+		bstate.allPackages[match.Package] = match.Suites
+		bstate.occurrences += max(len(match.Ranges), 1) * (1 + len(match.Duplicates))
+		return nil
+	}
+	for _, pointer := range best {
+		if err := write(pointer); err != nil {
+			return err
+		}
+		for _, dup := range pointer.duplicates {
+			if err := write(dup); err != nil {
+				return err
+			}
+		}
+	}
+	for _, bstate := range s.perBackend {
+		bstate.matches = nil
+	}
+	return nil
+}
+
+func duplicateKey(result *sourcebackendpb.Match) uint64 {
+	if len(result.ContentHash) == 0 {
+		return 0
+	}
+	h := fnv.New64()
+	h.Write(result.ContentHash)
+	fmt.Fprintf(h, ":%d", result.Line)
+	if len(result.Ranges) > 0 {
+		// Tells apart the occurrences on one line (all_occurrences=1).
+		fmt.Fprintf(h, ":%d", result.Ranges[0].Start)
+	}
+	return h.Sum64()
+}
+
+// collapseDuplicates returns pointers (sorted by ranking) without the results
+// in files whose contents are identical to a better-ranked result (e.g.
+// vendored copies of zlib), which are moved into that result’s duplicates.
+func collapseDuplicates(pointers []resultPointer) []resultPointer {
+	first := make(map[uint64]int)
+	collapsed := make([]resultPointer, 0, len(pointers))
+	for _, pointer := range pointers {
+		if pointer.dupKey != 0 {
+			if idx, ok := first[pointer.dupKey]; ok {
+				collapsed[idx].duplicates = append(collapsed[idx].duplicates, pointer)
+				continue
+			}
+			first[pointer.dupKey] = len(collapsed)
+		}
+		collapsed = append(collapsed, pointer)
+	}
+	return collapsed
+}
+
+func readMatch(src io.ReaderAt, pointer resultPointer) (*sourcebackendpb.Match, error) {
+	b := make([]byte, pointer.length)
+	if _, err := src.ReadAt(b, pointer.offset); err != nil {
+		return nil, err
+	}
+	var msg sourcebackendpb.SearchReply
+	if err := proto.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Type != sourcebackendpb.SearchReply_MATCH {
+		return nil, fmt.Errorf("Expected to find a sourcebackendpb.SearchReply_MATCH, instead got %d", msg.Type)
+	}
+	return msg.Match, nil
+}
+
+// addDuplicates appends the paths of the results which were collapsed into
+// pointer (see collapseDuplicates) to match.Duplicates. perBackend returns the
+// results file of the specified backend.
+func addDuplicates(match *sourcebackendpb.Match, pointer resultPointer, perBackend func(backendidx int) io.ReaderAt) error {
+	for _, dup := range pointer.duplicates {
+		m, err := readMatch(perBackend(dup.backendidx), dup)
+		if err != nil {
+			return err
+		}
+		match.Duplicates = append(match.Duplicates, m.Path)
+		match.Duplicates = append(match.Duplicates, m.Duplicates...)
+	}
+	return nil
 }
 
 func failQuery(queryid string) {
@@ -657,7 +961,12 @@ func writeFromPointers(queryid string, f io.Writer, pointers []resultPointer) er
 		// We need to fix the ranking here because we persist raw results from
 		// the dcs-source-backend in queryBackend(), but then modify the
 		// ranking in storeResult().
-		match.Ranking = match.Pathrank + ((firstPathRank * 0.1) * match.Ranking)
+		match.Ranking = ranking.FinalRank(match.Pathrank, match.Ranking, firstPathRank)
+		if err := addDuplicates(match, pointer, func(backendidx int) io.ReaderAt {
+			return s.perBackend[backendidx].tempFile
+		}); err != nil {
+			return err
+		}
 		if err := WriteMatchJSON(match, f); err != nil {
 			return err
 		}
@@ -672,6 +981,12 @@ func writeToDisk(queryid string) error {
 	// Get the slice with results and unset it on the state so that processing can continue.
 	stateMu.Lock()
 	s := state[queryid]
+	if s.maxResults > 0 {
+		if err := writeBest(s); err != nil {
+			stateMu.Unlock()
+			return err
+		}
+	}
 	pointers := make([]resultPointer, 0, s.numResults())
 	for _, bstate := range s.perBackend {
 		pointers = append(pointers, bstate.resultPointers...)
@@ -684,10 +999,18 @@ func writeToDisk(queryid string) error {
 	}
 	idx := 0
 
-	// For each full package (i3-wm_4.8-1), store only the newest version.
-	packageVersions := make(map[string]dpkgversion.Version)
+	// For each full package (i3-wm_4.8-1), store only the newest version
+	// within each suite (e.g. both sid and stable). Versions which are not
+	// part of any suite are only considered for packages without any suite
+	// information.
+	type nameSuite struct {
+		name  string
+		suite string
+	}
+	packageVersions := make(map[nameSuite]dpkgversion.Version)
+	inSuite := make(map[string]bool)
 	for _, bstate := range s.perBackend {
-		for pkg, _ := range bstate.allPackages {
+		for pkg, suites := range bstate.allPackages {
 			underscore := strings.Index(pkg, "_")
 			name := pkg[:underscore]
 			version, err := dpkgversion.Parse(pkg[underscore+1:])
@@ -696,18 +1019,36 @@ func writeToDisk(queryid string) error {
 				continue
 			}
 
-			if bestversion, ok := packageVersions[name]; ok {
-				if dpkgversion.Compare(version, bestversion) > 0 {
-					packageVersions[name] = version
-				}
+			if len(suites) > 0 {
+				inSuite[name] = true
 			} else {
-				packageVersions[name] = version
+				suites = []string{""}
+			}
+			for _, suite := range suites {
+				key := nameSuite{name, suite}
+				if bestversion, ok := packageVersions[key]; ok {
+					if dpkgversion.Compare(version, bestversion) > 0 {
+						packageVersions[key] = version
+					}
+				} else {
+					packageVersions[key] = version
+				}
 			}
 		}
 	}
 
-	packages := make([]string, len(packageVersions))
-	for pkg, _ := range packageVersions {
+	newest := make(map[string]bool)
+	names := make(map[string]bool)
+	for key, version := range packageVersions {
+		if key.suite == "" && inSuite[key.name] {
+			continue
+		}
+		newest[key.name+"_"+version.String()] = true
+		names[key.name] = true
+	}
+
+	packages := make([]string, len(names))
+	for pkg := range names {
 		packages[idx] = pkg
 		idx++
 	}
@@ -721,11 +1062,14 @@ func writeToDisk(queryid string) error {
 	sort.Sort(pointerByRanking(pointers))
 	log.Printf("[%s] pointer sorting done (%v).\n", queryid, time.Since(pointerSortingStarted))
 
+	collapsed := collapseDuplicates(pointers)
+	log.Printf("[%s] %d results after collapsing duplicates.\n", queryid, len(collapsed))
+
 	// TODO: it’d be so much better if we would correctly handle ESPACE errors
 	// in the code below (and above), but for that we need to carefully test it.
 	ensureEnoughSpaceAvailable()
 
-	pages := int(math.Ceil(float64(len(pointers)) / float64(resultsPerPage)))
+	pages := int(math.Ceil(float64(len(collapsed)) / float64(resultsPerPage)))
 
 	// Now save the results into their package-specific files.
 	byPkgSortingStarted := time.Now()
@@ -734,8 +1078,9 @@ func writeToDisk(queryid string) error {
 		pkg := *pointer.packageName
 		underscore := strings.Index(pkg, "_")
 		name := pkg[:underscore]
-		// Skip this result if it’s not in the newest version of the package.
-		if packageVersions[name].String() != pkg[underscore+1:] {
+		// Skip this result if it’s not in the newest version of the package
+		// (within any suite).
+		if !newest[name+"_"+pkg[underscore+1:]] {
 			continue
 		}
 		pkgresults := bypkg[name]
@@ -749,7 +1094,7 @@ func writeToDisk(queryid string) error {
 
 	stateMu.Lock()
 	s = state[queryid]
-	s.resultPointers = pointers
+	s.resultPointers = collapsed
 	s.resultPointersByPkg = bypkg
 	s.resultPages = pages
 	state[queryid] = s
@@ -801,6 +1146,7 @@ func storeProgress(queryid string, backendidx int, progress *sourcebackendpb.Pro
 			FilesProcessed: filesProcessed,
 			FilesTotal:     filesTotal,
 			Results:        s.numResults(),
+			Occurrences:    s.numOccurrences(),
 		})
 		if filesProcessed == filesTotal {
 			finishQuery(queryid)
@@ -8,8 +8,16 @@ import (
 )
 
 var (
-	start = regexp.MustCompile(`(?i)^\s*(-?(?:filetype|package|pkg|path|file)):(\S+)\s+`)
-	end   = regexp.MustCompile(`(?i)\s+(-?(?:filetype|package|pkg|path|file)):(\S+)\s*$`)
+	start = regexp.MustCompile(`(?i)^\s*(-?(?:filetype|package|pkg|path|file|suite|sym|scope)|and|near):(\S+)\s+`)
+	end   = regexp.MustCompile(`(?i)\s+(-?(?:filetype|package|pkg|path|file|suite|sym|scope)|and|near):(\S+)\s*$`)
+
+	// and:<regexp> (which may be repeated) restricts the results to files
+	// which also contain a match of <regexp>, near:<n> additionally to lines
+	// within <n> lines of each other (see regexp.Grep).
+	//
+	// only matches a keyword which remains as the only part of a query, e.g.
+	// in “package:i3-wm path:libi3”.
+	only = regexp.MustCompile(`(?i)^\s*(-?(?:filetype|package|pkg|path|file|suite|sym|scope)|and|near):(\S+)\s*$`)
 )
 
 func rewriteFilters(query url.Values, filtersRe *regexp.Regexp) url.Values {
@@ -25,20 +33,25 @@ func rewriteFilters(query url.Values, filtersRe *regexp.Regexp) url.Values {
 		value := matches[2]
 
 		filter = strings.Replace(filter, "pkg", "package", 1)
-		if filter == "-file" {
+		// The scope parameter enables the scope ranking (see
+		// ranking.RankingOpts), so scope:code becomes matchscope=code.
+		filter = strings.Replace(filter, "scope", "matchscope", 1)
+		if filter == "file" {
+			filter = "path"
+		} else if filter == "-file" {
 			filter = "npath"
 		} else if strings.HasPrefix(filter, "-") {
 			filter = "n" + filter[1:]
 		}
-		if strings.HasSuffix(filter, "filetype") {
+		if strings.HasSuffix(filter, "filetype") || strings.HasSuffix(filter, "suite") || strings.HasSuffix(filter, "scope") {
 			value = strings.ToLower(value)
 		}
 		query.Add(filter, value)
 
-		if filtersRe == start {
-			qstr = strings.TrimPrefix(qstr, matches[0])
-		} else {
+		if filtersRe == end {
 			qstr = strings.TrimSuffix(qstr, matches[0])
+		} else {
+			qstr = strings.TrimPrefix(qstr, matches[0])
 		}
 		matches = filtersRe.FindStringSubmatch(qstr)
 	}
@@ -53,6 +66,17 @@ func RewriteQuery(u url.URL) url.URL {
 	// query is a copy which we will modify using Set() and use in the result
 	query := rewriteFilters(u.Query(), start)
 	query = rewriteFilters(query, end)
+	// A query without search term searches file names (or the sym: keyword).
+	query = rewriteFilters(query, only)
+	if sym := query.Get("sym"); sym != "" && query.Get("q") == "" {
+		// Search for the symbol itself. The source backends only return
+		// matches in the lines which define it.
+		if query.Get("literal") == "1" {
+			query.Set("q", sym)
+		} else {
+			query.Set("q", `\b`+regexp.QuoteMeta(sym)+`\b`)
+		}
+	}
 	u.RawQuery = query.Encode()
 
 	return u
@@ -3,6 +3,7 @@ package search
 
 import (
 	"net/url"
+	"sort"
 	"testing"
 )
 
@@ -123,6 +124,46 @@ func TestRewriteQuery(t *testing.T) {
 		t.Fatalf("Expected npath %q, got %q", "foo", file)
 	}
 
+	// Verify that the suite: and -suite: keywords are recognized (case-insensitively)
+	rewritten = rewrite(t, "/search?q=searchterm+suite%3AStable+-suite%3Asid")
+	querystr = rewritten.Query().Get("q")
+	if querystr != "searchterm" {
+		t.Fatalf("Expected search query %q, got %q", "searchterm", querystr)
+	}
+	if suite := rewritten.Query().Get("suite"); suite != "stable" {
+		t.Fatalf("Expected suite %q, got %q", "stable", suite)
+	}
+	if suite := rewritten.Query().Get("nsuite"); suite != "sid" {
+		t.Fatalf("Expected nsuite %q, got %q", "sid", suite)
+	}
+
+	// Verify that the sym: keyword is recognized (case-sensitively) and
+	// results in a query for the symbol if there is no other query
+	rewritten = rewrite(t, "/search?q=sym%3Ai3Font")
+	if querystr := rewritten.Query().Get("q"); querystr != `\bi3Font\b` {
+		t.Fatalf("Expected search query %q, got %q", `\bi3Font\b`, querystr)
+	}
+	if sym := rewritten.Query().Get("sym"); sym != "i3Font" {
+		t.Fatalf("Expected sym %q, got %q", "i3Font", sym)
+	}
+	rewritten = rewrite(t, "/search?q=sym%3Ai3Font+load_font")
+	if querystr := rewritten.Query().Get("q"); querystr != "load_font" {
+		t.Fatalf("Expected search query %q, got %q", "load_font", querystr)
+	}
+
+	// Verify that queries consisting of keywords only are rewritten into an
+	// empty query (i.e. a file name search)
+	rewritten = rewrite(t, "/search?q=package%3Ai3-wm+file%3Ameson_options.txt")
+	if querystr := rewritten.Query().Get("q"); querystr != "" {
+		t.Fatalf("Expected empty search query, got %q", querystr)
+	}
+	if pkg := rewritten.Query().Get("package"); pkg != "i3-wm" {
+		t.Fatalf("Expected package %q, got %q", "i3-wm", pkg)
+	}
+	if path := rewritten.Query().Get("path"); path != "meson_options.txt" {
+		t.Fatalf("Expected path %q, got %q", "meson_options.txt", path)
+	}
+
 	// Verify that the multiple keywords work as expected
 	rewritten = rewrite(t, "/search?q=searchterm+package%3Ai3-WM+filetype%3Ac")
 	querystr = rewritten.Query().Get("q")
@@ -161,4 +202,35 @@ func TestRewriteQuery(t *testing.T) {
 	if seen != 2 {
 		t.Fatalf("Expected two elements in the hash of the -package keyword, saw %d", seen)
 	}
+
+	// Verify that the and: (repeatable) and near: keywords are moved
+	rewritten = rewrite(t, "/search?q=open%5C%28+and%3Aclose%5C%28+and%3Aread%5C%28+near%3A3")
+	querystr = rewritten.Query().Get("q")
+	if querystr != `open\(` {
+		t.Fatalf("Expected search query %q, got %q", `open\(`, querystr)
+	}
+	and := rewritten.Query()["and"]
+	sort.Strings(and)
+	if len(and) != 2 || and[0] != `close\(` || and[1] != `read\(` {
+		t.Fatalf("Expected and %q, got %q", []string{`close\(`, `read\(`}, and)
+	}
+	if near := rewritten.Query().Get("near"); near != "3" {
+		t.Fatalf("Expected near %q, got %q", "3", near)
+	}
+
+	// Verify that the scope: keyword does not clash with the scope ranking
+	rewritten = rewrite(t, "/search?q=cleanup%5C%28+scope%3ACode+-scope%3Acomment&scope=1")
+	querystr = rewritten.Query().Get("q")
+	if querystr != `cleanup\(` {
+		t.Fatalf("Expected search query %q, got %q", `cleanup\(`, querystr)
+	}
+	if scope := rewritten.Query().Get("matchscope"); scope != "code" {
+		t.Fatalf("Expected matchscope %q, got %q", "code", scope)
+	}
+	if nscope := rewritten.Query().Get("nmatchscope"); nscope != "comment" {
+		t.Fatalf("Expected nmatchscope %q, got %q", "comment", nscope)
+	}
+	if scope := rewritten.Query().Get("scope"); scope != "1" {
+		t.Fatalf("Expected scope %q, got %q", "1", scope)
+	}
 }
@@ -35,11 +35,13 @@ type halfRenderedResult struct {
 	SourcePackage string
 	RelativePath  string
 	Context       template.HTML
+	Duplicates    []string // paths of files with identical contents
+	OtherPackages int      // number of other packages among Duplicates
 }
 
 func maybeAppendContext(context []string, line string) []string {
 	if strings.TrimSpace(line) != "" {
-		replaced := line
+		replaced := template.HTMLEscapeString(line)
 		for strings.HasPrefix(replaced, "\t") {
 			replaced = strings.Replace(replaced, "\t", "    ", 1)
 		}
@@ -49,6 +51,52 @@ func maybeAppendContext(context []string, line string) []string {
 	}
 }
 
+// highlight returns the HTML-escaped line, with the matches at ranges
+// highlighted.
+func highlight(line string, ranges []dcsregexp.Range) string {
+	var b strings.Builder
+	last := 0
+	for _, r := range ranges {
+		if r.Start < last || r.End > len(line) {
+			continue // ranges do not belong to line
+		}
+		b.WriteString(template.HTMLEscapeString(line[last:r.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(line[r.Start:r.End]))
+		b.WriteString("</mark>")
+		last = r.End
+	}
+	b.WriteString(template.HTMLEscapeString(line[last:]))
+	return b.String()
+}
+
+// renderContext returns the line of result (in bold, with the matches
+// highlighted) and its context lines as HTML.
+func renderContext(result dcsregexp.Match) template.HTML {
+	var context []string
+	for _, line := range result.ContextBefore {
+		context = maybeAppendContext(context, line)
+	}
+	context = append(context, "<strong>"+highlight(result.Context, result.Ranges)+"</strong>")
+	for _, line := range result.ContextAfter {
+		context = maybeAppendContext(context, line)
+	}
+	return template.HTML(strings.Join(context, "<br>"))
+}
+
+// otherPackages returns the number of source packages (other than the one of
+// path) which contain one of duplicates.
+func otherPackages(path string, duplicates []string) int {
+	own, _ := splitPath(path)
+	packages := make(map[string]bool)
+	for _, dup := range duplicates {
+		if pkg, _ := splitPath(dup); pkg != own {
+			packages[pkg] = true
+		}
+	}
+	return len(packages)
+}
+
 func splitPath(path string) (sourcePackage string, relativePath string) {
 	for i := 0; i < len(path); i++ {
 		if path[i] == '_' {
@@ -143,13 +191,6 @@ func renderPerPackage(w http.ResponseWriter, r *http.Request, queryid string, pa
 	for idx, pp := range results {
 		halfrendered := make([]halfRenderedResult, len(pp.RawResults))
 		for idx, result := range pp.RawResults {
-			var context []string
-			context = maybeAppendContext(context, result.Ctxp2)
-			context = maybeAppendContext(context, result.Ctxp1)
-			context = append(context, "<strong>"+result.Context+"</strong>")
-			context = maybeAppendContext(context, result.Ctxn1)
-			context = maybeAppendContext(context, result.Ctxn2)
-
 			sourcePackage, relativePath := splitPath(result.Path)
 
 			halfrendered[idx] = halfRenderedResult{
@@ -159,7 +200,9 @@ func renderPerPackage(w http.ResponseWriter, r *http.Request, queryid string, pa
 				Ranking:       result.Ranking,
 				SourcePackage: sourcePackage,
 				RelativePath:  relativePath,
-				Context:       template.HTML(strings.Join(context, "<br>")),
+				Context:       renderContext(result),
+				Duplicates:    result.Duplicates,
+				OtherPackages: otherPackages(result.Path, result.Duplicates),
 			}
 		}
 		results[idx] = perPackageResults{
@@ -205,6 +248,9 @@ func renderPerPackage(w http.ResponseWriter, r *http.Request, queryid string, pa
 // page= page number
 // perpkg= per-package grouping
 // literal= literal vs. regex search
+// context_before=, context_after= number of context lines
+// all_occurrences= one result per occurrence instead of per line
+// identifier= identifier search in any identifier style
 func Search(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if err := r.ParseForm(); err != nil {
@@ -224,7 +270,11 @@ func Search(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// We encode a URL that contains _only_ the q parameter.
-	q := url.Values{"q": []string{query}}.Encode() + "&literal=" + literal
+	q := url.Values{"q": []string{query}}.Encode() + "&literal=" + literal +
+		contextParams(r.Form.Get("context_before"), r.Form.Get("context_after")) +
+		occurrencesParam(r.Form.Get("all_occurrences")) +
+		identifierParam(r.Form.Get("identifier")) +
+		whitespaceParams(r.Form.Get("ignore_whitespace"), r.Form.Get("ignore_line_breaks"))
 
 	pageStr := r.Form.Get("page")
 	if pageStr == "" {
@@ -297,13 +347,6 @@ func Search(w http.ResponseWriter, r *http.Request) {
 
 	halfrendered := make([]halfRenderedResult, len(results))
 	for idx, result := range results {
-		var context []string
-		context = maybeAppendContext(context, result.Ctxp2)
-		context = maybeAppendContext(context, result.Ctxp1)
-		context = append(context, "<strong>"+result.Context+"</strong>")
-		context = maybeAppendContext(context, result.Ctxn1)
-		context = maybeAppendContext(context, result.Ctxn2)
-
 		sourcePackage, relativePath := splitPath(result.Path)
 
 		halfrendered[idx] = halfRenderedResult{
@@ -313,7 +356,9 @@ func Search(w http.ResponseWriter, r *http.Request) {
 			Ranking:       result.Ranking,
 			SourcePackage: sourcePackage,
 			RelativePath:  relativePath,
-			Context:       template.HTML(strings.Join(context, "<br>")),
+			Context:       renderContext(result),
+			Duplicates:    result.Duplicates,
+			OtherPackages: otherPackages(result.Path, result.Duplicates),
 		}
 	}
 
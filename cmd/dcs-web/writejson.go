@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"io"
 
 	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
@@ -58,12 +59,12 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = b.WriteString("\"ctxp2\":")
+	_, err = b.WriteString("\"context_before\":")
 	if err != nil {
 		return err
 	}
 	{
-		s := match.Ctxp2
+		s := match.ContextBefore
 		buf, err = json.Marshal(s)
 		if err != nil {
 			return err
@@ -77,12 +78,12 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = b.WriteString("\"ctxp1\":")
+	_, err = b.WriteString("\"context\":")
 	if err != nil {
 		return err
 	}
 	{
-		s := match.Ctxp1
+		s := match.Context
 		buf, err = json.Marshal(s)
 		if err != nil {
 			return err
@@ -96,12 +97,12 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = b.WriteString("\"context\":")
+	_, err = b.WriteString("\"context_after\":")
 	if err != nil {
 		return err
 	}
 	{
-		s := match.Context
+		s := match.ContextAfter
 		buf, err = json.Marshal(s)
 		if err != nil {
 			return err
@@ -115,12 +116,12 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = b.WriteString("\"ctxn1\":")
+	_, err = b.WriteString("\"pathrank\":")
 	if err != nil {
 		return err
 	}
 	{
-		s := match.Ctxn1
+		s := match.Pathrank
 		buf, err = json.Marshal(s)
 		if err != nil {
 			return err
@@ -134,12 +135,12 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = b.WriteString("\"ctxn2\":")
+	_, err = b.WriteString("\"ranking\":")
 	if err != nil {
 		return err
 	}
 	{
-		s := match.Ctxn2
+		s := match.Ranking
 		buf, err = json.Marshal(s)
 		if err != nil {
 			return err
@@ -153,12 +154,12 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = b.WriteString("\"pathrank\":")
+	_, err = b.WriteString("\"package\":")
 	if err != nil {
 		return err
 	}
 	{
-		s := match.Pathrank
+		s := match.Package
 		buf, err = json.Marshal(s)
 		if err != nil {
 			return err
@@ -168,36 +169,33 @@ func WriteMatchJSON(match *sourcebackendpb.Match, w io.Writer) error {
 			return err
 		}
 	}
-	err = b.WriteByte(',')
-	if err != nil {
-		return err
-	}
-	_, err = b.WriteString("\"ranking\":")
-	if err != nil {
-		return err
-	}
-	{
-		s := match.Ranking
-		buf, err = json.Marshal(s)
+	if len(match.Ranges) > 0 {
+		_, err = b.WriteString(",\"ranges\":[")
 		if err != nil {
 			return err
 		}
-		_, err = b.Write(buf)
+		for idx, r := range match.Ranges {
+			if idx > 0 {
+				if err = b.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			_, err = fmt.Fprintf(b, "{\"start\":%d,\"end\":%d,\"start_rune\":%d,\"end_rune\":%d}", r.Start, r.End, r.StartRune, r.EndRune)
+			if err != nil {
+				return err
+			}
+		}
+		err = b.WriteByte(']')
 		if err != nil {
 			return err
 		}
 	}
-	err = b.WriteByte(',')
-	if err != nil {
-		return err
-	}
-	_, err = b.WriteString("\"package\":")
-	if err != nil {
-		return err
-	}
-	{
-		s := match.Package
-		buf, err = json.Marshal(s)
+	if len(match.Duplicates) > 0 {
+		_, err = b.WriteString(",\"duplicates\":")
+		if err != nil {
+			return err
+		}
+		buf, err = json.Marshal(match.Duplicates)
 		if err != nil {
 			return err
 		}
@@ -32,7 +32,9 @@ Index query commands:
 	posting  - list the (decoded) posting list for the specified trigram
 	matches  - list the filename[:pos] matches for the specified trigram
 	search   - list the filename[:pos] matches for the specified search query
+	explain  - show the trigram query plan and candidate counts for a search query
 	replay   - replay a query log
+	verify   - check the consistency of the specified index files
 
 Index manipulation commands:
 	create   - create an index
@@ -148,8 +150,12 @@ func main() {
 		err = merge(args)
 	case "search":
 		err = search(args)
+	case "explain":
+		err = explain(args)
 	case "replay":
 		err = replay(args)
+	case "verify":
+		err = verify(args)
 	case "apikey-create":
 		err = apikeyCreate(args)
 	case "apikey-verify":
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	dcssearch "github.com/Debian/dcs/cmd/dcs-web/search"
+	"github.com/Debian/dcs/internal/index"
+	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/Debian/dcs/internal/sourcebackend"
+)
+
+const explainHelp = `explain - show the trigram query plan and candidate counts for a search query
+
+Prints the rewritten query URL, the trigram query (AND/OR of trigrams), the
+number of docid and pos entries of each trigram and the number of candidate
+files after each filtering step, i.e. the number of files which would need to
+be searched.
+
+Example:
+  % dcs explain -idx=/srv/dcs/shard4/full -query='i3Font package:i3-wm'
+  rewritten URL: ?package=i3-wm&q=i3Font
+  query tree:    "3Fo" "Fon" "i3F" "ont"
+
+  trigram  docids  positions
+  "3Fo"    5118    11291
+  "Fon"    161331  1022933
+  "i3F"    29      301
+  "ont"    2312816 37460190
+
+  candidates after PostingQuery:     29
+  candidates after FilterFragments:  29
+  candidates after FilterByKeywords: 17
+`
+
+func explain(args []string) error {
+	fset := flag.NewFlagSet("explain", flag.ExitOnError)
+	fset.Usage = usage(fset, explainHelp)
+	var idx string
+	fset.StringVar(&idx, "idx", "", "path to the index file to work with")
+	var query string
+	fset.StringVar(&query, "query", "", "search query, including keywords such as package:")
+	var literal bool
+	fset.BoolVar(&literal, "literal", false, "interpret the query as a literal instead of a regular expression")
+	var identifier bool
+	fset.BoolVar(&identifier, "identifier", false, "interpret the query as an identifier in any identifier style (e.g. getUserName as get_user_name)")
+	var ignoreWhitespace, ignoreLineBreaks bool
+	fset.BoolVar(&ignoreWhitespace, "ignore_whitespace", false, "let every run of whitespace in the (literal) query match any run of whitespace")
+	fset.BoolVar(&ignoreLineBreaks, "ignore_line_breaks", false, "like -ignore_whitespace, but whitespace also matches line breaks")
+	var pos bool
+	fset.BoolVar(&pos, "pos", false, "use the positional index, like dcs-source-backend -use_positional_index")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if idx == "" || query == "" {
+		fset.Usage()
+		os.Exit(1)
+	}
+
+	ix, err := index.Open(idx)
+	if err != nil {
+		return fmt.Errorf("Could not open index: %v", err)
+	}
+	defer ix.Close()
+
+	values := url.Values{"q": []string{query}}
+	if literal {
+		values.Set("literal", "1")
+	}
+	if identifier {
+		values.Set("identifier", "1")
+	}
+	if ignoreWhitespace || ignoreLineBreaks {
+		values.Set("ignore_whitespace", "1")
+	}
+	if ignoreLineBreaks {
+		values.Set("ignore_line_breaks", "1")
+	}
+	rewritten := dcssearch.RewriteQuery(url.URL{RawQuery: values.Encode()})
+
+	srv := &sourcebackend.Server{
+		Index:              ix,
+		IndexPath:          idx,
+		UsePositionalIndex: pos,
+	}
+	reply, err := srv.Explain(context.Background(), &sourcebackendpb.ExplainRequest{
+		Query:            rewritten.Query().Get("q"),
+		RewrittenUrl:     rewritten.String(),
+		Literal:          literal,
+		Identifier:       identifier,
+		IgnoreWhitespace: ignoreWhitespace,
+		IgnoreLineBreaks: ignoreLineBreaks,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("rewritten URL: %s\n", rewritten.String())
+	fmt.Printf("query tree:    %s\n", reply.QueryTree)
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "trigram\tdocids\tpositions\n")
+	for _, t := range reply.Trigrams {
+		fmt.Fprintf(tw, "%q\t%d\t%d\n", t.Trigram, t.DocidEntries, t.PosEntries)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Printf("candidates after PostingQuery:     %d\n", reply.PostingQueryFiles)
+	fmt.Printf("candidates after FilterFragments:  %d\n", reply.FragmentFiles)
+	fmt.Printf("candidates after FilterByKeywords: %d\n", reply.KeywordFiles)
+	if reply.Positional {
+		fmt.Println()
+		fmt.Println("NOTE: this query is answered from the positional index instead, see dcs search -pos")
+	}
+	return nil
+}
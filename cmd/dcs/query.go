@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/proto"
 )
 
 const queryHelp = `query - performs a search query
@@ -27,6 +28,9 @@ Friendlier frontends welcome :)
 
 Example:
   % dcs query i3Font
+  % dcs query -C 5 i3Font
+  % dcs query -all_occurrences -C 0 XCloseDisplay
+  % dcs query -identifier getUserName
 `
 
 func query(args []string) error {
@@ -44,12 +48,46 @@ func query(args []string) error {
 		// “program!github.com/Debian/dcs/cmd/dcs”:
 		"MTU5OTI5NzQzN3xDU1d4UE0yZllVUk9TVXRfdmxicEhIdUxiU3YzTkxGRjZNRl90WUc4bUg1OVdqNU9CM3RQaXFsa0xaRGdZRlZPSWNCZG1QNGZ3ZUNEcXp2SGdocVlEc1dkQmxRSUh0dmZoM0xKazRrPXx5ED9o0r-7uawKvV_K0Fb4QdbHsTV1qfY0XYFrl_904g==",
 		"Debian Code Search API key to use, see https://codesearch.debian.net/apikeys/ for more details. Please get an API key if you are doing automated queries.")
+	var before, after, both int
+	fset.IntVar(&before, "B", -1, "print this many lines of context before each match (server default if negative)")
+	fset.IntVar(&after, "A", -1, "print this many lines of context after each match (server default if negative)")
+	fset.IntVar(&both, "C", -1, "print this many lines of context before and after each match (overridden by -B and -A)")
+	var allOccurrences bool
+	fset.BoolVar(&allOccurrences, "all_occurrences", false, "print every occurrence (with its column) instead of every matching line")
+	var identifier bool
+	fset.BoolVar(&identifier, "identifier", false, "interpret the query as an identifier in any identifier style (e.g. getUserName as get_user_name)")
+	var ignoreWhitespace, ignoreLineBreaks bool
+	fset.BoolVar(&ignoreWhitespace, "ignore_whitespace", false, "let every run of whitespace in the (literal) query match any run of whitespace")
+	fset.BoolVar(&ignoreLineBreaks, "ignore_line_breaks", false, "like -ignore_whitespace, but whitespace also matches line breaks")
+	var maxResults uint
+	fset.UintVar(&maxResults, "max_results", 0, "print only (approximately) the best this many results, which is much faster for queries with many results (all results if 0)")
 	if err := fset.Parse(args); err != nil {
 		return err
 	}
 	if fset.NArg() < 1 {
 		return fmt.Errorf("Usage: query <search term(s)>")
 	}
+	if before < 0 {
+		before = both
+	}
+	if after < 0 {
+		after = both
+	}
+	req := &dcspb.SearchRequest{
+		Query:            strings.Join(fset.Args(), " "),
+		Apikey:           apikey,
+		AllOccurrences:   allOccurrences,
+		Identifier:       identifier,
+		IgnoreWhitespace: ignoreWhitespace,
+		IgnoreLineBreaks: ignoreLineBreaks,
+		MaxResults:       uint32(maxResults),
+	}
+	if before >= 0 {
+		req.ContextBefore = proto.Uint32(uint32(before))
+	}
+	if after >= 0 {
+		req.ContextAfter = proto.Uint32(uint32(after))
+	}
 	log.Printf("dialing %s", target)
 
 	if grpcEnableLog {
@@ -67,10 +105,7 @@ func query(args []string) error {
 	}
 	log.Printf("sending Search query")
 	dcs := dcspb.NewDCSClient(conn)
-	stream, err := dcs.Search(context.Background(), &dcspb.SearchRequest{
-		Query:  strings.Join(fset.Args(), " "),
-		Apikey: apikey,
-	})
+	stream, err := dcs.Search(context.Background(), req)
 	if err != nil {
 		return err
 	}
@@ -83,6 +118,9 @@ func query(args []string) error {
 			return err
 		}
 		switch ev := event.Data.(type) {
+		case *dcspb.Event_Error:
+			log.Printf("error: %v %s", ev.Error.GetType(), ev.Error.GetMessage())
+
 		case *dcspb.Event_Progress:
 			log.Printf("progress: %v of %v files searched (%.2f%%)",
 				ev.Progress.FilesProcessed,
@@ -90,7 +128,9 @@ func query(args []string) error {
 				100*float64(ev.Progress.FilesProcessed)/float64(ev.Progress.FilesTotal))
 
 		case *dcspb.Event_Pagination:
-			log.Printf("query complete, now downloading results for %q", ev.Pagination.GetQueryId())
+			log.Printf("query complete (%d occurrences), now downloading results for %q",
+				ev.Pagination.GetOccurrences(),
+				ev.Pagination.GetQueryId())
 			stream, err := dcs.Results(context.Background(), &dcspb.ResultsRequest{
 				QueryId: ev.Pagination.GetQueryId(),
 				Apikey:  apikey,
@@ -106,11 +146,32 @@ func query(args []string) error {
 				if err != nil {
 					return err
 				}
-				// TODO: implement before/after/context flags
-				fmt.Printf("%s:%d: %s\n",
-					match.GetPath(),
-					match.GetLine(),
-					match.GetContext())
+				// Like grep, mark context lines with a dash.
+				before := match.GetContextBefore()
+				for idx, line := range before {
+					fmt.Printf("%s-%d- %s\n",
+						match.GetPath(),
+						int(match.GetLine())-len(before)+idx,
+						line)
+				}
+				if ranges := match.GetRanges(); allOccurrences && len(ranges) > 0 {
+					fmt.Printf("%s:%d:%d: %s\n",
+						match.GetPath(),
+						match.GetLine(),
+						ranges[0].GetStartRune()+1,
+						match.GetContext())
+				} else {
+					fmt.Printf("%s:%d: %s\n",
+						match.GetPath(),
+						match.GetLine(),
+						match.GetContext())
+				}
+				for idx, line := range match.GetContextAfter() {
+					fmt.Printf("%s-%d- %s\n",
+						match.GetPath(),
+						int(match.GetLine())+1+idx,
+						line)
+				}
 			}
 
 		}
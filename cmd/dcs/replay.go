@@ -112,7 +112,7 @@ func (si *shardedOldIndex) measure(idx int, query string, _, skipFile, skipGrep
 			files = append(files, result)
 		}
 	}
-	files = sourcebackend.FilterByKeywords(&rewritten, files)
+	files = sourcebackend.FilterByKeywords(&rewritten, files, nil)
 	m.FilesSearched = len(files)
 	m.PostingNano = int64(time.Since(start))
 	m.Matches = grep(rewritten.Query().Get("q"), files, rankingopts, skipFile, skipGrep)
@@ -221,6 +221,13 @@ func grep(query string, files ranking.ResultPaths, rankingopts ranking.RankingOp
 	// the grepping will naturally become slower.
 	work := make(chan ranking.ResultPath)
 
+	// Like in the source backend, all workers share the compiled regexp.
+	re, err := regexp.Compile(query)
+	if err != nil {
+		log.Printf("%s\n", err)
+		return 0
+	}
+
 	var wg sync.WaitGroup
 	// TODO: add numWorkers && use defer, not files
 	wg.Add(len(files))
@@ -251,12 +258,6 @@ func grep(query string, files ranking.ResultPaths, rankingopts ranking.RankingOp
 	}
 	for i := 0; i < numWorkers; i++ {
 		go func() {
-			re, err := regexp.Compile(query)
-			if err != nil {
-				log.Printf("%s\n", err)
-				return
-			}
-
 			grep := regexp.Grep{
 				Regexp: re,
 				Stdout: os.Stdout,
@@ -276,7 +277,6 @@ func grep(query string, files ranking.ResultPaths, rankingopts ranking.RankingOp
 					file.Ranking += 0.0008 * querystr.Match(&sourcePkgName)
 				}
 
-				// TODO: figure out how to safely clone a dcs/regexp
 				if !skipFile {
 					if skipGrep {
 						if f, err := os.Open(file.Path); err == nil {
@@ -433,7 +433,7 @@ func (si *shardedNewIndex) measure(idx int, query string, pos, skipFile, skipGre
 				files = append(files, result)
 			}
 		}
-		files = sourcebackend.FilterByKeywords(&rewritten, files)
+		files = sourcebackend.FilterByKeywords(&rewritten, files, nil)
 		m.PostingNano = int64(time.Since(start))
 		if !skipFile {
 			filesSearched, matches, err := verifyMatches(string(s.Rune), files)
@@ -456,7 +456,7 @@ func (si *shardedNewIndex) measure(idx int, query string, pos, skipFile, skipGre
 				files = append(files, result)
 			}
 		}
-		files = sourcebackend.FilterByKeywords(&rewritten, files)
+		files = sourcebackend.FilterByKeywords(&rewritten, files, nil)
 		m.FilesSearched = len(files)
 		m.PostingNano = int64(time.Since(start))
 		m.Matches = grep(rewritten.Query().Get("q"), files, rankingopts, skipFile, skipGrep)
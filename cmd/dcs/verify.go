@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Debian/dcs/internal/index"
+)
+
+const verifyHelp = `verify - check the consistency of the specified index files
+
+Verifies that the meta tables are sorted, that data offsets are monotonic and
+within bounds, that all docids are covered by the docid map, that the posrel
+section matches the pos section and that all TurboPFor blocks decode.
+
+Reports the file, trigram and offset of the first corruption.
+
+Example:
+  % dcs verify /srv/dcs/shard0/full
+  /srv/dcs/shard0/full: ok
+
+  % dcs verify /srv/dcs/shard*/full
+  /srv/dcs/shard0/full: ok
+  2026/10/17 02:29:25 /srv/dcs/shard1/full: posting.posrel.data: trigram "\nin" (682350) at offset 0: 7 bytes for 57 positions, want 8 bytes
+`
+
+func verify(args []string) error {
+	fset := flag.NewFlagSet("verify", flag.ExitOnError)
+	allowLegacy := fset.Bool("allow_legacy", false, "verify indexes without a manifest (written by older versions) instead of refusing them as incomplete")
+	fset.Usage = usage(fset, verifyHelp)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 1 {
+		return fmt.Errorf("Usage: verify <index> [<index>…]")
+	}
+	index.AllowLegacyFormat = *allowLegacy
+	for _, dir := range fset.Args() {
+		i, err := index.Open(dir)
+		if err != nil {
+			return fmt.Errorf("Could not open index: %v", err)
+		}
+		err = i.Verify()
+		i.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", dir, err)
+		}
+		fmt.Printf("%s: ok\n", dir)
+	}
+	return nil
+}
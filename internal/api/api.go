@@ -5,8 +5,19 @@ type SearchResult struct {
 	Path          string   `json:"path"`
 	Line          uint32   `json:"line"`
 	Context       string   `json:"context"`
+	Ranges        []Range  `json:"ranges,omitempty"`
 	ContextBefore []string `json:"context_before,omitempty"`
 	ContextAfter  []string `json:"context_after,omitempty"`
+	Duplicates    []string `json:"duplicates,omitempty"`
+}
+
+// Range is the position of a match within SearchResult.Context, both in bytes
+// and in runes (Unicode code points).
+type Range struct {
+	Start     uint32 `json:"start"`
+	End       uint32 `json:"end"`
+	StartRune uint32 `json:"start_rune"`
+	EndRune   uint32 `json:"end_rune"`
 }
 
 type PerPackageResult struct {
@@ -0,0 +1,278 @@
+// Package contentstore stores the files of unpacked source packages in one
+// compressed blob per source package and reads them back, transparently
+// falling back to the blobs for files which are not unpacked.
+//
+// A blob (“<package>.dcsz”) consists of one zstd frame per file, so that any
+// file can be decompressed without decompressing the others. The frames are
+// followed by an index of \n-separated “path\toffset\tlength” records (sorted
+// by path, relative to the package directory), the little-endian uint64 offset
+// of the index and the magic string "DCSZ0001". Paths which contain a tab or
+// newline, or which start with a double quote, are stored as Go string
+// literals (see strconv.Quote).
+package contentstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/renameio/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Ext is the file name extension of blobs.
+const Ext = ".dcsz"
+
+const magic = "DCSZ0001"
+
+const trailerLen = 8 + len(magic)
+
+var (
+	encoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	decoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// BlobPath returns the path of the blob for the source package pkg (e.g.
+// “i3-wm_4.13-1”) within dir.
+func BlobPath(dir, pkg string) string {
+	return filepath.Join(dir, pkg+Ext)
+}
+
+// WritePackage atomically writes the blob for the source package pkg into dir.
+// files are the paths of the files to store, relative to srcDir (the unpacked
+// package).
+func WritePackage(dir, pkg, srcDir string, files []string) error {
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	t, err := renameio.NewPendingFile(BlobPath(dir, pkg), renameio.WithPermissions(0644))
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup()
+
+	var (
+		offset int64
+		idx    bytes.Buffer
+		frame  []byte
+	)
+	for _, fn := range sorted {
+		b, err := os.ReadFile(filepath.Join(srcDir, fn))
+		if err != nil {
+			return err
+		}
+		frame = encoder.EncodeAll(b, frame[:0])
+		if _, err := t.Write(frame); err != nil {
+			return err
+		}
+		fmt.Fprintf(&idx, "%s\t%d\t%d\n", quotePath(filepath.ToSlash(fn)), offset, len(frame))
+		offset += int64(len(frame))
+	}
+	if _, err := t.Write(idx.Bytes()); err != nil {
+		return err
+	}
+	if err := binary.Write(t, binary.LittleEndian, uint64(offset)); err != nil {
+		return err
+	}
+	if _, err := t.WriteString(magic); err != nil {
+		return err
+	}
+	return t.CloseAtomicallyReplace()
+}
+
+// quotePath returns path in the form in which it is stored in a blob index.
+func quotePath(path string) string {
+	if strings.ContainsAny(path, "\t\n") || strings.HasPrefix(path, `"`) {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+// unquotePath reverses quotePath.
+func unquotePath(path string) (string, error) {
+	if strings.HasPrefix(path, `"`) {
+		return strconv.Unquote(path)
+	}
+	return path, nil
+}
+
+type span struct {
+	offset int64
+	length int64
+}
+
+// blobIndex is the parsed index of a blob, which is valid as long as the blob
+// file was not replaced.
+type blobIndex struct {
+	modTime time.Time
+	size    int64
+	files   map[string]span
+}
+
+func readIndex(f *os.File, st os.FileInfo) (*blobIndex, error) {
+	if st.Size() < int64(trailerLen) {
+		return nil, fmt.Errorf("%s: truncated", f.Name())
+	}
+	trailer := make([]byte, trailerLen)
+	if _, err := f.ReadAt(trailer, st.Size()-int64(trailerLen)); err != nil {
+		return nil, err
+	}
+	if string(trailer[8:]) != magic {
+		return nil, fmt.Errorf("%s: not a blob (invalid magic %q)", f.Name(), trailer[8:])
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(trailer))
+	if indexOffset > st.Size()-int64(trailerLen) {
+		return nil, fmt.Errorf("%s: index offset %d out of range", f.Name(), indexOffset)
+	}
+	b := make([]byte, st.Size()-int64(trailerLen)-indexOffset)
+	if _, err := f.ReadAt(b, indexOffset); err != nil {
+		return nil, err
+	}
+	bi := &blobIndex{
+		modTime: st.ModTime(),
+		size:    st.Size(),
+		files:   make(map[string]span),
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s: malformed index record %q", f.Name(), line)
+		}
+		path, err := unquotePath(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: malformed path in index record %q", f.Name(), line)
+		}
+		offset, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		length, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		if offset < 0 || length < 0 || offset+length > indexOffset {
+			return nil, fmt.Errorf("%s: %q: frame [%d, %d) out of range", f.Name(), path, offset, offset+length)
+		}
+		bi.files[path] = span{offset: offset, length: length}
+	}
+	return bi, nil
+}
+
+// maxCachedIndexes bounds the memory used for blob indexes.
+const maxCachedIndexes = 1024
+
+// A Store reads files of unpacked source packages, e.g.
+// “i3-wm_4.13-1/i3bar/src/xcb.c”, from Unpacked or, if not present there,
+// from the blob of the source package in Compressed.
+//
+// A Store is safe for concurrent use.
+type Store struct {
+	Unpacked   string // directory containing one directory per source package
+	Compressed string // directory containing one blob per source package, or empty
+
+	mu      sync.Mutex
+	indexes map[string]*blobIndex // by source package
+}
+
+// ReadFile returns the contents of the file at path.
+func (s *Store) ReadFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(s.Unpacked, path))
+	if err == nil || !os.IsNotExist(err) || s.Compressed == "" {
+		return b, err
+	}
+	return s.readCompressed(path)
+}
+
+// Open opens the file at path for reading.
+func (s *Store) Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.Unpacked, path))
+	if err == nil {
+		return f, nil
+	}
+	if !os.IsNotExist(err) || s.Compressed == "" {
+		return nil, err
+	}
+	b, err := s.readCompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *Store) index(pkg string, f *os.File) (*blobIndex, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	bi, ok := s.indexes[pkg]
+	s.mu.Unlock()
+	if ok && bi.modTime.Equal(st.ModTime()) && bi.size == st.Size() {
+		return bi, nil
+	}
+	bi, err = readIndex(f, st)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.indexes == nil {
+		s.indexes = make(map[string]*blobIndex)
+	}
+	if len(s.indexes) >= maxCachedIndexes {
+		for evict := range s.indexes {
+			delete(s.indexes, evict)
+			break
+		}
+	}
+	s.indexes[pkg] = bi
+	return bi, nil
+}
+
+func (s *Store) readCompressed(path string) ([]byte, error) {
+	notExist := &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	pkg, rel, ok := strings.Cut(filepath.ToSlash(filepath.Clean(path)), "/")
+	if !ok || pkg == "" || pkg == ".." {
+		return nil, notExist
+	}
+	f, err := os.Open(BlobPath(s.Compressed, pkg))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notExist
+		}
+		return nil, err
+	}
+	defer f.Close()
+	bi, err := s.index(pkg, f)
+	if err != nil {
+		return nil, err
+	}
+	sp, ok := bi.files[rel]
+	if !ok {
+		return nil, notExist
+	}
+	frame := make([]byte, sp.length)
+	if _, err := f.ReadAt(frame, sp.offset); err != nil {
+		return nil, err
+	}
+	b, err := decoder.DecodeAll(frame, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
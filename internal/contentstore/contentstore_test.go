@@ -0,0 +1,85 @@
+package contentstore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	unpacked := filepath.Join(tmpDir, "src")
+	compressed := filepath.Join(tmpDir, "store")
+
+	pkgDir := filepath.Join(tmpDir, "unpack", "i3-wm_4.13-1")
+	files := map[string]string{
+		"i3bar/src/xcb.c": strings.Repeat("xcb_create_window();\n", 100),
+		"debian/control":  "Source: i3-wm\n",
+		"empty":           "",
+		// names which need quoting in the blob index:
+		"tab\tname.c": "tab\n",
+		"new\nline.c": "newline\n",
+		`"quoted".c`:  "quoted\n",
+	}
+	var names []string
+	for fn, content := range files {
+		path := filepath.Join(pkgDir, fn)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, fn)
+	}
+	if err := WritePackage(compressed, "i3-wm_4.13-1", pkgDir, names); err != nil {
+		t.Fatal(err)
+	}
+
+	// A plain file takes precedence over the blob:
+	plain := filepath.Join(unpacked, "i3-wm_4.13-1", "debian", "control")
+	if err := os.MkdirAll(filepath.Dir(plain), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plain, []byte("Source: plain\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files["debian/control"] = "Source: plain\n"
+
+	s := &Store{Unpacked: unpacked, Compressed: compressed}
+	for fn, want := range files {
+		path := "i3-wm_4.13-1/" + fn
+		got, err := s.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", path, err)
+		}
+		if string(got) != want {
+			t.Errorf("ReadFile(%s) = %q, want %q", path, got, want)
+		}
+
+		r, err := s.Open(path)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", path, err)
+		}
+		got, err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("Open(%s): read %q, want %q", path, got, want)
+		}
+	}
+
+	for _, path := range []string{
+		"i3-wm_4.13-1/nonexistent",
+		"zlib_1.2.13-1/zlib.h",
+		"../etc/passwd",
+	} {
+		if _, err := s.ReadFile(path); !os.IsNotExist(err) {
+			t.Errorf("ReadFile(%s) = %v, want a not-exist error", path, err)
+		}
+	}
+}
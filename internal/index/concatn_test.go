@@ -70,10 +70,6 @@ func result(docid uint32, filename string, pos uint32) searchResult {
 func searchIndex(t *testing.T, indexDir string, query string) []searchResult {
 	t.Helper()
 
-	if len(query) < 4 {
-		t.Fatalf("Query must be at least 4 characters for positional search, got %q", query)
-	}
-
 	idx, err := Open(indexDir)
 	if err != nil {
 		t.Fatalf("Failed to open index: %v", err)
@@ -169,6 +165,91 @@ func TestConcatN(t *testing.T) {
 	}
 }
 
+func TestConcatNDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	const shared = "func Shared() {\n\treturn\n}\n"
+	src1Dir := filepath.Join(tmpDir, "src1")
+	writeFiles(t, src1Dir, map[string]string{
+		"a.go": shared,
+		"b.go": "func First() {}\n",
+	})
+	createIndex(t, src1Dir, src1Dir+".idx")
+
+	// The same file in a different shard, surrounded by unique files, so that
+	// the docids after the dropped copy need to be renumbered:
+	src2Dir := filepath.Join(tmpDir, "src2")
+	writeFiles(t, src2Dir, map[string]string{
+		"c.go": "func Second() {}\n",
+		"d.go": shared,
+		"e.go": "func Third() { Shared() }\n",
+	})
+	createIndex(t, src2Dir, src2Dir+".idx")
+
+	dest := filepath.Join(tmpDir, "merged")
+	if err := ConcatN(dest, []string{src1Dir + ".idx", src2Dir + ".idx"}); err != nil {
+		t.Fatalf("ConcatN failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []searchResult
+	}{
+		{
+			query: "Shared()",
+			want: []searchResult{
+				result(0, "a.go", 5),
+				result(3, "e.go", 15),
+			},
+		},
+
+		{
+			query: "func ",
+			want: []searchResult{
+				result(0, "a.go", 0),
+				result(1, "b.go", 0),
+				result(2, "c.go", 0),
+				result(3, "e.go", 0),
+			},
+		},
+	} {
+		results := searchIndex(t, dest, tt.query)
+		if diff := cmp.Diff(tt.want, results); diff != "" {
+			t.Errorf("searchIndex(%q): unexpected results: diff (-want +got):\n%s", tt.query, diff)
+		}
+	}
+
+	idx, err := Open(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.Verify(); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	aliases, err := idx.Aliases(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"d.go"}, aliases); diff != "" {
+		t.Errorf("Aliases(0): unexpected diff (-want +got):\n%s", diff)
+	}
+	defs, err := idx.Definitions("Shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].Docid != 0 {
+		t.Errorf("Definitions(Shared) = %+v, want only the definition in docid 0", defs)
+	}
+	defs, err = idx.Definitions("Third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].Docid != 3 {
+		t.Errorf("Definitions(Third) = %+v, want only the definition in docid 3", defs)
+	}
+}
+
 func TestConcatNEmpty(t *testing.T) {
 	tmpDir := t.TempDir()
 
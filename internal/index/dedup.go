@@ -0,0 +1,422 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/Debian/dcs/internal/mmap"
+)
+
+// Files with identical contents (e.g. vendored copies of zlib or jquery) are
+// indexed only once: Writer.AddFile gives any further copy the docid of the
+// first copy and records its name as an alias of that docid. Likewise, ConcatN
+// drops documents which are copies of a document of an earlier (or the same)
+// index, see dedupDocids.
+//
+// The docid.hash file contains the content hash (the first HashSize bytes of
+// the SHA-256) of each docid, which ConcatN uses to find copies, and which
+// recognizes copies in different indexes (e.g. a delta index and its base) at
+// query time. Documents which could not be indexed have an all-zero hash.
+//
+// The docid.aliases file lists the aliases as “docid\tname” records (see
+// records.go), sorted by docid.
+//
+// Both files are optional: indexes without them contain no duplicates.
+const (
+	hashFile    = "docid.hash"
+	aliasesFile = "docid.aliases"
+)
+
+// HashSize is the size of the content hash of a document.
+const HashSize = 16
+
+type contentHash [HashSize]byte
+
+type alias struct {
+	docid uint32
+	name  string
+}
+
+func writeHashes(dir string, hashes []contentHash) error {
+	f, err := os.Create(filepath.Join(dir, hashFile))
+	if err != nil {
+		return err
+	}
+	cw := newCountingWriter(f)
+	for _, h := range hashes {
+		if _, err := cw.Write(h[:]); err != nil {
+			return err
+		}
+	}
+	return cw.Close()
+}
+
+func writeAliases(dir string, aliases []alias) error {
+	sort.SliceStable(aliases, func(i, j int) bool { return aliases[i].docid < aliases[j].docid })
+	rw, err := newRecordWriter(filepath.Join(dir, aliasesFile))
+	if err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		if err := rw.add("%d\t%s", a.docid, a.name); err != nil {
+			return err
+		}
+	}
+	return rw.Close()
+}
+
+// openHashes returns nil if the index in dir has no docid.hash file.
+func openHashes(dir string) (*mmap.File, error) {
+	f, err := mmap.Open(filepath.Join(dir, hashFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// aliasReader is safe for concurrent use.
+type aliasReader struct {
+	*recordReader
+}
+
+// newAliasReader returns nil if the index in dir has no docid.aliases file.
+func newAliasReader(dir string) (*aliasReader, error) {
+	rr, err := openRecords(filepath.Join(dir, aliasesFile), aliasesFile)
+	if rr == nil || err != nil {
+		return nil, err
+	}
+	return &aliasReader{rr}, nil
+}
+
+// alias returns the n-th alias.
+func (ar *aliasReader) alias(n int) (alias, error) {
+	r := ar.record(n)
+	idx := bytes.IndexByte(r, '\t')
+	if idx == -1 {
+		return alias{}, fmt.Errorf("malformed alias record %q", r)
+	}
+	docid, err := strconv.ParseUint(string(r[:idx]), 10, 32)
+	if err != nil {
+		return alias{}, err
+	}
+	return alias{docid: uint32(docid), name: string(r[idx+1:])}, nil
+}
+
+func (ar *aliasReader) lookup(docid uint32) ([]string, error) {
+	if ar == nil {
+		return nil, nil
+	}
+	var err error
+	n := sort.Search(ar.count, func(i int) bool {
+		a, aerr := ar.alias(i)
+		if aerr != nil {
+			err = aerr
+			return true
+		}
+		return a.docid >= docid
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for ; n < ar.count; n++ {
+		a, err := ar.alias(n)
+		if err != nil {
+			return nil, err
+		}
+		if a.docid != docid {
+			break
+		}
+		names = append(names, a.name)
+	}
+	return names, nil
+}
+
+// ContentHash returns the content hash of docid, or nil if the index does not
+// store content hashes (or the document could not be indexed).
+func (i *Index) ContentHash(docid uint32) []byte {
+	if base := uint32(i.DocidMap.Count); i.delta != nil && docid >= base {
+		return i.delta.ContentHash(docid - base)
+	}
+	if i.hashes == nil {
+		return nil
+	}
+	offset := int(docid) * HashSize
+	if offset+HashSize > len(i.hashes.Data) {
+		return nil
+	}
+	h := i.hashes.Data[offset : offset+HashSize]
+	if bytes.Equal(h, make([]byte, HashSize)) {
+		return nil
+	}
+	return h
+}
+
+// Aliases returns the names of the other files whose contents are identical to
+// docid, i.e. which were indexed as docid.
+func (i *Index) Aliases(docid uint32) ([]string, error) {
+	if base := uint32(i.DocidMap.Count); i.delta != nil && docid >= base {
+		return i.delta.Aliases(docid - base)
+	}
+	if i.deletedNames == nil {
+		return i.aliases.lookup(docid)
+	}
+	name, err := i.DocidMap.Lookup(docid)
+	if err != nil {
+		return nil, err
+	}
+	_, aliases, err := i.names(docid, name)
+	return aliases, err
+}
+
+// A docidRemap maps the docids of one of the indexes merged by ConcatN to the
+// docids of the merged index.
+type docidRemap struct {
+	base uint32 // merged docid of docid 0, if docids is nil
+
+	// Only set if documents of the index are dropped in favor of an earlier
+	// copy: the merged docid of each document (of its first copy, for dropped
+	// documents) and whether it was dropped.
+	docids  []uint32
+	dropped []bool
+}
+
+// lookup returns the merged docid of docid and whether the document is kept,
+// as opposed to dropped in favor of the document with the merged docid.
+func (r *docidRemap) lookup(docid uint32) (uint32, bool) {
+	if r.docids == nil {
+		return r.base + docid, true
+	}
+	return r.docids[docid], !r.dropped[docid]
+}
+
+// dedupDocids determines how ConcatN maps the docids of srcdirs: documents
+// whose content hash equals that of an earlier document (of the same or an
+// earlier index) are dropped in favor of that document, and the remaining
+// documents are numbered consecutively.
+func dedupDocids(srcdirs []string) ([]docidRemap, error) {
+	// Sorting the hashes needs less memory than a map from hash to docid,
+	// which matters for merging all of Debian.
+	type hashedDoc struct {
+		hash  contentHash
+		docid uint32 // in the concatenation of all indexes
+	}
+	var docs []hashedDoc
+	bases := make([]uint32, len(srcdirs)+1)
+	for idx, dir := range srcdirs {
+		dr, err := newDocidReader(dir)
+		if err != nil {
+			return nil, err
+		}
+		n := dr.Count
+		dr.Close()
+		bases[idx+1] = bases[idx] + uint32(n)
+
+		hashes, err := openHashes(dir)
+		if err != nil {
+			return nil, err
+		}
+		if hashes == nil {
+			continue // indexes without content hashes contain no copies
+		}
+		if got, want := len(hashes.Data), n*HashSize; got != want {
+			hashes.Close()
+			return nil, fmt.Errorf("%s: %s is %d bytes, but %d documents need %d bytes", dir, hashFile, got, n, want)
+		}
+		var zero contentHash
+		for docid := 0; docid < n; docid++ {
+			var h contentHash
+			copy(h[:], hashes.Data[docid*HashSize:])
+			if h != zero {
+				docs = append(docs, hashedDoc{hash: h, docid: bases[idx] + uint32(docid)})
+			}
+		}
+		hashes.Close()
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if c := bytes.Compare(docs[i].hash[:], docs[j].hash[:]); c != 0 {
+			return c < 0
+		}
+		return docs[i].docid < docs[j].docid
+	})
+
+	total := bases[len(srcdirs)]
+	var copyOf []uint32 // docid of the first copy, allocated on the first copy
+	for i := 1; i < len(docs); i++ {
+		if docs[i].hash != docs[i-1].hash {
+			continue
+		}
+		if copyOf == nil {
+			copyOf = make([]uint32, total)
+			for docid := range copyOf {
+				copyOf[docid] = uint32(docid)
+			}
+		}
+		copyOf[docs[i].docid] = copyOf[docs[i-1].docid]
+	}
+	docs = nil
+
+	remaps := make([]docidRemap, len(srcdirs))
+	if copyOf == nil {
+		for idx := range srcdirs {
+			remaps[idx] = docidRemap{base: bases[idx]}
+		}
+		return remaps, nil
+	}
+	merged := make([]uint32, total)
+	dropped := make([]bool, total)
+	var next uint32
+	for docid, first := range copyOf {
+		if first != uint32(docid) {
+			// first < docid, so its merged docid is already known.
+			merged[docid] = merged[first]
+			dropped[docid] = true
+			continue
+		}
+		merged[docid] = next
+		next++
+	}
+	for idx := range srcdirs {
+		start, end := bases[idx], bases[idx+1]
+		if !slices.Contains(dropped[start:end], true) {
+			remaps[idx] = docidRemap{base: merged[start]}
+			if start == end {
+				remaps[idx].base = next
+			}
+			continue
+		}
+		remaps[idx] = docidRemap{
+			docids:  merged[start:end],
+			dropped: dropped[start:end],
+		}
+	}
+	return remaps, nil
+}
+
+// mergeHashes concatenates the docid.hash files of srcdirs into destdir,
+// omitting documents which are dropped according to remaps. Indexes without
+// content hashes contribute all-zero hashes.
+func mergeHashes(destdir string, srcdirs []string, remaps []docidRemap) error {
+	f, err := os.Create(filepath.Join(destdir, hashFile))
+	if err != nil {
+		return err
+	}
+	cw := newCountingWriter(f)
+	for idx, dir := range srcdirs {
+		hashes, err := openHashes(dir)
+		if err != nil {
+			return err
+		}
+		if hashes != nil {
+			for docid := 0; docid*HashSize < len(hashes.Data); docid++ {
+				if _, kept := remaps[idx].lookup(uint32(docid)); !kept {
+					continue
+				}
+				if _, err := cw.Write(hashes.Data[docid*HashSize : (docid+1)*HashSize]); err != nil {
+					hashes.Close()
+					return err
+				}
+			}
+			hashes.Close()
+			continue
+		}
+		dr, err := newDocidReader(dir)
+		if err != nil {
+			return err
+		}
+		n := dr.Count
+		dr.Close()
+		if _, err := cw.Write(make([]byte, n*HashSize)); err != nil {
+			return err
+		}
+	}
+	return cw.Close()
+}
+
+// mergeAliases merges the docid.aliases files of srcdirs into destdir, mapping
+// their docids according to remaps. The names of dropped documents become
+// aliases of their first copy.
+func mergeAliases(destdir string, srcdirs []string, remaps []docidRemap) error {
+	var aliases []alias
+	for idx, dir := range srcdirs {
+		remap := &remaps[idx]
+		if remap.docids != nil {
+			dr, err := newDocidReader(dir)
+			if err != nil {
+				return err
+			}
+			err = dr.each(func(docid uint32, name string) error {
+				if merged, kept := remap.lookup(docid); !kept {
+					aliases = append(aliases, alias{docid: merged, name: name})
+				}
+				return nil
+			})
+			dr.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %v", dir, err)
+			}
+		}
+
+		ar, err := newAliasReader(dir)
+		if err != nil {
+			return err
+		}
+		if ar == nil {
+			continue
+		}
+		for n := 0; n < ar.count; n++ {
+			a, err := ar.alias(n)
+			if err != nil {
+				ar.Close()
+				return fmt.Errorf("%s: %v", dir, err)
+			}
+			// Aliases of dropped documents become aliases of the first copy.
+			a.docid, _ = remap.lookup(a.docid)
+			aliases = append(aliases, a)
+		}
+		ar.Close()
+	}
+	return writeAliases(destdir, aliases)
+}
+
+// verifyDedup verifies that the docid.hash file (if any) contains one hash per
+// document and that the docid.aliases file (if any) is sorted and only refers
+// to documents of the index.
+func (i *Index) verifyDedup() error {
+	if i.hashes != nil {
+		if got, want := len(i.hashes.Data), i.DocidMap.Count*HashSize; got != want {
+			return corrupt(hashFile, NoTrigram, 0, "size is %d bytes, but %d documents need %d bytes", got, i.DocidMap.Count, want)
+		}
+	}
+	ar := i.aliases
+	if ar == nil {
+		return nil
+	}
+	if err := ar.verify(); err != nil {
+		return err
+	}
+	var prev uint32
+	for n := 0; n < ar.count; n++ {
+		offset := int64(ar.offset(n))
+		a, err := ar.alias(n)
+		if err != nil {
+			return corrupt(aliasesFile, NoTrigram, offset, "%v", err)
+		}
+		if a.docid >= uint32(i.DocidMap.Count) {
+			return corrupt(aliasesFile, NoTrigram, offset, "docid %d outside of docid map [0, %d)", a.docid, i.DocidMap.Count)
+		}
+		if a.docid < prev {
+			return corrupt(aliasesFile, NoTrigram, offset, "docid %d sorts before its predecessor %d", a.docid, prev)
+		}
+		prev = a.docid
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeduplication(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	const zlib = "int inflate(z_streamp strm, int flush) {\n\treturn Z_OK;\n}\n"
+	src1 := filepath.Join(tmpDir, "src1")
+	writeFiles(t, src1, map[string]string{
+		"a.c": zlib,
+		"b.c": "int main() {\n\treturn inflate(0, 0);\n}\n",
+		"c.c": zlib,
+	})
+	idx1 := filepath.Join(tmpDir, "idx1")
+	createIndex(t, src1, idx1)
+
+	src2 := filepath.Join(tmpDir, "src2")
+	writeFiles(t, src2, map[string]string{
+		"d.c": zlib,
+		"e.c": zlib,
+	})
+	idx2 := filepath.Join(tmpDir, "idx2")
+	createIndex(t, src2, idx2)
+
+	merged := filepath.Join(tmpDir, "merged")
+	if err := ConcatN(merged, []string{idx1, idx2}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := Open(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.Verify(); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	// The copies in idx2 are dropped in favor of the copy in idx1:
+	if got, want := idx.DocidMap.Count, 2; got != want {
+		t.Fatalf("DocidMap.Count = %d, want %d", got, want)
+	}
+
+	var files []string
+	if err := idx.Files(func(docid uint32, name string) error {
+		files = append(files, fmt.Sprintf("%d:%s", docid, name))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"0:a.c", "0:c.c", "0:d.c", "0:e.c", "1:b.c"}, files); diff != "" {
+		t.Errorf("Files: unexpected diff (-want +got):\n%s", diff)
+	}
+
+	for _, tt := range []struct {
+		docid uint32
+		want  []string
+	}{
+		{docid: 0, want: []string{"c.c", "d.c", "e.c"}},
+		{docid: 1, want: nil},
+	} {
+		got, err := idx.Aliases(tt.docid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("Aliases(%d): unexpected diff (-want +got):\n%s", tt.docid, diff)
+		}
+	}
+
+	if h0, h1 := idx.ContentHash(0), idx.ContentHash(1); h0 == nil || string(h0) == string(h1) {
+		t.Errorf("ContentHash(0) = %x, ContentHash(1) = %x, want different non-nil hashes", h0, h1)
+	}
+}
@@ -0,0 +1,258 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A delta index is a regular index directory which contains the documents that
+// were added after its base index was created, plus these additional files:
+//
+// The tombstones file lists the (sorted, little-endian uint32) docids of the
+// base index which were deleted, e.g. because their package was garbage
+// collected or superseded by a document in the delta index.
+//
+// The deleted.names file lists the \n-separated names of deleted documents of
+// the base index whose docid remains because other copies of the document
+// (see Aliases) were not deleted. The first remaining copy takes the place of
+// a deleted document name. Delta indexes written by older versions lack the
+// file.
+//
+// The base file contains the name of the base index directory, so that a delta
+// index is never used on top of a base index it was not created for.
+const (
+	tombstonesFile   = "tombstones"
+	deletedNamesFile = "deleted.names"
+	baseFile         = "base"
+)
+
+// WriteDelta writes the tombstones (docids of the base index which should no
+// longer be returned), the deletedNames (names of base index documents which
+// should no longer be returned, while other copies of their docid remain) and
+// the name of the base index directory into the delta index directory dir.
+func WriteDelta(dir, base string, tombstones []uint32, deletedNames []string) error {
+	sorted := append([]uint32(nil), tombstones...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, sorted); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, tombstonesFile), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	var names bytes.Buffer
+	for _, name := range deletedNames {
+		names.WriteString(name + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, deletedNamesFile), names.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, baseFile), []byte(filepath.Base(base)+"\n"), 0644)
+}
+
+func readTombstones(dir string) ([]uint32, error) {
+	b, err := os.ReadFile(filepath.Join(dir, tombstonesFile))
+	if err != nil {
+		return nil, err
+	}
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("%s: size %d is not a multiple of 4", tombstonesFile, len(b))
+	}
+	tombstones := make([]uint32, len(b)/4)
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, tombstones); err != nil {
+		return nil, err
+	}
+	return tombstones, nil
+}
+
+func readDeletedNames(dir string) (map[string]bool, error) {
+	b, err := os.ReadFile(filepath.Join(dir, deletedNamesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// OpenDelta opens the index in dir together with the delta index in deltaDir
+// (see WriteDelta). Queries on the resulting Index consult both indexes:
+// documents of the delta index use docids starting at DocidMap.Count, and
+// documents of the base index which were deleted are omitted.
+func OpenDelta(dir, deltaDir string) (*Index, error) {
+	b, err := os.ReadFile(filepath.Join(deltaDir, baseFile))
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	if got, want := strings.TrimSpace(string(b)), filepath.Base(resolved); got != want {
+		return nil, fmt.Errorf("delta index %s was created for %s, not %s", deltaDir, got, want)
+	}
+	tombstones, err := readTombstones(deltaDir)
+	if err != nil {
+		return nil, err
+	}
+	deletedNames, err := readDeletedNames(deltaDir)
+	if err != nil {
+		return nil, err
+	}
+	i, err := Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if i.delta, err = Open(deltaDir); err != nil {
+		i.Close()
+		return nil, err
+	}
+	i.tombstones = tombstones
+	i.deletedNames = deletedNames
+	return i, nil
+}
+
+// Lookup returns the file name of docid, which may refer to a document of the
+// delta index.
+func (i *Index) Lookup(docid uint32) (string, error) {
+	if base := uint32(i.DocidMap.Count); i.delta != nil && docid >= base {
+		return i.delta.DocidMap.Lookup(docid - base)
+	}
+	name, err := i.DocidMap.Lookup(docid)
+	if err != nil || !i.deletedNames[name] {
+		return name, err
+	}
+	name, _, err = i.names(docid, name)
+	return name, err
+}
+
+// names returns the remaining names of docid of the base index, whose
+// document is called name, without the names which the delta index deleted:
+// the name to use for docid and its aliases.
+func (i *Index) names(docid uint32, name string) (string, []string, error) {
+	aliases, err := i.aliases.lookup(docid)
+	if err != nil || i.deletedNames == nil {
+		return name, aliases, err
+	}
+	remaining := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		if !i.deletedNames[alias] {
+			remaining = append(remaining, alias)
+		}
+	}
+	if i.deletedNames[name] && len(remaining) > 0 {
+		return remaining[0], remaining[1:], nil
+	}
+	return name, remaining, nil
+}
+
+// Files calls fn for each document (including those of the delta index) which
+// was not deleted, in docid order. Aliases (see Aliases) are passed to fn with
+// the docid of the document they duplicate, after the name of the document.
+func (i *Index) Files(fn func(docid uint32, name string) error) error {
+	var next int // next alias
+	if err := i.DocidMap.each(func(docid uint32, name string) error {
+		if i.deleted(docid) {
+			return nil
+		}
+		if !i.deletedNames[name] {
+			if err := fn(docid, name); err != nil {
+				return err
+			}
+		}
+		for ; i.aliases != nil && next < i.aliases.count; next++ {
+			a, err := i.aliases.alias(next)
+			if err != nil {
+				return err
+			}
+			if a.docid > docid {
+				break
+			}
+			if a.docid == docid && !i.deletedNames[a.name] {
+				if err := fn(docid, a.name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if i.delta == nil {
+		return nil
+	}
+	base := uint32(i.DocidMap.Count)
+	return i.delta.Files(func(docid uint32, name string) error {
+		return fn(base+docid, name)
+	})
+}
+
+func (i *Index) deleted(docid uint32) bool {
+	n := sort.Search(len(i.tombstones), func(j int) bool { return i.tombstones[j] >= docid })
+	return n < len(i.tombstones) && i.tombstones[n] == docid
+}
+
+// withoutTombstones removes the deleted docids from docids (which must be
+// sorted), re-using its storage.
+func (i *Index) withoutTombstones(docids []uint32) []uint32 {
+	if len(i.tombstones) == 0 {
+		return docids
+	}
+	filtered := docids[:0]
+	t := i.tombstones
+	for _, docid := range docids {
+		for len(t) > 0 && t[0] < docid {
+			t = t[1:]
+		}
+		if len(t) > 0 && t[0] == docid {
+			continue
+		}
+		filtered = append(filtered, docid)
+	}
+	return filtered
+}
+
+// withDelta calls query on the base index and (if present) the delta index and
+// returns the combined matches.
+func (i *Index) withDelta(query string, fn func(*Index, string) ([]Match, error)) ([]Match, error) {
+	matches, err := fn(i, query)
+	if i.delta == nil {
+		return matches, err
+	}
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, err
+	}
+	if len(i.tombstones) > 0 {
+		filtered := matches[:0]
+		for _, m := range matches {
+			if !i.deleted(m.Docid) {
+				filtered = append(filtered, m)
+			}
+		}
+		matches = filtered
+	}
+	delta, err := fn(i.delta, query)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, err
+	}
+	base := uint32(i.DocidMap.Count)
+	for _, m := range delta {
+		m.Docid += base
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
@@ -0,0 +1,148 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp/syntax"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenDelta(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	baseSrc := filepath.Join(tmpDir, "base")
+	writeFiles(t, baseSrc, map[string]string{
+		"file1.txt": "abc def ghi",
+		"file2.txt": "abc jkl mno",
+	})
+	baseDir := filepath.Join(tmpDir, "full.1")
+	createIndex(t, baseSrc, baseDir)
+
+	deltaSrc := filepath.Join(tmpDir, "delta")
+	writeFiles(t, deltaSrc, map[string]string{
+		"file1.txt": "xyz abc def",
+	})
+	deltaDir := filepath.Join(tmpDir, "delta.1")
+	createIndex(t, deltaSrc, deltaDir)
+	// file1.txt was superseded by the version in the delta index:
+	if err := WriteDelta(deltaDir, baseDir, []uint32{0}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := OpenDelta(baseDir, deltaDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	re, err := syntax.Parse("abc", syntax.Perl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]uint32{1, 2}, idx.PostingQuery(RegexpQuery(re))); diff != "" {
+		t.Errorf("PostingQuery: unexpected diff (-want +got):\n%s", diff)
+	}
+
+	matches, err := idx.QueryPositional("abc d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]Match{{Docid: 2, Position: 4}}, matches); diff != "" {
+		t.Errorf("QueryPositional: unexpected diff (-want +got):\n%s", diff)
+	}
+
+	for docid, want := range []string{"file1.txt", "file2.txt", "file1.txt"} {
+		got, err := idx.Lookup(uint32(docid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Lookup(%d) = %q, want %q", docid, got, want)
+		}
+	}
+
+	var files []string
+	if err := idx.Files(func(docid uint32, name string) error {
+		files = append(files, fmt.Sprintf("%d:%s", docid, name))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1:file2.txt", "2:file1.txt"}, files); diff != "" {
+		t.Errorf("Files: unexpected diff (-want +got):\n%s", diff)
+	}
+
+	otherBase := filepath.Join(tmpDir, "full.2")
+	createIndex(t, baseSrc, otherBase)
+	if _, err := OpenDelta(otherBase, deltaDir); err == nil {
+		t.Errorf("OpenDelta(%s, %s) unexpectedly succeeded", otherBase, deltaDir)
+	}
+}
+
+func TestOpenDeltaDeletedNames(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// d.go is indexed as an alias of a.go:
+	const shared = "func Shared() {}\n"
+	var srcdirs []string
+	for idx, files := range []map[string]string{
+		{"a.go": shared, "b.go": "func First() {}\n"},
+		{"d.go": shared},
+	} {
+		src := filepath.Join(tmpDir, fmt.Sprintf("src%d", idx))
+		writeFiles(t, src, files)
+		createIndex(t, src, src+".idx")
+		srcdirs = append(srcdirs, src+".idx")
+	}
+	baseDir := filepath.Join(tmpDir, "full.1")
+	if err := ConcatN(baseDir, srcdirs); err != nil {
+		t.Fatal(err)
+	}
+
+	deltaSrc := filepath.Join(tmpDir, "delta")
+	writeFiles(t, deltaSrc, map[string]string{
+		"f.go": "func Fourth() {}\n",
+	})
+	deltaDir := filepath.Join(tmpDir, "delta.1")
+	createIndex(t, deltaSrc, deltaDir)
+
+	for _, tt := range []struct {
+		deleted string
+		want    string // name of docid 0
+		files   []string
+	}{
+		// The remaining copy takes the place of the deleted document:
+		{deleted: "a.go", want: "d.go", files: []string{"0:d.go", "1:b.go", "2:f.go"}},
+		{deleted: "d.go", want: "a.go", files: []string{"0:a.go", "1:b.go", "2:f.go"}},
+	} {
+		t.Run(tt.deleted, func(t *testing.T) {
+			if err := WriteDelta(deltaDir, baseDir, nil, []string{tt.deleted}); err != nil {
+				t.Fatal(err)
+			}
+			idx, err := OpenDelta(baseDir, deltaDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idx.Close()
+
+			if got, err := idx.Lookup(0); err != nil || got != tt.want {
+				t.Errorf("Lookup(0) = %q, %v, want %q", got, err, tt.want)
+			}
+			if got, err := idx.Aliases(0); err != nil || len(got) > 0 {
+				t.Errorf("Aliases(0) = %q, %v, want none", got, err)
+			}
+			var files []string
+			if err := idx.Files(func(docid uint32, name string) error {
+				files = append(files, fmt.Sprintf("%d:%s", docid, name))
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.files, files); diff != "" {
+				t.Errorf("Files: unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package index
+
+import (
+	"errors"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Fragment is a literal which occurs in every match of a regular expression.
+type Fragment struct {
+	Literal  string
+	FoldCase bool
+}
+
+// RequiredFragments returns the literal fragments which every match of re
+// contains, in the order in which they occur within the match. Only fragments
+// which can be looked up exactly in the positional index are returned, i.e.
+// fragments of at least 3 bytes.
+func RequiredFragments(re *syntax.Regexp) []Fragment {
+	var (
+		fragments []Fragment
+		cur       []rune
+		curFold   bool
+	)
+	flush := func() {
+		literal := string(cur)
+		cur = nil
+		if len(literal) < 3 {
+			return // too short for an exact lookup, see QueryPositional
+		}
+		if strings.Contains(literal, "   ") {
+			return // "   " is not stored in the positional index
+		}
+		fragments = append(fragments, Fragment{
+			Literal:  literal,
+			FoldCase: curFold,
+		})
+	}
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpConcat:
+			for _, sub := range re.Sub {
+				walk(sub)
+			}
+
+		case syntax.OpCapture:
+			walk(re.Sub[0])
+
+		case syntax.OpLiteral:
+			fold := re.Flags&syntax.FoldCase != 0
+			if fold {
+				// The positional index only knows about ASCII case variants,
+				// see QueryPositionalFold.
+				for _, r := range re.Rune {
+					if r >= utf8.RuneSelf {
+						flush()
+						return
+					}
+				}
+			}
+			if len(cur) > 0 && fold != curFold {
+				flush()
+			}
+			cur = append(cur, re.Rune...)
+			curFold = fold
+
+		default:
+			// Anything else (alternations, repetitions, character classes,
+			// assertions, …) ends the current fragment.
+			flush()
+		}
+	}
+	walk(re.Simplify())
+	flush()
+	return fragments
+}
+
+// FilterFragments returns the subset of docids (which must be sorted) in which
+// all fragments occur in order within the same line. Line boundaries are not
+// stored in the index, so the maximum line length of indexed files is used to
+// bound the distance between the fragments instead.
+//
+// The result may contain false positives, but never misses a document in
+// which the fragments occur.
+func (i *Index) FilterFragments(docids []uint32, fragments []Fragment) ([]uint32, error) {
+	if len(fragments) == 0 {
+		return docids, nil
+	}
+	positions := make([][]Match, len(fragments))
+	for idx, f := range fragments {
+		matches, err := i.fragmentMatches(f)
+		if err != nil || matches == nil {
+			return nil, err // fragment does not occur in any document
+		}
+		positions[idx] = matches
+	}
+
+	var filtered []uint32
+	inDoc := make([][]uint32, len(fragments))
+	next := make([]int, len(fragments))
+	for _, docid := range docids {
+		found := true
+		for idx, matches := range positions {
+			inDoc[idx] = positionsIn(inDoc[idx][:0], matches, &next[idx], docid)
+			if len(inDoc[idx]) == 0 {
+				found = false
+			}
+		}
+		if found && fragmentsInOrder(inDoc, fragments) {
+			filtered = append(filtered, docid)
+		}
+	}
+	return filtered, nil
+}
+
+// fragmentMatches returns the occurrences of f, or nil if f does not occur in
+// any document.
+func (i *Index) fragmentMatches(f Fragment) ([]Match, error) {
+	queryPositional := i.QueryPositional
+	if f.FoldCase {
+		queryPositional = i.QueryPositionalFold
+	}
+	matches, err := queryPositional(f.Literal)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return matches, nil
+}
+
+// positionsIn appends the positions of the matches in docid to dst, starting
+// at matches[*next]. Both the docids passed to consecutive calls and matches
+// are sorted by docid, so *next is advanced to continue where the previous call
+// left off.
+func positionsIn(dst []uint32, matches []Match, next *int, docid uint32) []uint32 {
+	j := *next
+	for j < len(matches) && matches[j].Docid < docid {
+		j++
+	}
+	for ; j < len(matches) && matches[j].Docid == docid; j++ {
+		dst = append(dst, matches[j].Position)
+	}
+	*next = j
+	return dst
+}
+
+// fragmentsInOrder reports whether there is an occurrence of each fragment
+// (positions[idx] contains the sorted positions of fragments[idx]) such that
+// all fragments occur in order and within maxLineLen bytes.
+func fragmentsInOrder(positions [][]uint32, fragments []Fragment) bool {
+	for _, start := range positions[0] {
+		end := start + uint32(len(fragments[0].Literal))
+		for idx := 1; idx < len(positions); idx++ {
+			pos := positions[idx]
+			j := sort.Search(len(pos), func(j int) bool { return pos[j] >= end })
+			if j == len(pos) {
+				// Later start positions can only result in later end
+				// positions, so there is no need to look any further.
+				return false
+			}
+			end = pos[j] + uint32(len(fragments[idx].Literal))
+		}
+		if end-start <= maxLineLen {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"path/filepath"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequiredFragments(t *testing.T) {
+	for _, tt := range []struct {
+		re   string
+		want []Fragment
+	}{
+		{`foo\(\s*bar`, []Fragment{{Literal: "foo("}, {Literal: "bar"}}},
+		{`Foo.*Bar`, []Fragment{{Literal: "Foo"}, {Literal: "Bar"}}},
+		{`(foo)(bar)`, []Fragment{{Literal: "foobar"}}},
+		{`foo(bar)?baz`, []Fragment{{Literal: "foo"}, {Literal: "baz"}}},
+		{`(?i)foo.*bar`, []Fragment{{Literal: "FOO", FoldCase: true}, {Literal: "BAR", FoldCase: true}}},
+		{`foo(?i:bar)`, []Fragment{{Literal: "foo"}, {Literal: "BAR", FoldCase: true}}},
+		{`ab.*cd`, nil},
+		{`foo|bar`, nil},
+		{`if    \(x`, nil},
+	} {
+		t.Run(tt.re, func(t *testing.T) {
+			re, err := syntax.Parse(tt.re, syntax.Perl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := RequiredFragments(re)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("RequiredFragments(%q): unexpected diff (-want +got):\n%s", tt.re, diff)
+			}
+		})
+	}
+}
+
+func TestFilterFragments(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "x := foo(bar)\n",
+		"file2.txt": "x := bar(foo)\n",
+		"file3.txt": "foo(\n" + strings.Repeat(strings.Repeat("y", maxLineLen*3/4)+"\n", 2) + "bar\n",
+		"file4.txt": "foo( bar\n",
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	idx, err := Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	all := []uint32{0, 1, 2, 3}
+	for _, tt := range []struct {
+		re   string
+		want []uint32
+	}{
+		{`foo\(\s*bar`, []uint32{0, 3}},
+		{`bar.*foo`, []uint32{1}},
+		{`foo.*xyz`, nil},
+	} {
+		t.Run(tt.re, func(t *testing.T) {
+			re, err := syntax.Parse(tt.re, syntax.Perl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := idx.FilterFragments(all, RequiredFragments(re))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FilterFragments(%q): unexpected diff (-want +got):\n%s", tt.re, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package index
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// IdentifierWords returns the (lower case) words of the identifier ident, e.g.
+// “get”, “user” and “name” for getUserName, GetUserName, get_user_name and
+// get-user-name. Runs of upper case letters are one word, except for the last
+// letter if it starts a capitalized word (“HTTPServer” is “http” and
+// “server”). Digits belong to the preceding word.
+func IdentifierWords(ident string) []string {
+	var (
+		words []string
+		cur   []rune
+	)
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = nil
+		}
+	}
+	runes := []rune(ident)
+	for i, r := range runes {
+		if r == '_' || r == '-' {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(cur) > 0 {
+			prev := cur[len(cur)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return words
+}
+
+// IdentifierRegexp returns a regular expression which matches the identifier
+// ident in any of the usual identifier styles, so that it can be found
+// wherever bindings or ports to other languages renamed it: camelCase,
+// PascalCase, snake_case, kebab-case and upper case, with or without
+// separators (e.g. getUserName, GetUserName, get_user_name, get-user-name,
+// GET_USER_NAME and GETUSERNAME).
+//
+// Each word only matches in lower case, capitalized or upper case, which keeps
+// the trigram query (see RegexpQuery) much smaller than that of a
+// case-insensitive regular expression.
+func IdentifierRegexp(ident string) (*syntax.Regexp, error) {
+	for _, r := range ident {
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return nil, fmt.Errorf("%q is not an identifier: invalid character %q", ident, r)
+		}
+	}
+	words := IdentifierWords(ident)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%q is not an identifier: no words", ident)
+	}
+	var expr strings.Builder
+	for idx, word := range words {
+		if idx > 0 {
+			expr.WriteString("[-_]?")
+		}
+		variants := []string{word}
+		for _, v := range []string{capitalize(word), strings.ToUpper(word)} {
+			if v != variants[len(variants)-1] {
+				variants = append(variants, v)
+			}
+		}
+		expr.WriteString("(?:" + strings.Join(variants, "|") + ")")
+	}
+	return syntax.Parse(expr.String(), syntax.Perl)
+}
+
+// capitalize returns word with its first letter in upper case.
+func capitalize(word string) string {
+	runes := []rune(word)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,104 @@
+package index
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIdentifierWords(t *testing.T) {
+	for _, tt := range []struct {
+		ident string
+		want  []string
+	}{
+		{"getUserName", []string{"get", "user", "name"}},
+		{"GetUserName", []string{"get", "user", "name"}},
+		{"get_user_name", []string{"get", "user", "name"}},
+		{"get-user-name", []string{"get", "user", "name"}},
+		{"GET_USER_NAME", []string{"get", "user", "name"}},
+		{"HTTPServer", []string{"http", "server"}},
+		{"utf8Decode", []string{"utf8", "decode"}},
+		{"__init__", []string{"init"}},
+		{"_", nil},
+	} {
+		if diff := cmp.Diff(tt.want, IdentifierWords(tt.ident)); diff != "" {
+			t.Errorf("IdentifierWords(%q): unexpected diff (-want +got):\n%s", tt.ident, diff)
+		}
+	}
+}
+
+// queryMatches reports whether q matches a document containing s.
+func queryMatches(q *Query, s string) bool {
+	switch q.Op {
+	case QAll:
+		return true
+	case QNone:
+		return false
+	case QAnd:
+		for _, t := range q.Trigram {
+			if !strings.Contains(s, t) {
+				return false
+			}
+		}
+		for _, sub := range q.Sub {
+			if !queryMatches(sub, s) {
+				return false
+			}
+		}
+		return true
+	case QOr:
+		for _, t := range q.Trigram {
+			if strings.Contains(s, t) {
+				return true
+			}
+		}
+		for _, sub := range q.Sub {
+			if queryMatches(sub, s) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func TestIdentifierRegexp(t *testing.T) {
+	re, err := IdentifierRegexp("getUserName")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := RegexpQuery(re)
+	if query.Op == QAll {
+		t.Errorf("RegexpQuery(%v) = %v, want a trigram query", re, query)
+	}
+	matcher := regexp.MustCompile(re.String())
+	for _, tt := range []struct {
+		line string
+		want bool
+	}{
+		{"x := getUserName()", true},
+		{"def get_user_name(self):", true},
+		{"public String GetUserName() {", true},
+		{"--get-user-name", true},
+		{"#define GET_USER_NAME 1", true},
+		{"GETUSERNAME", true},
+		{"getusername", true},
+		{"get user name", false},
+		{"getUserTitle", false},
+	} {
+		if got := matcher.MatchString(tt.line); got != tt.want {
+			t.Errorf("%v matches %q = %v, want %v", re, tt.line, got, tt.want)
+		}
+		if tt.want && !queryMatches(query, tt.line) {
+			t.Errorf("RegexpQuery(%v) does not match %q", re, tt.line)
+		}
+	}
+
+	for _, ident := range []string{"", "__", "get.user", "x+y"} {
+		if _, err := IdentifierRegexp(ident); err == nil {
+			t.Errorf("IdentifierRegexp(%q) unexpectedly succeeded", ident)
+		}
+	}
+}
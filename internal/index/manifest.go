@@ -0,0 +1,214 @@
+package index
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/google/renameio/v2"
+)
+
+// FormatVersion is the version of the index format which this package writes.
+// Open refuses to open indexes of any other version, except for
+// LegacyFormatVersion.
+const FormatVersion = 1
+
+// LegacyFormatVersion is the version of indexes which were written before
+// manifests were introduced. When AllowLegacyFormat is set, they are opened
+// without any checks, so that existing deployments keep working until their
+// indexes are re-merged.
+const LegacyFormatVersion = 0
+
+// AllowLegacyFormat makes ReadManifest (and hence Open) accept index
+// directories without a manifest as LegacyFormatVersion. It is off by default
+// because a missing manifest also marks an index whose writing was
+// interrupted.
+var AllowLegacyFormat = false
+
+// manifestFile is written after all sections of an index, so its presence also
+// marks the index as complete.
+const manifestFile = "manifest.json"
+
+// sectionFiles lists the files which make up an index, in the order in which
+// they appear in the manifest.
+var sectionFiles = []string{
+	"docid.map",
+	"posting.docid.meta",
+	"posting.docid.turbopfor",
+	"posting.pos.meta",
+	"posting.pos.turbopfor",
+	"posting.posrel.meta",
+	"posting.posrel.data",
+}
+
+// optionalSectionFiles are only present in indexes written by newer versions
+// of this package. Readers must work without them.
+var optionalSectionFiles = []string{
+	"posting.docid.skip.meta",
+	"posting.docid.skip",
+	symbolsFile,
+	hashFile,
+	aliasesFile,
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// A Manifest describes an index directory.
+type Manifest struct {
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	Docs     int       `json:"docs"` // number of documents in docid.map
+	Sections []Section `json:"sections"`
+}
+
+// A Section describes one file of an index.
+type Section struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	CRC32C uint32 `json:"crc32c"`
+}
+
+// writeManifest checksums the sections of the index in dir and writes its
+// manifest.
+func writeManifest(dir string) error {
+	m := Manifest{
+		Version:  FormatVersion,
+		Created:  time.Now().UTC(),
+		Sections: make([]Section, 0, len(sectionFiles)),
+	}
+	for _, name := range slices.Concat(sectionFiles, optionalSectionFiles) {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) && slices.Contains(optionalSectionFiles, name) {
+				continue
+			}
+			return err
+		}
+		h := crc32.New(crc32c)
+		size, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		m.Sections = append(m.Sections, Section{
+			Name:   name,
+			Size:   size,
+			CRC32C: h.Sum32(),
+		})
+	}
+
+	// docid.map ends with the offset of its index, which contains one uint32
+	// offset per document:
+	f, err := os.Open(filepath.Join(dir, "docid.map"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var indexOffset uint32
+	if _, err := f.Seek(-4, io.SeekEnd); err != nil {
+		return err
+	}
+	if err := binary.Read(f, binary.LittleEndian, &indexOffset); err != nil {
+		return err
+	}
+	m.Docs = int(m.Sections[0].Size-int64(indexOffset)-4) / 4
+
+	b, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(filepath.Join(dir, manifestFile), append(b, '\n'), 0644)
+}
+
+// ReadManifest reads the manifest of the index in dir and verifies that the
+// index is complete and of a supported format version.
+//
+// Indexes without a manifest are refused, unless AllowLegacyFormat is set: then
+// they are assumed to predate manifests and result in a Manifest of
+// LegacyFormatVersion without any sections. Directories which contain
+// sections that only manifest-writing versions of this package create (e.g.
+// skip tables or content hashes) are refused regardless, as their manifest
+// can only be missing because writing the index was interrupted.
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			if !AllowLegacyFormat {
+				return nil, fmt.Errorf("%s: no %s (incomplete index?)", dir, manifestFile)
+			}
+			for _, name := range optionalSectionFiles {
+				if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+					return nil, fmt.Errorf("%s: no %s, but %s is present (incomplete index?)", dir, manifestFile, name)
+				}
+			}
+			log.Printf("%s: no %s, opening as legacy index format version %d (without checksums). Re-merge the index to upgrade it to format version %d.", dir, manifestFile, LegacyFormatVersion, FormatVersion)
+			return &Manifest{Version: LegacyFormatVersion}, nil
+		}
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(dir, manifestFile), err)
+	}
+	if m.Version != FormatVersion {
+		return nil, fmt.Errorf("%s: unsupported index format version %d (want %d)", dir, m.Version, FormatVersion)
+	}
+	sections := make(map[string]bool, len(m.Sections))
+	for _, s := range m.Sections {
+		sections[s.Name] = true
+		st, err := os.Stat(filepath.Join(dir, s.Name))
+		if err != nil {
+			return nil, err
+		}
+		if st.Size() != s.Size {
+			return nil, fmt.Errorf("%s: %s is %d bytes, but manifest specifies %d bytes (truncated?)", dir, s.Name, st.Size(), s.Size)
+		}
+	}
+	for _, name := range sectionFiles {
+		if !sections[name] {
+			return nil, fmt.Errorf("%s: manifest lacks section %s", dir, name)
+		}
+	}
+	return &m, nil
+}
+
+// verifyChecksums compares the checksums of all (mapped) sections against the
+// manifest.
+func (i *Index) verifyChecksums() error {
+	data := map[string][]byte{
+		"docid.map":               i.DocidMap.f.Data,
+		"posting.docid.meta":      i.Docid.meta.Data,
+		"posting.docid.turbopfor": i.Docid.data.Data,
+		"posting.pos.meta":        i.Pos.meta.Data,
+		"posting.pos.turbopfor":   i.Pos.data.Data,
+		"posting.posrel.meta":     i.Posrel.meta.Data,
+		"posting.posrel.data":     i.Posrel.data.Data,
+	}
+	if i.docidSkip != nil {
+		data["posting.docid.skip.meta"] = i.docidSkip.meta.Data
+		data["posting.docid.skip"] = i.docidSkip.data.Data
+	}
+	if i.symbols != nil {
+		data[symbolsFile] = i.symbols.f.Data
+	}
+	if i.hashes != nil {
+		data[hashFile] = i.hashes.Data
+	}
+	if i.aliases != nil {
+		data[aliasesFile] = i.aliases.f.Data
+	}
+	for _, s := range i.Manifest.Sections {
+		if got := crc32.Checksum(data[s.Name], crc32c); got != s.CRC32C {
+			return corrupt(s.Name, NoTrigram, 0, "CRC32C checksum is %08x, but manifest specifies %08x", got, s.CRC32C)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "abc def ghi",
+		"file2.txt": "abc jkl mno",
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	idx, err := Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := idx.Manifest.Version, FormatVersion; got != want {
+		t.Errorf("Manifest.Version = %d, want %d", got, want)
+	}
+	if got, want := idx.Manifest.Docs, 2; got != want {
+		t.Errorf("Manifest.Docs = %d, want %d", got, want)
+	}
+	if err := idx.Verify(); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+	idx.Close()
+
+	// Corrupt a byte without changing the structure:
+	fn := filepath.Join(idxDir, "docid.map")
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[0] = 'F'
+	if err := os.WriteFile(fn, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Verify(); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Verify() = %v, want checksum error", err)
+	}
+	idx.Close()
+
+	if err := os.Truncate(filepath.Join(idxDir, "posting.pos.turbopfor"), 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(idxDir); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Open(truncated index) = %v, want truncation error", err)
+	}
+
+	// Indexes written before manifests were introduced need to keep working:
+	legacyDir := filepath.Join(tmpDir, "legacy")
+	createIndex(t, srcDir, legacyDir)
+	if err := os.Remove(filepath.Join(legacyDir, manifestFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(legacyDir); err == nil {
+		t.Errorf("Open(index without manifest) = nil, want error without AllowLegacyFormat")
+	}
+	AllowLegacyFormat = true
+	defer func() { AllowLegacyFormat = false }()
+	// The index was written by this version (with skip tables etc.), so its
+	// manifest can only be missing because writing it was interrupted:
+	if _, err := Open(legacyDir); err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Errorf("Open(index without manifest, but with new sections) = %v, want incomplete index error", err)
+	}
+	for _, name := range optionalSectionFiles {
+		if err := os.Remove(filepath.Join(legacyDir, name)); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+	idx, err = Open(legacyDir)
+	if err != nil {
+		t.Fatalf("Open(index without manifest) = %v, want nil", err)
+	}
+	defer idx.Close()
+	if got, want := idx.Manifest.Version, LegacyFormatVersion; got != want {
+		t.Errorf("Manifest.Version = %d, want %d", got, want)
+	}
+	if err := idx.Verify(); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+}
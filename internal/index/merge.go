@@ -3,6 +3,7 @@ package index
 import (
 	"bufio"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -12,15 +13,15 @@ import (
 )
 
 type indexMeta struct {
-	docidBase   uint32
+	remap       *docidRemap
 	rd          *PForReader
 	currentMeta int
 	nextMeta    MetaEntry
 }
 
-func newIndexMeta(docidBase uint32, rd *PForReader) indexMeta {
+func newIndexMeta(remap *docidRemap, rd *PForReader) indexMeta {
 	meta := indexMeta{
-		docidBase:   docidBase,
+		remap:       remap,
 		rd:          rd,
 		currentMeta: -1,
 	}
@@ -32,6 +33,50 @@ type posrelMeta struct {
 	rd *PosrelReader
 }
 
+// A posFilter removes the pos (and posrel) entries of the documents which
+// ConcatN drops from the posting lists of one index.
+type posFilter struct {
+	remap  *docidRemap
+	docid  *PForReader // nil if remap drops no documents
+	posrel *PosrelReader
+}
+
+// kept returns which of the entries pos entries of trigram t belong to
+// documents which are kept, or nil if all of them are kept.
+func (f *posFilter) kept(t Trigram, entries int) ([]bool, error) {
+	if f.remap.docids == nil {
+		return nil, nil
+	}
+	docids, err := f.docid.Deltas(t)
+	if err != nil {
+		return nil, err
+	}
+	var meta MetaEntry
+	if found := f.posrel.metaEntry1(&meta, t); !found {
+		return nil, errNotFound
+	}
+	posrel := f.posrel.data.Data[meta.OffsetData:]
+	keep := make([]bool, entries)
+	var (
+		docid uint32
+		next  int
+		kept  bool
+	)
+	for i := range keep {
+		// A 1 bit marks the first position within the next document:
+		if (posrel[i/8]>>(uint(i)%8))&1 == 1 {
+			if next >= len(docids) {
+				return nil, fmt.Errorf("trigram %v: posrel refers to more than %d documents", t, len(docids))
+			}
+			docid += docids[next]
+			next++
+			_, kept = f.remap.lookup(docid)
+		}
+		keep[i] = kept
+	}
+	return keep, nil
+}
+
 func readMeta(dir, typ string, idx map[Trigram][]uint32, idxid uint32) error {
 	f, err := os.Open(filepath.Join(dir, "posting."+typ+".meta"))
 	if err != nil {
@@ -68,7 +113,8 @@ type docidMapMerge struct {
 	offsets []uint32
 }
 
-func (m *docidMapMerge) merge(srcdir string) (uint32, error) {
+// merge appends the names of the documents of srcdir which remap keeps.
+func (m *docidMapMerge) merge(srcdir string, remap *docidRemap) (uint32, error) {
 	f, err := os.Open(filepath.Join(srcdir, "docid.map"))
 	if err != nil {
 		return 0, err
@@ -99,7 +145,10 @@ func (m *docidMapMerge) merge(srcdir string) (uint32, error) {
 	scanner := bufio.NewScanner(&io.LimitedReader{
 		R: m.bufr,
 		N: int64(indexOffset)})
-	for scanner.Scan() {
+	for docid := uint32(0); scanner.Scan(); docid++ {
+		if _, kept := remap.lookup(docid); !kept {
+			continue // the name becomes an alias, see mergeAliases
+		}
 		m.offsets = append(m.offsets, uint32(m.dest.offset))
 		m.dest.Write(scanner.Bytes())
 		m.dest.Write([]byte{'\n'})
@@ -110,10 +159,10 @@ func (m *docidMapMerge) merge(srcdir string) (uint32, error) {
 	return n, nil
 }
 
-func mergeDocidMaps(destdir string, srcdirs []string) ([]uint32, error) {
+func mergeDocidMaps(destdir string, srcdirs []string, remaps []docidRemap) error {
 	fDocidMap, err := os.Create(filepath.Join(destdir, "docid.map"))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer fDocidMap.Close()
 	cw := newCountingWriter(fDocidMap)
@@ -123,30 +172,22 @@ func mergeDocidMaps(destdir string, srcdirs []string) ([]uint32, error) {
 		dest: &cw,
 	}
 
-	bases := make([]uint32, len(srcdirs))
-	var base uint32
 	for idx, srcdir := range srcdirs {
-		bases[idx] = base
-		n, err := m.merge(srcdir)
+		n, err := m.merge(srcdir, &remaps[idx])
 		if err != nil {
-			return nil, err
+			return err
 		}
 		log.Printf("%s (idx %d) contains %d docids", srcdir, idx, n)
-		base += n
 	}
 	indexStart := uint32(cw.offset)
 	if err := binary.Write(&cw, binary.LittleEndian, m.offsets); err != nil {
-		return nil, err
+		return err
 	}
 	if err := binary.Write(&cw, binary.LittleEndian, indexStart); err != nil {
-		return nil, err
-	}
-
-	if err := cw.Close(); err != nil {
-		return nil, err
+		return err
 	}
 
-	return bases, nil
+	return cw.Close()
 }
 
 func ConcatN(destdir string, srcdirs []string) error {
@@ -154,11 +195,15 @@ func ConcatN(destdir string, srcdirs []string) error {
 		return err
 	}
 
-	bases, err := mergeDocidMaps(destdir, srcdirs)
+	remaps, err := dedupDocids(srcdirs)
 	if err != nil {
 		return err
 	}
 
+	if err := mergeDocidMaps(destdir, srcdirs, remaps); err != nil {
+		return err
+	}
+
 	start := time.Now()
 	log.Printf("reading fileMetaEntries")
 
@@ -183,12 +228,11 @@ func ConcatN(destdir string, srcdirs []string) error {
 		idxMetaDocid := make([]indexMeta, len(srcdirs))
 
 		for idx, dir := range srcdirs {
-			base := bases[idx]
 			rd, err := newPForReader(dir, "docid")
 			if err != nil {
 				return err
 			}
-			idxMetaDocid[idx] = newIndexMeta(base, rd)
+			idxMetaDocid[idx] = newIndexMeta(&remaps[idx], rd)
 		}
 
 		if err := writeDocids(destdir, trigrams, idxDocid, idxMetaDocid); err != nil {
@@ -204,49 +248,69 @@ func ConcatN(destdir string, srcdirs []string) error {
 
 	idxMetaPos := make([]indexMeta, len(srcdirs))
 	for idx, dir := range srcdirs {
-		base := bases[idx]
-
 		rd, err := newPForReader(dir, "pos")
 		if err != nil {
 			return err
 		}
 		defer rd.Close()
-		idxMetaPos[idx] = newIndexMeta(base, rd)
+		idxMetaPos[idx] = newIndexMeta(&remaps[idx], rd)
+	}
+
+	filters := make([]posFilter, len(srcdirs))
+	for idx, dir := range srcdirs {
+		rd, err := newPosrelReader(dir)
+		if err != nil {
+			return err
+		}
+		defer rd.Close()
+		filters[idx] = posFilter{remap: &remaps[idx], posrel: rd}
+		if remaps[idx].docids == nil {
+			continue
+		}
+		// The docids are needed to tell which pos entries belong to
+		// dropped documents.
+		if filters[idx].docid, err = newPForReader(dir, "docid"); err != nil {
+			return err
+		}
+		defer filters[idx].docid.Close()
 	}
 
 	{
 		idxMetaPosrel := make([]posrelMeta, len(srcdirs))
-
-		for idx, dir := range srcdirs {
-			rd, err := newPosrelReader(dir)
-			if err != nil {
-				return err
-			}
-			defer rd.Close()
-
-			idxMetaPosrel[idx] = posrelMeta{rd: rd}
+		for idx, f := range filters {
+			idxMetaPosrel[idx] = posrelMeta{rd: f.posrel}
 		}
 
-		if err := writePosrel(destdir, trigrams, idxDocid, idxMetaPos, idxMetaPosrel); err != nil {
+		if err := writePosrel(destdir, trigrams, idxDocid, idxMetaPos, idxMetaPosrel, filters); err != nil {
 			return err
 		}
-		for _, meta := range idxMetaPosrel {
-			meta.rd.Close()
-		}
 
 		log.Printf("wrote posrel in %v", time.Since(start))
 		start = time.Now()
 	}
 
-	if err := writePos(destdir, trigrams, idxDocid, idxMetaPos); err != nil {
+	if err := writePos(destdir, trigrams, idxDocid, idxMetaPos, filters); err != nil {
 		return err
 	}
 	for _, meta := range idxMetaPos {
 		meta.rd.Close()
 	}
 	log.Printf("wrote pos in %v", time.Since(start))
+	start = time.Now()
 
-	return nil
+	if err := mergeSymbols(destdir, srcdirs, remaps); err != nil {
+		return err
+	}
+	log.Printf("wrote symbols in %v", time.Since(start))
+
+	if err := mergeHashes(destdir, srcdirs, remaps); err != nil {
+		return err
+	}
+	if err := mergeAliases(destdir, srcdirs, remaps); err != nil {
+		return err
+	}
+
+	return writeManifest(destdir)
 }
 
 func writeDocids(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint32, idxMetaDocid []indexMeta) error {
@@ -255,6 +319,11 @@ func writeDocids(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 	if err != nil {
 		return err
 	}
+	sw, err := newSkipWriter(destdir)
+	if err != nil {
+		return err
+	}
+	dw.skip = sw
 
 	fDocidMeta, err := os.Create(filepath.Join(destdir, "posting.docid.meta"))
 	if err != nil {
@@ -281,6 +350,7 @@ func writeDocids(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 			//OffsetEnc:  data,
 			OffsetData: dw.Offset(),
 		}
+		sw.Reset(me.OffsetData)
 		var last uint32
 		for _, idxid := range idxDocid[t] {
 			idx := idxMetaDocid[idxid]
@@ -296,15 +366,37 @@ func writeDocids(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 			}
 			idxMetaDocid[idxid] = idx
 
-			me.Entries += meta.Entries
 			dr.Reset(&meta, idx.rd.data.Data)
+			if idx.remap.docids != nil {
+				// Some documents of this index are dropped, so the docids
+				// need to be mapped one by one. As dropped documents are
+				// copies of a kept document, the trigram still occurs in
+				// at least one document.
+				var docid uint32
+				for docids := dr.Read(); docids != nil; docids = dr.Read() {
+					for _, d := range docids {
+						docid += d
+						merged, kept := idx.remap.lookup(docid)
+						if !kept {
+							continue
+						}
+						if err := dw.PutUint32(merged - last); err != nil {
+							return err
+						}
+						last = merged
+						me.Entries++
+					}
+				}
+				continue
+			}
+			me.Entries += meta.Entries
 			docids := dr.Read() // returns non-nil at least once
 			// Bump the first docid: it needs to be mapped from the old
 			// docid range [0, n) to the new docid range [base, base+n).
 			//
 			// Since we are building a single docid list for this trigram,
 			// the new value needs to be a delta, hence, subtract last.
-			docids[0] += (idx.docidBase - last)
+			docids[0] += (idx.remap.base - last)
 			for docids != nil {
 				for _, d := range docids {
 					if err := dw.PutUint32(d); err != nil {
@@ -318,6 +410,9 @@ func writeDocids(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 		if err := dw.Flush(); err != nil {
 			return err
 		}
+		if err := sw.Flush(t); err != nil {
+			return err
+		}
 		me.Marshal(meBuf)
 		if _, err := bufwDocidMeta.Write(meBuf); err != nil {
 			//if err := binary.Write(bufwDocidMeta, binary.LittleEndian, &me); err != nil {
@@ -337,10 +432,10 @@ func writeDocids(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 		return err
 	}
 
-	return nil
+	return sw.Close()
 }
 
-func writePosrel(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint32, idxMetaPos []indexMeta, idxMetaPosrel []posrelMeta) error {
+func writePosrel(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint32, idxMetaPos []indexMeta, idxMetaPosrel []posrelMeta, filters []posFilter) error {
 	log.Printf("writing merged posrel")
 	fmetaf, err := os.Create(filepath.Join(destdir, "posting.posrel.meta"))
 	if err != nil {
@@ -383,9 +478,24 @@ func writePosrel(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 				continue
 			}
 			b := idxMetaPosrel[idxid].rd.data.Data[pmeta.OffsetData:]
-			if err := pw.Write(b, int(fmeta.Entries)); err != nil {
+			keep, err := filters[idxid].kept(t, int(fmeta.Entries))
+			if err != nil {
 				return err
 			}
+			if keep == nil {
+				if err := pw.Write(b, int(fmeta.Entries)); err != nil {
+					return err
+				}
+				continue
+			}
+			for i, k := range keep {
+				if !k {
+					continue
+				}
+				if err := pw.WriteByte((b[i/8]>>(uint(i)%8))&1, 1); err != nil {
+					return err
+				}
+			}
 
 		}
 		if err := pw.Flush(); err != nil {
@@ -404,7 +514,7 @@ func writePosrel(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint
 	return nil
 }
 
-func writePos(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint32, idxMetaPos []indexMeta) error {
+func writePos(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint32, idxMetaPos []indexMeta, filters []posFilter) error {
 	log.Printf("writing merged pos")
 	dw, err := newPForWriter(destdir, "pos")
 	if err != nil {
@@ -455,14 +565,26 @@ func writePos(destdir string, trigrams []Trigram, idxDocid map[Trigram][]uint32,
 			}
 			idxMetaPos[idxid] = idx
 
-			me.Entries += meta.Entries
+			keep, err := filters[idxid].kept(t, int(meta.Entries))
+			if err != nil {
+				return err
+			}
 			dr.Reset(&meta, idx.rd.data.Data)
 
+			// Positions are relative to the previous position within the
+			// same document, so dropping all positions of a document does not
+			// affect the other positions.
+			var n int
 			for docids := dr.Read(); docids != nil; docids = dr.Read() {
 				for _, d := range docids {
+					n++
+					if keep != nil && !keep[n-1] {
+						continue
+					}
 					if err := dw.PutUint32(d); err != nil {
 						return err
 					}
+					me.Entries++
 				}
 			}
 
@@ -0,0 +1,81 @@
+package index
+
+import "sort"
+
+// FilterNear returns the subset of docids (which must be sorted) in which the
+// required fragments (see RequiredFragments) of all parts occur within near
+// lines of the fragments of parts[0]. Like in FilterFragments, the maximum line
+// length of indexed files bounds the distance instead of line boundaries.
+//
+// Only the longest fragment of each part is considered, and parts without
+// fragments do not restrict the result. The result may contain false
+// positives, but never misses a document in which the parts occur within near
+// lines of each other.
+func (i *Index) FilterNear(docids []uint32, parts [][]Fragment, near int) ([]uint32, error) {
+	if len(parts) < 2 || len(parts[0]) == 0 {
+		return docids, nil
+	}
+	var positions [][]Match
+	for _, fragments := range parts {
+		if len(fragments) == 0 {
+			continue
+		}
+		longest := fragments[0]
+		for _, f := range fragments[1:] {
+			if len(f.Literal) > len(longest.Literal) {
+				longest = f
+			}
+		}
+		matches, err := i.fragmentMatches(longest)
+		if err != nil || matches == nil {
+			return nil, err // fragment does not occur in any document
+		}
+		positions = append(positions, matches)
+	}
+	if len(positions) < 2 {
+		return docids, nil
+	}
+
+	// Two positions which are at most near lines apart are less than
+	// near+1 lines (including their newlines) apart.
+	maxDist := uint32(near+1) * (maxLineLen + 1)
+	var filtered []uint32
+	inDoc := make([][]uint32, len(positions))
+	next := make([]int, len(positions))
+	for _, docid := range docids {
+		found := true
+		for idx, matches := range positions {
+			inDoc[idx] = positionsIn(inDoc[idx][:0], matches, &next[idx], docid)
+			if len(inDoc[idx]) == 0 {
+				found = false
+			}
+		}
+		if found && positionsNear(inDoc, maxDist) {
+			filtered = append(filtered, docid)
+		}
+	}
+	return filtered, nil
+}
+
+// positionsNear reports whether there is a position in positions[0] which has
+// a position of each of the other (sorted) positions within maxDist bytes.
+func positionsNear(positions [][]uint32, maxDist uint32) bool {
+	for _, start := range positions[0] {
+		lo := uint32(0)
+		if start > maxDist {
+			lo = start - maxDist
+		}
+		near := true
+		for _, pos := range positions[1:] {
+			j := sort.Search(len(pos), func(j int) bool { return pos[j] >= lo })
+			if j == len(pos) || pos[j] > start+maxDist {
+				near = false
+				break
+			}
+		}
+		if near {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package index
+
+import (
+	"path/filepath"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilterNear(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	long := strings.Repeat(strings.Repeat("y", maxLineLen*3/4)+"\n", 10)
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "fd := open(path)\ndefer close(fd)\n",
+		"file2.txt": "fd := open(path)\n" + long + "close(fd)\n",
+		"file3.txt": "fd := open(path)\n",
+		"file4.txt": "close(fd)\n" + long + long + "fd := open(path)\n",
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	idx, err := Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	all := []uint32{0, 1, 2, 3}
+	for _, tt := range []struct {
+		parts []string
+		near  int
+		want  []uint32
+	}{
+		{[]string{`open\(`, `close\(`}, 1, []uint32{0}},
+		{[]string{`open\(`, `close\(`}, 11, []uint32{0, 1}},
+		{[]string{`close\(`, `open\(`}, 30, []uint32{0, 1, 3}},
+		{[]string{`open\(`, `xyz`}, 30, nil},
+		// Parts without fragments do not restrict the result:
+		{[]string{`open\(`, `\d`}, 1, all},
+	} {
+		t.Run(strings.Join(tt.parts, ","), func(t *testing.T) {
+			var parts [][]Fragment
+			for _, expr := range tt.parts {
+				re, err := syntax.Parse(expr, syntax.Perl)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, RequiredFragments(re))
+			}
+			got, err := idx.FilterNear(all, parts, tt.near)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FilterNear(%q, %d): unexpected diff (-want +got):\n%s", tt.parts, tt.near, diff)
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ type pforWriter struct {
 	f    countingWriter
 	ints []uint32
 	buf  []byte
+
+	skip *skipWriter // if non-nil, records the block boundaries
 }
 
 func newPForWriter(dir, typ string) (*pforWriter, error) {
@@ -42,6 +44,11 @@ func (pw *pforWriter) putUint32flush() error {
 }
 
 func (pw *pforWriter) PutUint32(u uint32) error {
+	if pw.skip != nil {
+		// All previous blocks were flushed, so Offset is where the block
+		// containing u will start.
+		pw.skip.add(u, pw.Offset())
+	}
 	pw.ints = append(pw.ints, u)
 	if len(pw.ints) == 256 {
 		return pw.putUint32flush()
@@ -6,7 +6,16 @@ import (
 )
 
 func (i *Index) PostingQuery(q *Query) (docids []uint32) {
-	return i.postingQuery(q, nil)
+	docids = i.postingQuery(q, nil)
+	if i.delta == nil {
+		return docids
+	}
+	docids = i.withoutTombstones(docids)
+	base := uint32(i.DocidMap.Count)
+	for _, docid := range i.delta.PostingQuery(q) {
+		docids = append(docids, base+docid)
+	}
+	return docids
 }
 
 // Implements sort.Interface
@@ -177,6 +186,11 @@ func (ix *Index) postingList(tri uint32, restrict []uint32) []uint32 {
 }
 
 func (ix *Index) postingAnd(list []uint32, tri uint32, restrict []uint32) []uint32 {
+	// list is already restricted, so restrict does not need to be applied
+	// again when skipping:
+	if x, ok := ix.postingAndSkip(list, Trigram(tri)); ok {
+		return x
+	}
 	l, err := ix.readDocids(Trigram(tri), restrict)
 	if err != nil {
 		log.Printf("(probably okay?) tri %d: %v", tri, err)
@@ -225,3 +239,43 @@ func (ix *Index) postingOr(list []uint32, tri uint32, restrict []uint32) []uint3
 	//log.Printf("len(postingOr(%d, retrict %d)) = %d", tri, len(restrict), len(x))
 	return x[:xn]
 }
+
+// Trigrams returns the sorted, de-duplicated trigrams which appear anywhere in
+// q.
+func (q *Query) Trigrams() []string {
+	seen := make(map[string]bool)
+	var walk func(q *Query)
+	walk = func(q *Query) {
+		for _, t := range q.Trigram {
+			seen[t] = true
+		}
+		for _, sub := range q.Sub {
+			walk(sub)
+		}
+	}
+	walk(q)
+	trigrams := make([]string, 0, len(seen))
+	for t := range seen {
+		trigrams = append(trigrams, t)
+	}
+	sort.Strings(trigrams)
+	return trigrams
+}
+
+// TrigramEntries returns the number of documents containing t (the length of
+// its docid posting list) and the number of occurrences of t (the length of its
+// pos posting list), including the delta index, if any.
+func (ix *Index) TrigramEntries(t Trigram) (docids, positions uint32) {
+	if meta, err := ix.Docid.MetaEntry(t); err == nil {
+		docids = meta.Entries
+	}
+	if meta, err := ix.Pos.MetaEntry(t); err == nil {
+		positions = meta.Entries
+	}
+	if ix.delta != nil {
+		d, p := ix.delta.TrigramEntries(t)
+		docids += d
+		positions += p
+	}
+	return docids, positions
+}
@@ -1,7 +1,6 @@
 package index
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
@@ -9,8 +8,12 @@ import (
 	"io"
 	"math/bits"
 	"path/filepath"
+	"regexp/syntax"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 
 	"github.com/Debian/dcs/internal/mmap"
 	"github.com/Debian/dcs/internal/turbopfor"
@@ -21,15 +24,16 @@ import (
 var errNotFound = errors.New("not found")
 
 type cachedLookup struct {
-	docid uint32 // 0xFFFFFFFF is invalid, because 0 is a valid docid
+	docid uint32
 	fn    string
 }
 
+// DocidReader is safe for concurrent use.
 type DocidReader struct {
 	f           *mmap.File
 	indexOffset uint32
 	Count       int
-	last        cachedLookup
+	last        atomic.Pointer[cachedLookup]
 }
 
 func newDocidReader(dir string) (*DocidReader, error) {
@@ -42,9 +46,6 @@ func newDocidReader(dir string) (*DocidReader, error) {
 		f:           f,
 		indexOffset: indexOffset,
 		Count:       int(uint32(len(f.Data))-indexOffset-4) / 4,
-		last: cachedLookup{
-			docid: 0xFFFFFFFF,
-		},
 	}, nil
 }
 
@@ -56,10 +57,26 @@ func (dr *DocidReader) All() io.Reader {
 	return bytes.NewReader(dr.f.Data[:dr.indexOffset])
 }
 
+// each calls fn for each document, in docid order.
+func (dr *DocidReader) each(fn func(docid uint32, name string) error) error {
+	b := dr.f.Data[:dr.indexOffset]
+	for docid := uint32(0); len(b) > 0; docid++ {
+		idx := bytes.IndexByte(b, '\n')
+		if idx == -1 {
+			return fmt.Errorf("docid %d: name not terminated by a newline", docid)
+		}
+		if err := fn(docid, string(b[:idx])); err != nil {
+			return err
+		}
+		b = b[idx+1:]
+	}
+	return nil
+}
+
 func (dr *DocidReader) Lookup(docid uint32) (string, error) {
 	// memoizing the last entry suffices because posting lists are sorted by docid
-	if dr.last.docid == docid {
-		return dr.last.fn, nil
+	if last := dr.last.Load(); last != nil && last.docid == docid {
+		return last.fn, nil
 	}
 	offset := int64(dr.indexOffset + (docid * 4))
 	if offset >= int64(len(dr.f.Data)-4) {
@@ -76,9 +93,9 @@ func (dr *DocidReader) Lookup(docid uint32) (string, error) {
 
 	// Read docid file name:
 	l := int(offsets.Next - offsets.String - 1)
-	dr.last.docid = docid
-	dr.last.fn = string(dr.f.Data[int(offsets.String) : int(offsets.String)+l])
-	return dr.last.fn, nil
+	fn := string(dr.f.Data[int(offsets.String) : int(offsets.String)+l])
+	dr.last.Store(&cachedLookup{docid: docid, fn: fn})
+	return fn, nil
 }
 
 type reusableBuffer struct {
@@ -371,25 +388,44 @@ func (pr *PosrelReader) Close() error {
 	return nil
 }
 
+// An Index is safe for concurrent use by multiple goroutines, but must not be
+// closed while queries are in flight.
 type Index struct {
+	Manifest *Manifest     // format version, creation time and checksums
 	DocidMap *DocidReader  // docid → filename mapping
 	Docid    *PForReader   // docids for all trigrams
 	Pos      *PForReader   // positions for all trigrams
 	Posrel   *PosrelReader // position relationships for all trigrams
 
-	// buffers for both i.Matches() calls
-	firstBuffer *bufferPair
-	lastBuffer  *bufferPair
+	docidSkip *skipReader   // skip tables for long docid posting lists, or nil
+	symbols   *symbolReader // definitions, or nil
+	hashes    *mmap.File    // content hash per docid, or nil
+	aliases   *aliasReader  // names of duplicate files, or nil
+
+	// delta (if non-nil) contains documents which were added after this index
+	// was created, see OpenDelta.
+	delta        *Index
+	tombstones   []uint32        // sorted docids which were deleted from this index
+	deletedNames map[string]bool // deleted copies of docids which remain, or nil
 }
 
+// Open opens the index in dir after checking its manifest (see ReadManifest)
+// for completeness: section sizes and the number of documents. Open does not
+// compare checksums, which would require reading the entire index; Verify
+// does.
 func Open(dir string) (*Index, error) {
 	var i Index
-	i.firstBuffer = newBufferPair()
-	i.lastBuffer = newBufferPair()
 	var err error
+	if i.Manifest, err = ReadManifest(dir); err != nil {
+		return nil, err
+	}
 	if i.DocidMap, err = newDocidReader(dir); err != nil {
 		return nil, err
 	}
+	if got, want := i.DocidMap.Count, i.Manifest.Docs; got != want && i.Manifest.Version != LegacyFormatVersion {
+		i.DocidMap.Close()
+		return nil, fmt.Errorf("%s: docid.map contains %d documents, but manifest specifies %d", dir, got, want)
+	}
 
 	// posrel reduces the index size by about ≈ 1/4!
 	if i.Posrel, err = newPosrelReader(dir); err != nil {
@@ -399,9 +435,21 @@ func Open(dir string) (*Index, error) {
 	if i.Docid, err = newPForReader(dir, "docid"); err != nil {
 		return nil, err
 	}
+	if i.docidSkip, err = newSkipReader(dir); err != nil {
+		return nil, err
+	}
 	if i.Pos, err = newPForReader(dir, "pos"); err != nil {
 		return nil, err
 	}
+	if i.symbols, err = newSymbolReader(dir); err != nil {
+		return nil, err
+	}
+	if i.hashes, err = openHashes(dir); err != nil {
+		return nil, err
+	}
+	if i.aliases, err = newAliasReader(dir); err != nil {
+		return nil, err
+	}
 
 	return &i, nil
 }
@@ -478,58 +526,164 @@ func (i *Index) matchesWithBuffer(t Trigram, buffers *bufferPair) ([]Match, erro
 	return matches, nil
 }
 
-// FiveLines returns five \n-separated lines surrounding pos. The first two
-// lines are context above the line containing pos (which is always element
-// [2]), the last two lines are context below that line.
-func FiveLines(b []byte, pos int) [5]string {
-	//fmt.Printf("FiveLines(%q, %d)\n", string(b), pos)
-	var five [5]string
-	prev := pos
-	start := 2 // no before lines
-
-	// move prev to the beginning of the line in case the match starts in the
-	// middle of the line
-	if idx := bytes.LastIndexByte(b[:prev], '\n'); idx != -1 {
-		prev = idx + 1
-	} else {
-		prev = 0
-	}
-
-	for start > 0 {
-		// fmt.Printf("  looking for newline in %q\n  (would extract from: %q)\n", string(b[:prev]), string(b[prev:]))
-		if idx := bytes.LastIndexByte(b[:prev], '\n'); idx != -1 {
-			// fmt.Printf("  new line found at %d, could add one more context line\n", idx)
-			// idx points to the end of the line (\n),
-			// but we want to position prev at the start of the line.
-			if idx := bytes.LastIndexByte(b[:idx], '\n'); idx != -1 {
-				prev = idx + 1
-			} else {
-				prev = 0
+// ContextLines returns the line containing pos, preceded by up to before
+// lines and followed by up to after lines of context (fewer if b starts or
+// ends first).
+func ContextLines(b []byte, pos, before, after int) (ctxBefore []string, line string, ctxAfter []string) {
+	start := bytes.LastIndexByte(b[:pos], '\n') + 1
+	for prev := start; len(ctxBefore) < before && prev > 0; {
+		end := prev - 1 // the \n of the previous line
+		prev = bytes.LastIndexByte(b[:end], '\n') + 1
+		ctxBefore = append(ctxBefore, string(b[prev:end]))
+	}
+	slices.Reverse(ctxBefore)
+
+	rest := b[start:]
+	nextLine := func() string {
+		idx := bytes.IndexByte(rest, '\n')
+		if idx == -1 {
+			l := string(rest)
+			rest = nil
+			return l
+		}
+		l := string(rest[:idx])
+		rest = rest[idx+1:]
+		return l
+	}
+	line = nextLine()
+	for len(ctxAfter) < after && len(rest) > 0 {
+		ctxAfter = append(ctxAfter, nextLine())
+	}
+	return ctxBefore, line, ctxAfter
+}
+
+// PositionalLiteral returns the literal which re matches if re can be answered
+// using QueryPositional (or QueryPositionalFold, if foldCase is true).
+func PositionalLiteral(re *syntax.Regexp) (literal string, foldCase bool, ok bool) {
+	simplified := re.Simplify()
+	if simplified.Op != syntax.OpLiteral {
+		return "", false, false
+	}
+	foldCase = simplified.Flags&syntax.FoldCase != 0
+	if foldCase {
+		// The positional index only knows about ASCII case variants, see
+		// QueryPositionalFold.
+		for _, r := range simplified.Rune {
+			if r >= utf8.RuneSelf {
+				return "", false, false
 			}
-			start--
-		} else {
-			break
 		}
-		// fmt.Println()
 	}
-	// fmt.Printf("  will extract from: %q", string(b[prev:]))
+	return string(simplified.Rune), foldCase, true
+}
+
+// ErrQueryTooBroad is returned by QueryPositional for short queries which
+// match too many positions to be answered from the positional index.
+var ErrQueryTooBroad = errors.New("query matches too many positions")
 
-	if prev == -1 {
-		return five // TODO: BUG
+// maxShortQueryEntries bounds the number of pos entries which queryShort
+// decodes, which would otherwise be the entire pos section for a single-byte
+// query like “e”.
+const maxShortQueryEntries = 10 * 1000 * 1000
+
+// queryShort returns all positions at which one of prefixes (each 1 or 2 bytes
+// long) occurs, sorted by docid and position.
+//
+// Occurrences are found via the trigrams starting at them. No trigram starts
+// within the last two bytes of a file, so for single-byte prefixes, queryShort
+// additionally consults the trigrams which end in the prefix followed by a
+// newline, covering the last byte before the final newline (e.g. the closing
+// brace of a source file). Occurrences within the last two bytes of files
+// which do not end in a newline are not found.
+func (i *Index) queryShort(prefixes []string) ([]Match, error) {
+	type lookup struct {
+		t      Trigram
+		offset uint32 // of the prefix within the trigram
+	}
+	var lookups []lookup
+	var entries uint32
+	d := i.Pos.meta.Data
+	num := len(d) / metaEntrySize
+	for _, prefix := range prefixes {
+		shift := 8 * uint(3-len(prefix))
+		var lo Trigram
+		for j := 0; j < len(prefix); j++ {
+			lo = lo<<8 | Trigram(prefix[j])
+		}
+		lo <<= shift
+		hi := lo | (1<<shift - 1)
+		n := sort.Search(num, func(i int) bool {
+			// MetaEntry.Trigram is the first member
+			return Trigram(binary.LittleEndian.Uint32(d[i*metaEntrySize:])) >= lo
+		})
+		var meta MetaEntry
+		for ; i.Pos.metaEntryAt(&meta, n) && meta.Trigram <= hi; n++ {
+			if entries += meta.Entries; entries > maxShortQueryEntries {
+				return nil, ErrQueryTooBroad
+			}
+			lookups = append(lookups, lookup{t: meta.Trigram})
+		}
+		if len(prefix) != 1 {
+			continue
+		}
+		for b := 0; b < 256; b++ {
+			t := Trigram(b)<<16 | Trigram(prefix[0])<<8 | '\n'
+			if !i.Pos.metaEntry1(&meta, t) {
+				continue
+			}
+			if entries += meta.Entries; entries > maxShortQueryEntries {
+				return nil, ErrQueryTooBroad
+			}
+			lookups = append(lookups, lookup{t: t, offset: 1})
+		}
 	}
-	// fmt.Printf("start=%d, prev=%d, window = %q\n", start, prev, b[prev:])
-	scanner := bufio.NewScanner(bytes.NewReader(b[prev:]))
-	for ; start < 5; start++ {
-		if scanner.Scan() {
-			five[start] = scanner.Text()
+
+	matches := make([]Match, 0, entries)
+	buffers := newBufferPair()
+	for _, l := range lookups {
+		m, err := i.matchesWithBuffer(l.t, buffers)
+		if err != nil {
+			return nil, err
 		}
+		for j := range m {
+			m[j].Position += l.offset
+		}
+		matches = append(matches, m...)
 	}
-	return five
+	sortMatches(matches)
+	// Occurrences which are followed by a newline and at least one more byte
+	// are found via both the trigram starting and the trigram ending at them.
+	return slices.Compact(matches), nil
+}
+
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Docid != matches[j].Docid {
+			return matches[i].Docid < matches[j].Docid
+		}
+		return matches[i].Position < matches[j].Position
+	})
 }
 
+// QueryPositional returns the positions at which query (potentially) occurs.
+// For queries longer than 3 bytes, only the first and last trigram are
+// consulted, so callers need to verify the results against the file contents.
 func (i *Index) QueryPositional(query string) ([]Match, error) {
-	if len(query) < 4 {
-		return nil, nil // not yet implemented
+	return i.withDelta(query, (*Index).queryPositional)
+}
+
+func (i *Index) queryPositional(query string) ([]Match, error) {
+	switch len(query) {
+	case 0:
+		return nil, nil
+	case 1, 2:
+		return i.queryShort([]string{query})
+	case 3:
+		matches, err := i.matchesWithBuffer(trigramAt([]byte(query), 0), newBufferPair())
+		if errors.Is(err, errNotFound) {
+			return nil, nil // the trigram does not occur in the index
+		}
+		return matches, err
 	}
 	type planEntry struct {
 		offset  int
@@ -540,11 +694,12 @@ func (i *Index) QueryPositional(query string) ([]Match, error) {
 	plan := make([]planEntry, len(query)-2)
 	// TODO: maybe parallelize building the plan?
 	for j := 0; j < len(query)-2; j++ {
-		t := Trigram(uint32(qb[j])<<16 |
-			uint32(qb[j+1])<<8 |
-			uint32(qb[j+2]))
+		t := trigramAt(qb, j)
 		meta, _, err := i.Pos.metaEntry(t)
 		if err != nil {
+			if errors.Is(err, errNotFound) {
+				return nil, nil // a trigram of query does not occur in the index
+			}
 			return nil, err
 		}
 		plan[j] = planEntry{
@@ -587,13 +742,13 @@ func (i *Index) QueryPositional(query string) ([]Match, error) {
 
 	eg.Go(func() error {
 		var err error
-		fdocids, fpos, fposrel, err = i.matchesWithBufferDirect(first.t, i.firstBuffer)
+		fdocids, fpos, fposrel, err = i.matchesWithBufferDirect(first.t, newBufferPair())
 		return err
 	})
 
 	eg.Go(func() error {
 		var err error
-		ldocids, lpos, lposrel, err = i.matchesWithBufferDirect(last.t, i.lastBuffer)
+		ldocids, lpos, lposrel, err = i.matchesWithBufferDirect(last.t, newBufferPair())
 		return err
 	})
 
@@ -675,7 +830,121 @@ func (i *Index) QueryPositional(query string) ([]Match, error) {
 	return entries, nil
 }
 
+func trigramAt(b []byte, j int) Trigram {
+	return Trigram(uint32(b[j])<<16 |
+		uint32(b[j+1])<<8 |
+		uint32(b[j+2]))
+}
+
+// caseVariants returns all trigrams which differ from t only in the case of
+// ASCII letters, including t itself.
+func caseVariants(t Trigram) []Trigram {
+	variants := []Trigram{t}
+	for shift := 0; shift < 24; shift += 8 {
+		c := byte(t >> shift)
+		var other byte
+		switch {
+		case 'a' <= c && c <= 'z':
+			other = c - 'a' + 'A'
+		case 'A' <= c && c <= 'Z':
+			other = c - 'A' + 'a'
+		default:
+			continue
+		}
+		for _, v := range variants {
+			variants = append(variants, v&^(0xFF<<shift)|Trigram(other)<<shift)
+		}
+	}
+	return variants
+}
+
+// matchesFold returns the matches of all case variants of t, sorted by docid
+// and position.
+func (i *Index) matchesFold(t Trigram) ([]Match, error) {
+	var matches []Match
+	for _, v := range caseVariants(t) {
+		m, err := i.matchesWithBuffer(v, newBufferPair())
+		if err != nil {
+			if errors.Is(err, errNotFound) {
+				continue // this case variant does not occur in the index
+			}
+			return nil, err
+		}
+		matches = append(matches, m...)
+	}
+	sortMatches(matches)
+	return matches, nil
+}
+
+// QueryPositionalFold is like QueryPositional, but matches query without
+// regard to the case of ASCII letters. Case variants of non-ASCII characters
+// (e.g. U+212A KELVIN SIGN for k) are not considered.
+//
+// Like with QueryPositional, only the first and last trigram of query are
+// looked up, so callers need to verify the results against the file contents,
+// e.g. using bytes.EqualFold.
+func (i *Index) QueryPositionalFold(query string) ([]Match, error) {
+	return i.withDelta(query, (*Index).queryPositionalFold)
+}
+
+func (i *Index) queryPositionalFold(query string) ([]Match, error) {
+	qb := []byte(query)
+	switch len(qb) {
+	case 0:
+		return nil, nil
+	case 1, 2:
+		var padded [3]byte
+		copy(padded[:], qb)
+		var prefixes []string
+		for _, t := range caseVariants(trigramAt(padded[:], 0)) {
+			prefixes = append(prefixes, string([]byte{byte(t >> 16), byte(t >> 8)}[:len(qb)]))
+		}
+		return i.queryShort(prefixes)
+	case 3:
+		return i.matchesFold(trigramAt(qb, 0))
+	}
+
+	var eg errgroup.Group
+	var first, last []Match
+	eg.Go(func() error {
+		var err error
+		first, err = i.matchesFold(trigramAt(qb, 0))
+		return err
+	})
+	eg.Go(func() error {
+		var err error
+		last, err = i.matchesFold(trigramAt(qb, len(qb)-3))
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Both lists are sorted by (docid, position), so a single pass over each
+	// suffices to find all first trigram matches which are followed by a last
+	// trigram match at the expected distance.
+	delta := uint32(len(qb) - 3)
+	var entries []Match
+	var j int
+	for _, f := range first {
+		want := Match{Docid: f.Docid, Position: f.Position + delta}
+		for j < len(last) && (last[j].Docid < want.Docid ||
+			(last[j].Docid == want.Docid && last[j].Position < want.Position)) {
+			j++
+		}
+		if j < len(last) && last[j] == want {
+			entries = append(entries, f)
+		}
+	}
+	return entries, nil
+}
+
 func (i *Index) Close() error {
+	if i.delta != nil {
+		if err := i.delta.Close(); err != nil {
+			return err
+		}
+	}
 	if i.Docid != nil {
 		if err := i.Docid.Close(); err != nil {
 			return err
@@ -686,6 +955,26 @@ func (i *Index) Close() error {
 			return err
 		}
 	}
+	if i.docidSkip != nil {
+		if err := i.docidSkip.Close(); err != nil {
+			return err
+		}
+	}
+	if i.symbols != nil {
+		if err := i.symbols.Close(); err != nil {
+			return err
+		}
+	}
+	if i.hashes != nil {
+		if err := i.hashes.Close(); err != nil {
+			return err
+		}
+	}
+	if i.aliases != nil {
+		if err := i.aliases.Close(); err != nil {
+			return err
+		}
+	}
 	if err := i.Posrel.Close(); err != nil {
 		return err
 	}
@@ -2,12 +2,13 @@ package index
 
 import (
 	"bytes"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 )
 
-func TestFiveLines(t *testing.T) {
+func TestContextLines(t *testing.T) {
 	golden := []byte(`first line
 second line
 third line
@@ -27,124 +28,309 @@ fi
 #DEBHELPER#
 `)
 
+	type contextLines struct {
+		Before []string
+		Line   string
+		After  []string
+	}
 	for _, tt := range []struct {
-		input []byte
-		query string
-		want  [5]string
+		input         []byte
+		query         string
+		before, after int
+		want          contextLines
 	}{
 
 		{
 			input: golden,
 			query: "foo bar",
-			want: [5]string{
-				"second line",
-				"third line",
-				"foo bar",
-				"baz 234",
-				"qux 567 890<no newline>",
+			want: contextLines{
+				Before: []string{"second line", "third line"},
+				Line:   "foo bar",
+				After:  []string{"baz 234", "qux 567 890<no newline>"},
 			},
 		},
 
 		{
 			input: golden,
 			query: "third line",
-			want: [5]string{
-				"first line",
-				"second line",
-				"third line",
-				"foo bar",
-				"baz 234",
+			want: contextLines{
+				Before: []string{"first line", "second line"},
+				Line:   "third line",
+				After:  []string{"foo bar", "baz 234"},
 			},
 		},
 
 		{
 			input: golden,
 			query: "qux 567",
-			want: [5]string{
-				"foo bar",
-				"baz 234",
-				"qux 567 890<no newline>",
-				"",
-				"",
+			want: contextLines{
+				Before: []string{"foo bar", "baz 234"},
+				Line:   "qux 567 890<no newline>",
+			},
+		},
+
+		{
+			input:  golden,
+			query:  "foo bar",
+			before: -1, // no context
+			after:  -1,
+			want: contextLines{
+				Line: "foo bar",
+			},
+		},
+
+		{
+			input:  golden,
+			query:  "foo bar",
+			before: 3,
+			after:  5,
+			want: contextLines{
+				Before: []string{"first line", "second line", "third line"},
+				Line:   "foo bar",
+				After:  []string{"baz 234", "qux 567 890<no newline>"},
 			},
 		},
 
 		{
 			input: []byte("oneline"),
 			query: "one",
-			want: [5]string{
-				"",
-				"",
-				"oneline",
-				"",
-				"",
+			want: contextLines{
+				Line: "oneline",
 			},
 		},
 
 		{
 			input: []byte("oneline\ntwoline"),
 			query: "one",
-			want: [5]string{
-				"",
-				"",
-				"oneline",
-				"twoline",
-				"",
+			want: contextLines{
+				Line:  "oneline",
+				After: []string{"twoline"},
 			},
 		},
 
 		{
 			input: []byte("oneline\ntwoline\nthreeline"),
 			query: "one",
-			want: [5]string{
-				"",
-				"",
-				"oneline",
-				"twoline",
-				"threeline",
+			want: contextLines{
+				Line:  "oneline",
+				After: []string{"twoline", "threeline"},
 			},
 		},
 
 		{
 			input: []byte("oneline\ntwoline\nthreeline\nfourline\n"),
 			query: "one",
-			want: [5]string{
-				"",
-				"",
-				"oneline",
-				"twoline",
-				"threeline",
+			want: contextLines{
+				Line:  "oneline",
+				After: []string{"twoline", "threeline"},
 			},
 		},
 
 		{
 			input: []byte(binShGolden),
 			query: "bin/sh",
-			want: [5]string{
-				"",
-				"",
-				"#!/bin/sh",
-				"set -e",
-				"",
+			want: contextLines{
+				Line:  "#!/bin/sh",
+				After: []string{"set -e", ""},
 			},
 		},
 
 		{
 			input: []byte(binShGolden),
 			query: "set -e",
-			want: [5]string{
-				"",
-				"#!/bin/sh",
-				"set -e",
-				"",
-				`if [ "$(uname -s)" = "Linux" ]; then`,
+			want: contextLines{
+				Before: []string{"#!/bin/sh"},
+				Line:   "set -e",
+				After:  []string{"", `if [ "$(uname -s)" = "Linux" ]; then`},
 			},
 		},
 	} {
 		t.Run("", func(t *testing.T) {
-			got := FiveLines(tt.input, bytes.Index(tt.input, []byte(tt.query)))
+			before, after := tt.before, tt.after
+			if before == 0 {
+				before = 2
+			}
+			if after == 0 {
+				after = 2
+			}
+			var got contextLines
+			got.Before, got.Line, got.After = ContextLines(tt.input, bytes.Index(tt.input, []byte(tt.query)), max(before, 0), max(after, 0))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ContextLines(%s): unexpected diff (-want +got):\n%s", tt.query, diff)
+			}
+		})
+	}
+}
+
+func TestQueryPositionalShort(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "p->x = !!y;\n",
+		"file2.txt": "if (!done) x++;\n",
+		"file3.txt": "f() {}\n",
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	for _, tt := range []struct {
+		query string
+		want  []searchResult
+	}{
+		{
+			query: "->x",
+			want: []searchResult{
+				result(0, "file1.txt", 1),
+			},
+		},
+
+		{
+			query: "!!",
+			want: []searchResult{
+				result(0, "file1.txt", 7),
+			},
+		},
+
+		{
+			query: "x",
+			want: []searchResult{
+				result(0, "file1.txt", 3),
+				result(1, "file2.txt", 11),
+			},
+		},
+
+		{
+			query: "!",
+			want: []searchResult{
+				result(0, "file1.txt", 7),
+				result(0, "file1.txt", 8),
+				result(1, "file2.txt", 4),
+			},
+		},
+
+		{
+			// last byte before the final newline
+			query: ";",
+			want: []searchResult{
+				result(0, "file1.txt", 10),
+				result(1, "file2.txt", 14),
+			},
+		},
+
+		{
+			query: "}",
+			want: []searchResult{
+				result(2, "file3.txt", 5),
+			},
+		},
+
+		{
+			query: "?",
+			want:  []searchResult{},
+		},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			results := searchIndex(t, idxDir, tt.query)
+			if diff := cmp.Diff(tt.want, results); diff != "" {
+				t.Errorf("searchIndex(%q): unexpected results: diff (-want +got):\n%s", tt.query, diff)
+			}
+		})
+	}
+}
+
+func TestQueryPositionalAbsent(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "p->x = !!y;\n",
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	idx, err := Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	// Queries whose trigrams do not occur in the index must result in no
+	// matches, not in an error.
+	for _, query := range []string{"zzz", "zzzz", "p->z"} {
+		t.Run(query, func(t *testing.T) {
+			got, err := idx.QueryPositional(query)
+			if err != nil {
+				t.Fatalf("QueryPositional(%q): %v", query, err)
+			}
+			if len(got) > 0 {
+				t.Errorf("QueryPositional(%q) = %v, want no matches", query, got)
+			}
+		})
+	}
+}
+
+func TestQueryPositionalFold(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "func NewReader() *Reader",
+		"file2.txt": "newreader := NEWREADER",
+		"file3.txt": "new_reader",
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	idx, err := Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	for _, tt := range []struct {
+		query string
+		want  []Match
+	}{
+		{
+			query: "newreader",
+			want: []Match{
+				{Docid: 0, Position: 5},
+				{Docid: 1, Position: 0},
+				{Docid: 1, Position: 13},
+			},
+		},
+
+		{
+			query: "READER",
+			want: []Match{
+				{Docid: 0, Position: 8},
+				{Docid: 0, Position: 18},
+				{Docid: 1, Position: 3},
+				{Docid: 1, Position: 16},
+				{Docid: 2, Position: 4},
+			},
+		},
+
+		{
+			query: "xyzzy",
+			want:  nil,
+		},
+
+		{
+			query: "nE",
+			want: []Match{
+				{Docid: 0, Position: 5},
+				{Docid: 1, Position: 0},
+				{Docid: 1, Position: 13},
+				{Docid: 2, Position: 0},
+			},
+		},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := idx.QueryPositionalFold(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if diff := cmp.Diff(tt.want, got); diff != "" {
-				t.Errorf("FiveLines(%s): unexpected diff (-want +got):\n%s", tt.query, diff)
+				t.Errorf("QueryPositionalFold(%q): unexpected diff (-want +got):\n%s", tt.query, diff)
 			}
 		})
 	}
@@ -0,0 +1,105 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/Debian/dcs/internal/mmap"
+)
+
+// Like docid.map, the optional record files of an index (symbols,
+// docid.aliases) consist of \n-separated text records, followed by the byte
+// offsets of each record and, lastly, the offset of the byte offsets.
+
+type recordWriter struct {
+	cw      countingWriter
+	offsets []uint32
+}
+
+func newRecordWriter(path string) (*recordWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recordWriter{cw: newCountingWriter(f)}, nil
+}
+
+// add appends a record formatted according to format, which must not contain
+// a newline.
+func (rw *recordWriter) add(format string, args ...any) error {
+	rw.offsets = append(rw.offsets, uint32(rw.cw.offset))
+	_, err := fmt.Fprintf(&rw.cw, format+"\n", args...)
+	return err
+}
+
+func (rw *recordWriter) Close() error {
+	indexStart := uint32(rw.cw.offset)
+	if err := binary.Write(&rw.cw, binary.LittleEndian, rw.offsets); err != nil {
+		return err
+	}
+	if err := binary.Write(&rw.cw, binary.LittleEndian, indexStart); err != nil {
+		return err
+	}
+	return rw.cw.Close()
+}
+
+// recordReader is safe for concurrent use.
+type recordReader struct {
+	f           *mmap.File
+	name        string
+	indexOffset uint32
+	count       int
+}
+
+// openRecords returns nil if path does not exist.
+func openRecords(path, name string) (*recordReader, error) {
+	f, err := mmap.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(f.Data) < 4 {
+		f.Close()
+		return nil, fmt.Errorf("%s: truncated", name)
+	}
+	indexOffset := binary.LittleEndian.Uint32(f.Data[len(f.Data)-4:])
+	return &recordReader{
+		f:           f,
+		name:        name,
+		indexOffset: indexOffset,
+		count:       int(uint32(len(f.Data))-indexOffset-4) / 4,
+	}, nil
+}
+
+// offset returns the byte offset of the i-th record, or (for i == count) of
+// the record offsets.
+func (rr *recordReader) offset(i int) uint32 {
+	return binary.LittleEndian.Uint32(rr.f.Data[int(rr.indexOffset)+i*4:])
+}
+
+// record returns the i-th record, without its trailing newline.
+func (rr *recordReader) record(i int) []byte {
+	return rr.f.Data[rr.offset(i) : rr.offset(i+1)-1]
+}
+
+// verify verifies the record offsets, so that record can be called for all
+// records without panicking.
+func (rr *recordReader) verify() error {
+	if int64(rr.indexOffset)+int64(rr.count)*4+4 != int64(len(rr.f.Data)) {
+		return corrupt(rr.name, NoTrigram, int64(rr.indexOffset), "record offsets do not end at the end of the file")
+	}
+	for n := 0; n < rr.count; n++ {
+		offset, next := rr.offset(n), rr.offset(n+1)
+		if offset >= next || next > rr.indexOffset || rr.f.Data[next-1] != '\n' {
+			return corrupt(rr.name, NoTrigram, int64(offset), "record %d: invalid offsets [%d, %d)", n, offset, next)
+		}
+	}
+	return nil
+}
+
+func (rr *recordReader) Close() error {
+	return rr.f.Close()
+}
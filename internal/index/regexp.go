@@ -337,6 +337,12 @@ func RegexpQuery(re *syntax.Regexp) *Query {
 	return info.match
 }
 
+// And returns a Query which matches the documents matched by both q and r,
+// e.g. to look for files containing matches of several regexps.
+func (q *Query) And(r *Query) *Query {
+	return q.and(r)
+}
+
 // A regexpInfo summarizes the results of analyzing a regexp.
 type regexpInfo struct {
 	// canEmpty records whether the regexp matches the empty string
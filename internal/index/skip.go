@@ -0,0 +1,244 @@
+package index
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Debian/dcs/internal/mmap"
+	"github.com/Debian/dcs/internal/turbopfor"
+)
+
+// A skip table allows decoding only those TurboPFor blocks of a docid posting
+// list which can contain a given docid. For each posting list which spans more
+// than one block (of 256 deltas, the last block may be shorter),
+// posting.docid.skip contains one skipEntry per block, and
+// posting.docid.skip.meta contains a MetaEntry pointing to them (with Entries
+// being the number of blocks).
+//
+// Both files are optional: indexes without them are intersected linearly.
+type skipEntry struct {
+	Last   uint32 // last (largest) docid of the block
+	Offset uint32 // byte offset of the block, relative to MetaEntry.OffsetData
+}
+
+const skipEntrySize = 8
+
+const blockSize = 256 // number of deltas per TurboPFor block, see pforWriter
+
+type skipWriter struct {
+	metaf *os.File
+	meta  *bufio.Writer
+	data  countingWriter
+
+	start  int64 // MetaEntry.OffsetData of the current posting list
+	n      int   // number of deltas in the current posting list
+	docid  uint32
+	blocks []skipEntry
+	buf    [metaEntrySize]byte
+}
+
+func newSkipWriter(dir string) (*skipWriter, error) {
+	metaf, err := os.Create(filepath.Join(dir, "posting.docid.skip.meta"))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, "posting.docid.skip"))
+	if err != nil {
+		metaf.Close()
+		return nil, err
+	}
+	return &skipWriter{
+		metaf: metaf,
+		meta:  bufio.NewWriter(metaf),
+		data:  newCountingWriter(f),
+	}, nil
+}
+
+// Reset starts a new posting list at offset start of the docid data file.
+func (sw *skipWriter) Reset(start int64) {
+	sw.start = start
+	sw.n = 0
+	sw.docid = 0
+	sw.blocks = sw.blocks[:0]
+}
+
+// add records delta, which is about to be written at offset of the docid data
+// file.
+func (sw *skipWriter) add(delta uint32, offset int64) {
+	if sw.n%blockSize == 0 {
+		sw.blocks = append(sw.blocks, skipEntry{Offset: uint32(offset - sw.start)})
+	}
+	sw.docid += delta
+	sw.blocks[len(sw.blocks)-1].Last = sw.docid
+	sw.n++
+}
+
+// Flush writes the skip table of the current posting list (for trigram t), if
+// it spans more than one block.
+func (sw *skipWriter) Flush(t Trigram) error {
+	if len(sw.blocks) < 2 {
+		return nil
+	}
+	me := MetaEntry{
+		Trigram:    t,
+		Entries:    uint32(len(sw.blocks)),
+		OffsetData: int64(sw.data.offset),
+	}
+	me.Marshal(sw.buf[:])
+	if _, err := sw.meta.Write(sw.buf[:]); err != nil {
+		return err
+	}
+	for _, b := range sw.blocks {
+		encoding.PutUint32(sw.buf[:], b.Last)
+		encoding.PutUint32(sw.buf[4:], b.Offset)
+		if _, err := sw.data.Write(sw.buf[:skipEntrySize]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sw *skipWriter) Close() error {
+	if err := sw.meta.Flush(); err != nil {
+		return err
+	}
+	if err := sw.metaf.Close(); err != nil {
+		return err
+	}
+	return sw.data.Close()
+}
+
+type skipReader struct {
+	meta *mmap.File
+	data *mmap.File
+}
+
+// newSkipReader returns nil if the index in dir has no skip table.
+func newSkipReader(dir string) (*skipReader, error) {
+	var sr skipReader
+	var err error
+	if sr.meta, err = mmap.Open(filepath.Join(dir, "posting.docid.skip.meta")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if sr.data, err = mmap.Open(filepath.Join(dir, "posting.docid.skip")); err != nil {
+		sr.meta.Close()
+		return nil, err
+	}
+	return &sr, nil
+}
+
+// metaEntry returns the MetaEntry of trigram t and its index within
+// posting.docid.skip.meta, or -1 if t has no skip table.
+func (sr *skipReader) metaEntry(t Trigram) (MetaEntry, int) {
+	var meta MetaEntry
+	d := sr.meta.Data
+	num := len(d) / metaEntrySize
+	n := sort.Search(num, func(i int) bool {
+		// MetaEntry.Trigram is the first member
+		return Trigram(encoding.Uint32(d[i*metaEntrySize:])) >= t
+	})
+	if n >= num {
+		return meta, -1
+	}
+	meta.Unmarshal(d[n*metaEntrySize:])
+	if meta.Trigram != t {
+		return meta, -1
+	}
+	return meta, n
+}
+
+// blocks returns the skip entries of trigram t (encoded, skipEntrySize bytes
+// each), or nil if t has no skip table.
+func (sr *skipReader) blocks(t Trigram) []byte {
+	meta, n := sr.metaEntry(t)
+	if n == -1 {
+		return nil
+	}
+	return sr.data.Data[meta.OffsetData : meta.OffsetData+int64(meta.Entries)*skipEntrySize]
+}
+
+func (sr *skipReader) Close() error {
+	if err := sr.meta.Close(); err != nil {
+		return err
+	}
+	return sr.data.Close()
+}
+
+// gallop returns the smallest index in [lo, n) for which pred (which must be
+// false up to some index and true afterwards) returns true, or n. It probes
+// exponentially increasing distances from lo first, so that advancing by a few
+// elements is cheap.
+func gallop(lo, n int, pred func(int) bool) int {
+	step := 1
+	hi := lo
+	for hi < n && !pred(hi) {
+		lo = hi + 1
+		hi += step
+		step *= 2
+	}
+	if hi > n {
+		hi = n
+	}
+	return lo + sort.Search(hi-lo, func(i int) bool { return pred(lo + i) })
+}
+
+// postingAndSkip intersects list with the docid posting list of t, decoding
+// only the blocks which can contain docids of list. It returns false if t has
+// no skip table or list is too long for skipping to pay off.
+func (ix *Index) postingAndSkip(list []uint32, t Trigram) ([]uint32, bool) {
+	if ix.docidSkip == nil {
+		return nil, false
+	}
+	blocks := ix.docidSkip.blocks(t)
+	num := len(blocks) / skipEntrySize
+	// Decoding a block costs about as much as checking blockSize docids of
+	// list, so only skip when most blocks can be left alone:
+	if num == 0 || len(list) > num/4 {
+		return nil, false
+	}
+	var meta MetaEntry
+	if !ix.Docid.metaEntry1(&meta, t) {
+		return nil, true
+	}
+	last := func(k int) uint32 { return encoding.Uint32(blocks[k*skipEntrySize:]) }
+	d := ix.Docid.data.Data
+	buf := make([]uint32, blockSize, turbopfor.DecodingSize(blockSize))
+	var block []uint32
+	decoded := -1
+	result := make([]uint32, 0, len(list))
+	k := 0
+	for _, docid := range list {
+		k = gallop(k, num, func(j int) bool { return last(j) >= docid })
+		if k == num {
+			break
+		}
+		if k != decoded {
+			offset := meta.OffsetData + int64(encoding.Uint32(blocks[k*skipEntrySize+4:]))
+			if remaining := int(meta.Entries) - k*blockSize; remaining < blockSize {
+				block = buf[:remaining]
+				turbopfor.P4dec32(d[offset:], block)
+			} else {
+				block = buf[:blockSize]
+				turbopfor.P4dec256v32(d[offset:], block)
+			}
+			var prev uint32
+			if k > 0 {
+				prev = last(k - 1)
+			}
+			for i, delta := range block {
+				prev += delta
+				block[i] = prev
+			}
+			decoded = k
+		}
+		if i := sort.Search(len(block), func(i int) bool { return block[i] >= docid }); i < len(block) && block[i] == docid {
+			result = append(result, docid)
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,79 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGallop(t *testing.T) {
+	list := []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}
+	for _, tt := range []struct {
+		lo, want int
+		x        int
+	}{
+		{0, 0, 0},
+		{0, 1, 2},
+		{0, 9, 19},
+		{0, 10, 20},
+		{3, 3, 2},
+		{3, 5, 10},
+	} {
+		if got := gallop(tt.lo, len(list), func(i int) bool { return list[i] >= tt.x }); got != tt.want {
+			t.Errorf("gallop(%d, >= %d) = %d, want %d", tt.lo, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestPostingAndSkip(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	files := make(map[string]string)
+	const numFiles = 3000 // com spans 11 blocks
+	for i := 0; i < numFiles; i++ {
+		// Each file has distinct contents, as identical files share a docid.
+		content := fmt.Sprintf("common %d\n", i)
+		if i%7 == 0 {
+			content = fmt.Sprintf("other %d\n", i)
+		}
+		files[fmt.Sprintf("f%04d.txt", i)] = content
+	}
+	writeFiles(t, srcDir, files)
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	idx, err := Open(idxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	if err := idx.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	com := Trigram('c'<<16 | 'o'<<8 | 'm')
+	for _, tt := range []struct {
+		list []uint32
+		want []uint32
+	}{
+		{[]uint32{7}, []uint32{}},                  // full block, missing
+		{[]uint32{300, 2999}, []uint32{300, 2999}}, // full block, last block
+		{[]uint32{2996, 2998}, []uint32{2998}},     // last block
+	} {
+		got, ok := idx.postingAndSkip(tt.list, com)
+		if !ok {
+			t.Fatalf("postingAndSkip(%v) did not use the skip table", tt.list)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("postingAndSkip(%v): unexpected diff (-want +got):\n%s", tt.list, diff)
+		}
+	}
+
+	// Too many docids for skipping to pay off:
+	if _, ok := idx.postingAndSkip([]uint32{1, 2, 3, 4, 5, 6}, com); ok {
+		t.Errorf("postingAndSkip unexpectedly used the skip table")
+	}
+}
@@ -0,0 +1,263 @@
+package index
+
+import (
+	"bytes"
+	"cmp"
+	"container/heap"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/Debian/dcs/internal/symbols"
+)
+
+// The symbols file lists the definitions (see package symbols) in all
+// documents of an index, sorted by name, docid and line. Like docid.map, it
+// consists of \n-separated records (“name\tkind\tdocid\tline”), followed by
+// the byte offsets of each record and, lastly, the offset of the byte offsets.
+//
+// The file is optional: indexes without it contain no definitions.
+const symbolsFile = "symbols"
+
+// A Definition is a symbol defined in line Line of document Docid.
+type Definition struct {
+	Name  string
+	Kind  symbols.Kind
+	Docid uint32
+	Line  uint32
+}
+
+func compareDefinitions(a, b Definition) int {
+	if c := cmp.Compare(a.Name, b.Name); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Docid, b.Docid); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Line, b.Line)
+}
+
+func parseDefinition(b []byte) (Definition, error) {
+	fields := bytes.Split(b, []byte{'\t'})
+	if len(fields) != 4 || len(fields[1]) != 1 {
+		return Definition{}, fmt.Errorf("malformed symbol record %q", b)
+	}
+	docid, err := strconv.ParseUint(string(fields[2]), 10, 32)
+	if err != nil {
+		return Definition{}, err
+	}
+	line, err := strconv.ParseUint(string(fields[3]), 10, 32)
+	if err != nil {
+		return Definition{}, err
+	}
+	return Definition{
+		Name:  string(fields[0]),
+		Kind:  symbols.Kind(fields[1][0]),
+		Docid: uint32(docid),
+		Line:  uint32(line),
+	}, nil
+}
+
+func writeSymbols(dir string, defs []Definition) error {
+	slices.SortFunc(defs, compareDefinitions)
+	rw, err := newRecordWriter(filepath.Join(dir, symbolsFile))
+	if err != nil {
+		return err
+	}
+	for _, d := range defs {
+		if err := addDefinition(rw, d); err != nil {
+			return err
+		}
+	}
+	return rw.Close()
+}
+
+// addDefinition appends d, which must not sort before the previously added
+// definition.
+func addDefinition(rw *recordWriter, d Definition) error {
+	return rw.add("%s\t%c\t%d\t%d", d.Name, d.Kind, d.Docid, d.Line)
+}
+
+// symbolReader is safe for concurrent use.
+type symbolReader struct {
+	*recordReader
+}
+
+// newSymbolReader returns nil if the index in dir has no symbols file.
+func newSymbolReader(dir string) (*symbolReader, error) {
+	rr, err := openRecords(filepath.Join(dir, symbolsFile), symbolsFile)
+	if rr == nil || err != nil {
+		return nil, err
+	}
+	return &symbolReader{rr}, nil
+}
+
+func (sr *symbolReader) name(i int) []byte {
+	r := sr.record(i)
+	if idx := bytes.IndexByte(r, '\t'); idx > -1 {
+		return r[:idx]
+	}
+	return r
+}
+
+func (sr *symbolReader) lookup(name string) ([]Definition, error) {
+	if sr == nil {
+		return nil, nil
+	}
+	n := sort.Search(sr.count, func(i int) bool {
+		return string(sr.name(i)) >= name
+	})
+	var defs []Definition
+	for ; n < sr.count && string(sr.name(n)) == name; n++ {
+		d, err := parseDefinition(sr.record(n))
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, d)
+	}
+	return defs, nil
+}
+
+// Definitions returns the definitions of the symbol name, sorted by docid.
+func (i *Index) Definitions(name string) ([]Definition, error) {
+	defs, err := i.symbols.lookup(name)
+	if err != nil || i.delta == nil {
+		return defs, err
+	}
+	if len(i.tombstones) > 0 {
+		filtered := defs[:0]
+		for _, d := range defs {
+			if !i.deleted(d.Docid) {
+				filtered = append(filtered, d)
+			}
+		}
+		defs = filtered
+	}
+	delta, err := i.delta.symbols.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	base := uint32(i.DocidMap.Count)
+	for _, d := range delta {
+		d.Docid += base
+		defs = append(defs, d)
+	}
+	return defs, nil
+}
+
+// symbolCursor points to the next definition of an index which is being
+// merged.
+type symbolCursor struct {
+	sr    *symbolReader
+	next  int
+	remap *docidRemap
+	def   Definition
+}
+
+type symbolHeap []*symbolCursor
+
+func (h symbolHeap) Len() int           { return len(h) }
+func (h symbolHeap) Less(i, j int) bool { return compareDefinitions(h[i].def, h[j].def) < 0 }
+func (h symbolHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *symbolHeap) Push(x any)        { *h = append(*h, x.(*symbolCursor)) }
+func (h *symbolHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// advance reads the next definition, returning false once all definitions
+// were read.
+func (c *symbolCursor) advance() (bool, error) {
+	for ; c.next < c.sr.count; c.next++ {
+		d, err := parseDefinition(c.sr.record(c.next))
+		if err != nil {
+			return false, err
+		}
+		var kept bool
+		if d.Docid, kept = c.remap.lookup(d.Docid); !kept {
+			continue // the first copy contains the same definitions
+		}
+		c.def = d
+		c.next++
+		return true, nil
+	}
+	return false, nil
+}
+
+// mergeSymbols merges the symbols files of srcdirs into destdir, mapping their
+// docids according to remaps.
+func mergeSymbols(destdir string, srcdirs []string, remaps []docidRemap) error {
+	var h symbolHeap
+	for idx, dir := range srcdirs {
+		sr, err := newSymbolReader(dir)
+		if err != nil {
+			return err
+		}
+		if sr == nil {
+			continue
+		}
+		defer sr.Close()
+		c := &symbolCursor{sr: sr, remap: &remaps[idx]}
+		ok, err := c.advance()
+		if err != nil {
+			return fmt.Errorf("%s: %v", dir, err)
+		}
+		if ok {
+			h = append(h, c)
+		}
+	}
+	heap.Init(&h)
+
+	rw, err := newRecordWriter(filepath.Join(destdir, symbolsFile))
+	if err != nil {
+		return err
+	}
+	for len(h) > 0 {
+		c := h[0]
+		if err := addDefinition(rw, c.def); err != nil {
+			return err
+		}
+		ok, err := c.advance()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return rw.Close()
+}
+
+// verifySymbols verifies that the symbols file (if any) is sorted and only
+// refers to documents of the index.
+func (i *Index) verifySymbols() error {
+	sr := i.symbols
+	if sr == nil {
+		return nil
+	}
+	if err := sr.verify(); err != nil {
+		return err
+	}
+	var prev Definition
+	for n := 0; n < sr.count; n++ {
+		offset := int64(sr.offset(n))
+		d, err := parseDefinition(sr.record(n))
+		if err != nil {
+			return corrupt(symbolsFile, NoTrigram, offset, "%v", err)
+		}
+		if d.Docid >= uint32(i.DocidMap.Count) {
+			return corrupt(symbolsFile, NoTrigram, offset, "docid %d outside of docid map [0, %d)", d.Docid, i.DocidMap.Count)
+		}
+		if n > 0 && compareDefinitions(prev, d) > 0 {
+			return corrupt(symbolsFile, NoTrigram, offset, "record %q sorts before its predecessor", sr.record(n))
+		}
+		prev = d
+	}
+	return nil
+}
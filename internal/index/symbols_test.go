@@ -0,0 +1,73 @@
+package index
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Debian/dcs/internal/symbols"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDefinitions(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src1 := filepath.Join(tmpDir, "src1")
+	writeFiles(t, src1, map[string]string{
+		"a.c": "int helper(void);\n\nint\nhelper(void) {\n\treturn 0;\n}\n",
+		"b.c": "int main() {\n\treturn helper();\n}\n",
+	})
+	idx1 := filepath.Join(tmpDir, "idx1")
+	createIndex(t, src1, idx1)
+
+	src2 := filepath.Join(tmpDir, "src2")
+	writeFiles(t, src2, map[string]string{
+		"c.py": "def helper():\n    pass\n",
+	})
+	idx2 := filepath.Join(tmpDir, "idx2")
+	createIndex(t, src2, idx2)
+
+	merged := filepath.Join(tmpDir, "merged")
+	if err := ConcatN(merged, []string{idx1, idx2}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := Open(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.Verify(); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		want []Definition
+	}{
+		{
+			name: "helper",
+			want: []Definition{
+				{Name: "helper", Kind: symbols.Function, Docid: 0, Line: 4},
+				{Name: "helper", Kind: symbols.Function, Docid: 2, Line: 1},
+			},
+		},
+		{
+			name: "main",
+			want: []Definition{
+				{Name: "main", Kind: symbols.Function, Docid: 1, Line: 1},
+			},
+		},
+		{
+			name: "nonexistent",
+			want: nil,
+		},
+	} {
+		got, err := idx.Definitions(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("Definitions(%q): unexpected diff (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/Debian/dcs/internal/turbopfor"
+)
+
+// NoTrigram is used as CorruptionError.Trigram for corruptions which do not
+// concern a specific trigram. Trigrams only use 24 bits.
+const NoTrigram = Trigram(0xFFFFFFFF)
+
+// A CorruptionError describes the first inconsistency which Verify found.
+type CorruptionError struct {
+	File    string  // index file, e.g. posting.docid.meta
+	Trigram Trigram // affected trigram, or NoTrigram
+	Offset  int64   // byte offset within File
+	Err     error
+}
+
+func (e *CorruptionError) Error() string {
+	if e.Trigram == NoTrigram {
+		return fmt.Sprintf("%s: offset %d: %v", e.File, e.Offset, e.Err)
+	}
+	t := []byte{byte(e.Trigram >> 16), byte(e.Trigram >> 8), byte(e.Trigram)}
+	return fmt.Sprintf("%s: trigram %q (%d) at offset %d: %v", e.File, t, e.Trigram, e.Offset, e.Err)
+}
+
+func (e *CorruptionError) Unwrap() error { return e.Err }
+
+func corrupt(file string, t Trigram, offset int64, format string, args ...interface{}) error {
+	return &CorruptionError{
+		File:    file,
+		Trigram: t,
+		Offset:  offset,
+		Err:     fmt.Errorf(format, args...),
+	}
+}
+
+// Verify checks the consistency of all index sections (and of the delta index,
+// if any) and returns a *CorruptionError describing the first inconsistency.
+//
+// Verify decodes every posting list, so it takes about as long as reading the
+// entire index from disk.
+func (i *Index) Verify() error {
+	if err := i.verifyDocidMap(); err != nil {
+		return err
+	}
+	if err := i.verifyDedup(); err != nil {
+		return err
+	}
+	if err := i.verifySymbols(); err != nil {
+		return err
+	}
+	for _, s := range []struct {
+		file    string
+		meta    []byte
+		dataLen int
+	}{
+		{"posting.docid.meta", i.Docid.meta.Data, len(i.Docid.data.Data)},
+		{"posting.pos.meta", i.Pos.meta.Data, len(i.Pos.data.Data)},
+		{"posting.posrel.meta", i.Posrel.meta.Data, len(i.Posrel.data.Data)},
+	} {
+		if err := verifyMeta(s.file, s.meta, s.dataLen); err != nil {
+			return err
+		}
+	}
+	if i.docidSkip != nil {
+		if err := verifyMeta("posting.docid.skip.meta", i.docidSkip.meta.Data, len(i.docidSkip.data.Data)); err != nil {
+			return err
+		}
+		if err := i.verifySkipBounds(); err != nil {
+			return err
+		}
+	}
+	var v verifier
+	if err := v.verifyDocids(i); err != nil {
+		return err
+	}
+	if err := v.verifyPos(i); err != nil {
+		return err
+	}
+	// Checksums catch corruptions which leave the structure intact:
+	if err := i.verifyChecksums(); err != nil {
+		return err
+	}
+	if i.delta != nil {
+		return i.delta.Verify()
+	}
+	return nil
+}
+
+func (i *Index) verifyDocidMap() error {
+	const file = "docid.map"
+	d := i.DocidMap.f.Data
+	if len(d) < 4 {
+		return corrupt(file, NoTrigram, 0, "file too short (%d bytes)", len(d))
+	}
+	indexOffset := int64(i.DocidMap.indexOffset)
+	if indexOffset > int64(len(d)-4) || (int64(len(d)-4)-indexOffset)%4 != 0 {
+		return corrupt(file, NoTrigram, int64(len(d)-4), "invalid index offset %d for file size %d", indexOffset, len(d))
+	}
+	// The index consists of the name offsets of all Count documents, followed
+	// by the index offset itself, which terminates the last name.
+	var prev uint32
+	for off := indexOffset; off < int64(len(d)); off += 4 {
+		cur := binary.LittleEndian.Uint32(d[off:])
+		if off == indexOffset && cur != 0 {
+			return corrupt(file, NoTrigram, off, "first name offset is %d, want 0", cur)
+		}
+		if off > indexOffset && cur <= prev {
+			return corrupt(file, NoTrigram, off, "name offset %d does not follow previous name offset %d", cur, prev)
+		}
+		if int64(cur) > indexOffset {
+			return corrupt(file, NoTrigram, off, "name offset %d beyond index offset %d", cur, indexOffset)
+		}
+		prev = cur
+	}
+	return nil
+}
+
+// verifyMeta verifies that meta entries are sorted by trigram and that their
+// offsets are monotonic and within the bounds of the corresponding data file.
+func verifyMeta(file string, meta []byte, dataLen int) error {
+	if rest := len(meta) % metaEntrySize; rest != 0 {
+		return corrupt(file, NoTrigram, int64(len(meta)-rest), "file size %d is not a multiple of %d", len(meta), metaEntrySize)
+	}
+	var prev, e MetaEntry
+	for j := 0; j < len(meta)/metaEntrySize; j++ {
+		e.Unmarshal(meta[j*metaEntrySize:])
+		off := int64(j * metaEntrySize)
+		if j > 0 && e.Trigram <= prev.Trigram {
+			return corrupt(file, e.Trigram, off, "trigram does not follow previous trigram %d", prev.Trigram)
+		}
+		if j > 0 && e.OffsetData < prev.OffsetData {
+			return corrupt(file, e.Trigram, off, "data offset %d precedes previous data offset %d", e.OffsetData, prev.OffsetData)
+		}
+		if e.OffsetData < 0 || e.OffsetData > int64(dataLen) {
+			return corrupt(file, e.Trigram, off, "data offset %d outside of data file [0, %d]", e.OffsetData, dataLen)
+		}
+		prev = e
+	}
+	return nil
+}
+
+// verifier holds the buffers which are re-used across posting lists.
+type verifier struct {
+	in    []byte
+	out   reusableBuffer
+	block []uint32
+}
+
+// decode decodes entries deltas from block and returns the number of bytes
+// which the decoder consumed.
+func (v *verifier) decode(block []byte, entries int) (deltas []uint32, read int, err error) {
+	if entries == 0 {
+		return nil, 0, nil
+	}
+	if len(block) == 0 {
+		return nil, 0, fmt.Errorf("no data for %d entries", entries)
+	}
+	// recover cannot catch a crash inside the cgo decoder, so reject blocks
+	// which cannot hold entries before decoding them: each block of blockSize
+	// entries takes at least one header byte, and the encoder never writes
+	// more than turbopfor.EncodingSize bytes.
+	if min, max := (entries+blockSize-1)/blockSize, turbopfor.EncodingSize(entries); len(block) < min || len(block) > max {
+		return nil, 0, fmt.Errorf("%d bytes for %d entries, want [%d, %d] bytes", len(block), entries, min, max)
+	}
+	// Corrupt blocks can make the decoder panic (or read past its input, see
+	// turbopfor.DecodingSize), so decode from a sufficiently padded copy:
+	if n := len(block) + turbopfor.DecodingSize(entries); cap(v.in) < n {
+		v.in = make([]byte, 0, n)
+	}
+	in := append(v.in[:0], block...)
+	in = in[:cap(in)]
+	for j := len(block); j < len(in); j++ {
+		in[j] = 0
+	}
+	v.in = in
+	if n := turbopfor.DecodingSize(entries); n > cap(v.out.u) {
+		v.out.u = make([]uint32, 0, n)
+	}
+	deltas = v.out.u[:entries]
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("decoding %d entries failed: %v", entries, r)
+		}
+	}()
+	return deltas, turbopfor.P4ndec256v32(in, deltas), nil
+}
+
+// verifySkipOffsets verifies that the skip entries point to the start of each
+// block of the posting list which decode last decoded (blockLen bytes).
+func (v *verifier) verifySkipOffsets(skip []byte, entries, blockLen int) error {
+	if len(v.block) == 0 {
+		v.block = make([]uint32, blockSize, turbopfor.DecodingSize(blockSize))
+	}
+	var off int
+	for b := 0; b < len(skip)/skipEntrySize; b++ {
+		if got := int(encoding.Uint32(skip[b*skipEntrySize+4:])); got != off {
+			return fmt.Errorf("block %d starts at offset %d, but skip entry specifies %d", b, off, got)
+		}
+		if off >= blockLen {
+			return fmt.Errorf("block %d starts at offset %d, beyond the end of the posting list (%d bytes)", b, off, blockLen)
+		}
+		if n := entries - b*blockSize; n < blockSize {
+			off += turbopfor.P4dec32(v.in[off:], v.block[:n])
+		} else {
+			off += turbopfor.P4dec256v32(v.in[off:], v.block)
+		}
+	}
+	return nil
+}
+
+// nextOffset returns the data offset of the entry following entry j, or
+// dataLen for the last entry.
+func nextOffset(meta []byte, j, dataLen int) int64 {
+	if j+1 < len(meta)/metaEntrySize {
+		var next MetaEntry
+		next.Unmarshal(meta[(j+1)*metaEntrySize:])
+		return next.OffsetData
+	}
+	return int64(dataLen)
+}
+
+func (v *verifier) verifyDocids(i *Index) error {
+	const file = "posting.docid.turbopfor"
+	meta, data := i.Docid.meta.Data, i.Docid.data.Data
+	count := uint64(i.DocidMap.Count)
+	var e MetaEntry
+	for j := 0; j < len(meta)/metaEntrySize; j++ {
+		e.Unmarshal(meta[j*metaEntrySize:])
+		if e.Entries == 0 || uint64(e.Entries) > count {
+			return corrupt("posting.docid.meta", e.Trigram, int64(j*metaEntrySize), "%d entries, want [1, %d]", e.Entries, count)
+		}
+		block := data[e.OffsetData:nextOffset(meta, j, len(data))]
+		deltas, read, err := v.decode(block, int(e.Entries))
+		if err != nil {
+			return corrupt(file, e.Trigram, e.OffsetData, "%v", err)
+		}
+		if read != len(block) {
+			return corrupt(file, e.Trigram, e.OffsetData, "decoding %d entries consumed %d of %d bytes", e.Entries, read, len(block))
+		}
+		var skip []byte
+		var skipMeta MetaEntry
+		if i.docidSkip != nil {
+			var n int
+			skipMeta, n = i.docidSkip.metaEntry(e.Trigram)
+			blocks := (int(e.Entries) + blockSize - 1) / blockSize
+			if n == -1 && blocks > 1 {
+				return corrupt("posting.docid.skip.meta", e.Trigram, 0, "no skip table for %d blocks", blocks)
+			}
+			if n > -1 && int(skipMeta.Entries) != blocks {
+				return corrupt("posting.docid.skip.meta", e.Trigram, int64(n*metaEntrySize), "%d skip entries for %d blocks", skipMeta.Entries, blocks)
+			}
+			skip = i.docidSkip.blocks(e.Trigram)
+		}
+		if err := v.verifySkipOffsets(skip, int(e.Entries), len(block)); err != nil {
+			return corrupt("posting.docid.skip", e.Trigram, skipMeta.OffsetData, "%v", err)
+		}
+		var docid uint64
+		for k, delta := range deltas {
+			if k > 0 && delta == 0 {
+				return corrupt(file, e.Trigram, e.OffsetData, "docid %d (entry %d) repeats", docid, k)
+			}
+			docid += uint64(delta)
+			if docid >= count {
+				return corrupt(file, e.Trigram, e.OffsetData, "docid %d (entry %d) outside of docid map [0, %d)", docid, k, count)
+			}
+			if len(skip) > 0 && (k%blockSize == blockSize-1 || k == len(deltas)-1) {
+				if last := encoding.Uint32(skip[(k/blockSize)*skipEntrySize:]); uint64(last) != docid {
+					return corrupt("posting.docid.skip", e.Trigram, skipMeta.OffsetData+int64((k/blockSize)*skipEntrySize), "block %d ends with docid %d, but skip entry specifies %d", k/blockSize, docid, last)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// verifySkipBounds verifies that all skip tables are within the bounds of
+// posting.docid.skip.
+func (i *Index) verifySkipBounds() error {
+	meta, data := i.docidSkip.meta.Data, i.docidSkip.data.Data
+	var e MetaEntry
+	for j := 0; j < len(meta)/metaEntrySize; j++ {
+		e.Unmarshal(meta[j*metaEntrySize:])
+		if end := e.OffsetData + int64(e.Entries)*skipEntrySize; end > int64(len(data)) {
+			return corrupt("posting.docid.skip.meta", e.Trigram, int64(j*metaEntrySize), "%d skip entries end at offset %d, beyond end of data file (%d bytes)", e.Entries, end, len(data))
+		}
+	}
+	return nil
+}
+
+func (v *verifier) verifyPos(i *Index) error {
+	const file = "posting.pos.turbopfor"
+	meta, data := i.Pos.meta.Data, i.Pos.data.Data
+	relMeta, relData := i.Posrel.meta.Data, i.Posrel.data.Data
+	if len(meta) != len(relMeta) {
+		return corrupt("posting.posrel.meta", NoTrigram, int64(min(len(meta), len(relMeta))), "%d entries, but posting.pos.meta has %d", len(relMeta)/metaEntrySize, len(meta)/metaEntrySize)
+	}
+	var e, rel, docidMeta MetaEntry
+	for j := 0; j < len(meta)/metaEntrySize; j++ {
+		e.Unmarshal(meta[j*metaEntrySize:])
+		rel.Unmarshal(relMeta[j*metaEntrySize:])
+		if rel.Trigram != e.Trigram {
+			return corrupt("posting.posrel.meta", rel.Trigram, int64(j*metaEntrySize), "trigram differs from posting.pos.meta trigram %d", e.Trigram)
+		}
+		if !i.Docid.metaEntry1(&docidMeta, e.Trigram) {
+			return corrupt("posting.pos.meta", e.Trigram, int64(j*metaEntrySize), "trigram missing from posting.docid.meta")
+		}
+
+		if e.Entries == 0 {
+			return corrupt("posting.pos.meta", e.Trigram, int64(j*metaEntrySize), "0 positions")
+		}
+
+		// posrel contains one bit per position, set when the docid changes:
+		posrel := relData[rel.OffsetData:nextOffset(relMeta, j, len(relData))]
+		if want := (int(e.Entries) + 7) / 8; len(posrel) != want {
+			return corrupt("posting.posrel.data", e.Trigram, rel.OffsetData, "%d bytes for %d positions, want %d bytes", len(posrel), e.Entries, want)
+		}
+		var ones int
+		for _, b := range posrel {
+			ones += bits.OnesCount8(b)
+		}
+		if ones != int(docidMeta.Entries) {
+			return corrupt("posting.posrel.data", e.Trigram, rel.OffsetData, "%d docid changes, but posting.docid.meta has %d docids", ones, docidMeta.Entries)
+		}
+		if len(posrel) == 0 || posrel[0]&1 == 0 {
+			return corrupt("posting.posrel.data", e.Trigram, rel.OffsetData, "first position does not start a docid")
+		}
+
+		block := data[e.OffsetData:nextOffset(meta, j, len(data))]
+		_, read, err := v.decode(block, int(e.Entries))
+		if err != nil {
+			return corrupt(file, e.Trigram, e.OffsetData, "%v", err)
+		}
+		if read != len(block) {
+			return corrupt(file, e.Trigram, e.OffsetData, "decoding %d entries consumed %d of %d bytes", e.Entries, read, len(block))
+		}
+	}
+	return nil
+}
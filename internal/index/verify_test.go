@@ -0,0 +1,122 @@
+package index
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	writeFiles(t, srcDir, map[string]string{
+		"file1.txt": "abc def ghi",
+		"file2.txt": "abc jkl mno",
+		"file3.txt": strings.Repeat("abc xyz\n", 100),
+	})
+	idxDir := filepath.Join(tmpDir, "idx")
+	createIndex(t, srcDir, idxDir)
+
+	verify := func(t *testing.T, dir string) error {
+		t.Helper()
+		idx, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		return idx.Verify()
+	}
+
+	if err := verify(t, idxDir); err != nil {
+		t.Fatalf("Verify(%s) = %v, want nil", idxDir, err)
+	}
+
+	abc := Trigram('a'<<16 | 'b'<<8 | 'c')
+	for _, tt := range []struct {
+		name    string
+		file    string
+		corrupt func(b []byte, at int)
+		want    CorruptionError
+	}{
+		{
+			name: "unsorted",
+			file: "posting.docid.meta",
+			corrupt: func(b []byte, at int) {
+				// duplicate abc into the following entry
+				copy(b[at+metaEntrySize:], b[at:at+4])
+			},
+			want: CorruptionError{File: "posting.docid.meta", Trigram: abc},
+		},
+
+		{
+			name: "offset",
+			file: "posting.pos.meta",
+			corrupt: func(b []byte, at int) {
+				encoding.PutUint64(b[at+8:], 1<<40)
+			},
+			want: CorruptionError{File: "posting.pos.meta", Trigram: abc},
+		},
+
+		{
+			name: "entries",
+			file: "posting.pos.meta",
+			corrupt: func(b []byte, at int) {
+				encoding.PutUint32(b[at+4:], encoding.Uint32(b[at+4:])+8)
+			},
+			want: CorruptionError{File: "posting.posrel.data", Trigram: abc},
+		},
+
+		{
+			name: "no entries",
+			file: "posting.pos.meta",
+			corrupt: func(b []byte, at int) {
+				encoding.PutUint32(b[at+4:], 0)
+			},
+			want: CorruptionError{File: "posting.pos.meta", Trigram: abc},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(tmpDir, tt.name)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			files, err := os.ReadDir(idxDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				b, err := os.ReadFile(filepath.Join(idxDir, f.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if f.Name() == tt.file {
+					at := -1
+					for j := 0; j < len(b); j += metaEntrySize {
+						if Trigram(encoding.Uint32(b[j:])) == abc {
+							at = j
+							break
+						}
+					}
+					if at == -1 || at+metaEntrySize >= len(b) {
+						t.Fatalf("trigram abc not found (or last) in %s", tt.file)
+					}
+					tt.corrupt(b, at)
+				}
+				if err := os.WriteFile(filepath.Join(dir, f.Name()), b, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = verify(t, dir)
+			var ce *CorruptionError
+			if !errors.As(err, &ce) {
+				t.Fatalf("Verify(%s) = %v, want *CorruptionError", dir, err)
+			}
+			if ce.File != tt.want.File || ce.Trigram != tt.want.Trigram {
+				t.Errorf("Verify(%s) = %v, want corruption of trigram %d in %s", dir, err, tt.want.Trigram, tt.want.File)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package index
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// WhitespaceRegexp returns a regular expression which matches the literal
+// query with every run of whitespace replaced by any (non-empty) run of
+// whitespace, e.g. “if (x)  {” matches “if (x) {” and “if (x)\t{”.
+//
+// Note that \s matches line breaks, which only matters if the matcher does not
+// operate on individual lines (see regexp.Grep.Multiline).
+func WhitespaceRegexp(literal string) (*syntax.Regexp, error) {
+	fragments := strings.Fields(literal)
+	if len(fragments) == 0 {
+		return nil, fmt.Errorf("%q consists of whitespace only", literal)
+	}
+	quoted := make([]string, len(fragments))
+	for idx, fragment := range fragments {
+		quoted[idx] = regexp.QuoteMeta(fragment)
+	}
+	return syntax.Parse(strings.Join(quoted, `\s+`), syntax.Perl)
+}
+
+// WhitespaceQuery returns the trigram query for WhitespaceRegexp(literal): the
+// trigrams of every whitespace-free fragment of literal, which do not depend
+// on the whitespace in between.
+func WhitespaceQuery(literal string) *Query {
+	query := &Query{Op: QAll}
+	for _, fragment := range strings.Fields(literal) {
+		re, err := syntax.Parse(regexp.QuoteMeta(fragment), syntax.Perl)
+		if err != nil {
+			// Cannot happen: QuoteMeta returns a valid regular expression.
+			return &Query{Op: QAll}
+		}
+		query = query.And(RegexpQuery(re))
+	}
+	return query
+}
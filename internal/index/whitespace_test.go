@@ -0,0 +1,42 @@
+package index
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestWhitespaceRegexp(t *testing.T) {
+	const literal = "if (x == 1)  {\n\treturn"
+	re, err := WhitespaceRegexp(literal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := WhitespaceQuery(literal)
+	if query.Op != QAnd {
+		t.Errorf("WhitespaceQuery(%q) = %v, want a trigram query", literal, query)
+	}
+	matcher := regexp.MustCompile(re.String())
+	for _, tt := range []struct {
+		text string
+		want bool
+	}{
+		{"if (x == 1) { return", true},
+		{"if  (x\t==\t1)\n{\n\treturn", true},
+		{"\tif (x == 1) {return", false},
+		{"if (x==1) { return", false},
+		{"if (x == 12) { return", false},
+	} {
+		if got := matcher.MatchString(tt.text); got != tt.want {
+			t.Errorf("%v matches %q = %v, want %v", re, tt.text, got, tt.want)
+		}
+		if tt.want && !queryMatches(query, tt.text) {
+			t.Errorf("WhitespaceQuery(%q) does not match %q", literal, tt.text)
+		}
+	}
+
+	for _, literal := range []string{"", " \t\n"} {
+		if _, err := WhitespaceRegexp(literal); err == nil {
+			t.Errorf("WhitespaceRegexp(%q) unexpectedly succeeded", literal)
+		}
+	}
+}
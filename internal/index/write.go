@@ -2,6 +2,7 @@ package index
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Debian/dcs/internal/symbols"
 	"github.com/google/codesearch/sparse"
 )
 
@@ -53,6 +55,12 @@ type Writer struct {
 	dir   string
 	index map[Trigram][]entry
 	docs  []string
+	defs  []Definition
+
+	hashes  []contentHash          // per docid
+	byHash  map[contentHash]uint32 // content hash → docid
+	aliases []alias
+
 	set   *sparse.Set // efficiently reset across AddFile calls
 	inbuf []byte
 }
@@ -63,10 +71,11 @@ func Create(dir string) (*Writer, error) {
 		return nil, err
 	}
 	return &Writer{
-		dir:   dir,
-		index: make(map[Trigram][]entry),
-		set:   sparse.NewSet(maxTrigram),
-		inbuf: make([]byte, 16384),
+		dir:    dir,
+		index:  make(map[Trigram][]entry),
+		byHash: make(map[contentHash]uint32),
+		set:    sparse.NewSet(maxTrigram),
+		inbuf:  make([]byte, 16384),
 	}, nil
 }
 
@@ -146,10 +155,14 @@ const (
 	maxTextTrigrams = 20000
 )
 
+// AddFile adds the file fn to the index under the specified name. If a file
+// with identical contents was added before, name becomes an alias of that
+// file’s docid (see Index.Aliases) instead.
 func (w *Writer) AddFile(fn, name string) error {
 	w.set.Reset()
 	docid := uint32(len(w.docs))
 	w.docs = append(w.docs, name)
+	w.hashes = append(w.hashes, contentHash{})
 	f, err := os.Open(fn)
 	if err != nil {
 		return err
@@ -175,6 +188,7 @@ func (w *Writer) AddFile(fn, name string) error {
 		linelen = 0
 		buf     = w.inbuf[:0]
 		entries = make([]uint64, st.Size()-2)
+		h       = sha256.New()
 	)
 	for {
 		tv = (tv << 8) & (1<<24 - 1)
@@ -190,6 +204,7 @@ func (w *Writer) AddFile(fn, name string) error {
 				return errors.New("0-length read")
 			}
 			buf = buf[:n]
+			h.Write(buf)
 			i = 0
 		}
 		c = buf[i]
@@ -212,10 +227,34 @@ func (w *Writer) AddFile(fn, name string) error {
 	if w.set.Len() > maxTextTrigrams {
 		return errors.New("too many trigrams, probably not text, ignoring")
 	}
+	var sum contentHash
+	copy(sum[:], h.Sum(nil))
+	if first, ok := w.byHash[sum]; ok {
+		w.docs = w.docs[:docid]
+		w.hashes = w.hashes[:docid]
+		w.aliases = append(w.aliases, alias{docid: first, name: name})
+		return nil
+	}
+	w.byHash[sum] = docid
+	w.hashes[docid] = sum
 	for _, e := range entries {
 		t := Trigram(e >> 32)
 		w.index[t] = append(w.index[t], entry{docid: docid, position: uint32(e)})
 	}
+	if symbols.Supported(name) {
+		b, err := os.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		for _, s := range symbols.Extract(name, b) {
+			w.defs = append(w.defs, Definition{
+				Name:  s.Name,
+				Kind:  s.Kind,
+				Docid: docid,
+				Line:  uint32(s.Line),
+			})
+		}
+	}
 	return nil
 }
 
@@ -243,7 +282,19 @@ func (w *Writer) Flush() error {
 		return err
 	}
 
-	return nil
+	if err := writeSymbols(w.dir, w.defs); err != nil {
+		return err
+	}
+
+	if err := writeHashes(w.dir, w.hashes); err != nil {
+		return err
+	}
+
+	if err := writeAliases(w.dir, w.aliases); err != nil {
+		return err
+	}
+
+	return writeManifest(w.dir)
 }
 
 // writeDocidMap creates the index’s docid.map file, which is a list of
@@ -284,6 +335,11 @@ func (w *Writer) writeDocid(trigrams []Trigram) error {
 		return err
 	}
 	defer dw.Close()
+	sw, err := newSkipWriter(w.dir)
+	if err != nil {
+		return err
+	}
+	dw.skip = sw
 	for _, t := range trigrams {
 		//log.Printf("trigram %c%c%c (docid)", (t>>16)&0xFF, (t>>8)&0xFF, (t>>0)&0xFF)
 		entries := w.index[t]
@@ -292,6 +348,7 @@ func (w *Writer) writeDocid(trigrams []Trigram) error {
 			Entries:    1,
 			OffsetData: dw.Offset(),
 		}
+		sw.Reset(me.OffsetData)
 
 		// The first entry will always be stored, even if 0
 		prev := entries[0].docid
@@ -313,6 +370,9 @@ func (w *Writer) writeDocid(trigrams []Trigram) error {
 		if err := dw.Flush(); err != nil {
 			return err
 		}
+		if err := sw.Flush(t); err != nil {
+			return err
+		}
 
 		if err := binary.Write(bufw, binary.LittleEndian, &me); err != nil {
 			return err
@@ -321,6 +381,9 @@ func (w *Writer) writeDocid(trigrams []Trigram) error {
 	if err := dw.Close(); err != nil {
 		return err
 	}
+	if err := sw.Close(); err != nil {
+		return err
+	}
 	if err := bufw.Flush(); err != nil {
 		return err
 	}
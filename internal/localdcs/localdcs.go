@@ -406,6 +406,7 @@ func Start(args ...string) (*Instance, error) {
 			"-template_pattern=cmd/dcs-web/templates/*",
 			"-static_path=static/",
 			"-source_backends=" + sourceBackend,
+			"-use_positional_index",
 			"-tls_cert_path=" + filepath.Join(*localdcsPath, "cert.pem"),
 			"-tls_key_path=" + filepath.Join(*localdcsPath, "key.pem"),
 			"-listen_address=" + *listenWeb,
@@ -42,5 +42,8 @@ func Open(path string) (*File, error) {
 }
 
 func (f *File) Close() error {
+	if f.orig == nil {
+		return nil // empty files are not mapped
+	}
 	return unix.Munmap(f.orig)
 }
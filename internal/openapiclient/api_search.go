@@ -32,13 +32,25 @@ Performs a search through the full Debian Code Search corpus, blocking until all
  * @param ctx context.Context - for authentication, logging, cancellation, deadlines, tracing, etc. Passed from http.Request or context.Background().
  * @param query The search query, for example &#x60;who knows...&#x60; (literal) or &#x60;who knows\\.\\.\\.&#x60; (regular expression). See https://codesearch.debian.net/faq for more details about which keywords are supported. The regular expression flavor is RE2, see https://github.com/google/re2/blob/master/doc/syntax.txt
  * @param optional nil or *SearchApiSearchOpts - Optional Parameters:
-     * @param "MatchMode" (optional.String) -  Whether the query is to be interpreted as a literal (&#x60;literal&#x60;) instead of as an RE2 regular expression (&#x60;regexp&#x60;). Literal searches are faster and do not require escaping special characters, regular expression searches are more powerful.
+     * @param "MatchMode" (optional.String) -  Whether the query is to be interpreted as a literal (&#x60;literal&#x60;) instead of as an RE2 regular expression (&#x60;regexp&#x60;). Literal searches are faster and do not require escaping special characters, regular expression searches are more powerful. Identifier searches (&#x60;identifier&#x60;) match the query in any identifier style, e.g. &#x60;getUserName&#x60; also matches &#x60;get_user_name&#x60;, &#x60;GetUserName&#x60;, &#x60;get-user-name&#x60; and &#x60;GETUSERNAME&#x60;.
+     * @param "ContextBefore" (optional.Int32) -  Number of full lines before each search result to return in &#x60;context_before&#x60; (at most 10).
+     * @param "ContextAfter" (optional.Int32) -  Number of full lines after each search result to return in &#x60;context_after&#x60; (at most 10).
+     * @param "AllOccurrences" (optional.Bool) -  Whether to return one search result per occurrence of the query (with only that occurrence in &#x60;ranges&#x60;) instead of one per line, e.g. to count call sites. The total number of occurrences is returned in the X-Codesearch-Occurrences header.
+     * @param "IgnoreWhitespace" (optional.Bool) -  Whether every run of whitespace in the query matches any run of whitespace, e.g. &#x60;if (x) {&#x60; also matches &#x60;if  (x)  {&#x60;. The query is interpreted as a literal, unless &#x60;match_mode&#x60; is &#x60;identifier&#x60;.
+     * @param "IgnoreLineBreaks" (optional.Bool) -  Like &#x60;ignore_whitespace&#x60; (which it implies), but whitespace also matches line breaks, so that matches can span lines. Search results are returned for the first line of each match.
+     * @param "MaxResults" (optional.Int32) -  If non-zero, return only (approximately) the best &#x60;max_results&#x60; search results, which is much faster for queries with many results. The X-Codesearch-Occurrences header then only counts the occurrences within the best results. Results are ranked slightly differently than without &#x60;max_results&#x60; (how well each line matches is weighted the same for all queries instead of relative to the first result), so the best results can differ from the first page of the same query without &#x60;max_results&#x60;.
 
 @return []SearchResult
 */
 
 type SearchApiSearchOpts struct {
-	MatchMode optional.String
+	MatchMode        optional.String
+	ContextBefore    optional.Int32
+	ContextAfter     optional.Int32
+	AllOccurrences   optional.Bool
+	IgnoreWhitespace optional.Bool
+	IgnoreLineBreaks optional.Bool
+	MaxResults       optional.Int32
 }
 
 func (a *SearchApiService) Search(ctx context.Context, query string, localVarOptionals *SearchApiSearchOpts) ([]SearchResult, *http.Response, error) {
@@ -61,6 +73,24 @@ func (a *SearchApiService) Search(ctx context.Context, query string, localVarOpt
 	if localVarOptionals != nil && localVarOptionals.MatchMode.IsSet() {
 		localVarQueryParams.Add("match_mode", parameterToString(localVarOptionals.MatchMode.Value(), ""))
 	}
+	if localVarOptionals != nil && localVarOptionals.ContextBefore.IsSet() {
+		localVarQueryParams.Add("context_before", parameterToString(localVarOptionals.ContextBefore.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.ContextAfter.IsSet() {
+		localVarQueryParams.Add("context_after", parameterToString(localVarOptionals.ContextAfter.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.AllOccurrences.IsSet() {
+		localVarQueryParams.Add("all_occurrences", parameterToString(localVarOptionals.AllOccurrences.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.IgnoreWhitespace.IsSet() {
+		localVarQueryParams.Add("ignore_whitespace", parameterToString(localVarOptionals.IgnoreWhitespace.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.IgnoreLineBreaks.IsSet() {
+		localVarQueryParams.Add("ignore_line_breaks", parameterToString(localVarOptionals.IgnoreLineBreaks.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.MaxResults.IsSet() {
+		localVarQueryParams.Add("max_results", parameterToString(localVarOptionals.MaxResults.Value(), ""))
+	}
 	// to determine the Content-Type header
 	localVarHttpContentTypes := []string{}
 
@@ -142,13 +172,25 @@ The search results are currently sorted arbitrarily, but we intend to sort them
  * @param ctx context.Context - for authentication, logging, cancellation, deadlines, tracing, etc. Passed from http.Request or context.Background().
  * @param query The search query, for example &#x60;who knows...&#x60; (literal) or &#x60;who knows\\.\\.\\.&#x60; (regular expression). See https://codesearch.debian.net/faq for more details about which keywords are supported. The regular expression flavor is RE2, see https://github.com/google/re2/blob/master/doc/syntax.txt
  * @param optional nil or *SearchApiSearchperpackageOpts - Optional Parameters:
-     * @param "MatchMode" (optional.String) -  Whether the query is to be interpreted as a literal (&#x60;literal&#x60;) instead of as an RE2 regular expression (&#x60;regexp&#x60;). Literal searches are faster and do not require escaping special characters, regular expression searches are more powerful.
+     * @param "MatchMode" (optional.String) -  Whether the query is to be interpreted as a literal (&#x60;literal&#x60;) instead of as an RE2 regular expression (&#x60;regexp&#x60;). Literal searches are faster and do not require escaping special characters, regular expression searches are more powerful. Identifier searches (&#x60;identifier&#x60;) match the query in any identifier style, e.g. &#x60;getUserName&#x60; also matches &#x60;get_user_name&#x60;, &#x60;GetUserName&#x60;, &#x60;get-user-name&#x60; and &#x60;GETUSERNAME&#x60;.
+     * @param "ContextBefore" (optional.Int32) -  Number of full lines before each search result to return in &#x60;context_before&#x60; (at most 10).
+     * @param "ContextAfter" (optional.Int32) -  Number of full lines after each search result to return in &#x60;context_after&#x60; (at most 10).
+     * @param "AllOccurrences" (optional.Bool) -  Whether to return one search result per occurrence of the query (with only that occurrence in &#x60;ranges&#x60;) instead of one per line, e.g. to count call sites. The total number of occurrences is returned in the X-Codesearch-Occurrences header.
+     * @param "IgnoreWhitespace" (optional.Bool) -  Whether every run of whitespace in the query matches any run of whitespace, e.g. &#x60;if (x) {&#x60; also matches &#x60;if  (x)  {&#x60;. The query is interpreted as a literal, unless &#x60;match_mode&#x60; is &#x60;identifier&#x60;.
+     * @param "IgnoreLineBreaks" (optional.Bool) -  Like &#x60;ignore_whitespace&#x60; (which it implies), but whitespace also matches line breaks, so that matches can span lines. Search results are returned for the first line of each match.
+     * @param "MaxResults" (optional.Int32) -  If non-zero, return only (approximately) the best &#x60;max_results&#x60; search results, which is much faster for queries with many results. The X-Codesearch-Occurrences header then only counts the occurrences within the best results. Results are ranked slightly differently than without &#x60;max_results&#x60; (how well each line matches is weighted the same for all queries instead of relative to the first result), so the best results can differ from the first page of the same query without &#x60;max_results&#x60;.
 
 @return []PackageSearchResult
 */
 
 type SearchApiSearchperpackageOpts struct {
-	MatchMode optional.String
+	MatchMode        optional.String
+	ContextBefore    optional.Int32
+	ContextAfter     optional.Int32
+	AllOccurrences   optional.Bool
+	IgnoreWhitespace optional.Bool
+	IgnoreLineBreaks optional.Bool
+	MaxResults       optional.Int32
 }
 
 func (a *SearchApiService) Searchperpackage(ctx context.Context, query string, localVarOptionals *SearchApiSearchperpackageOpts) ([]PackageSearchResult, *http.Response, error) {
@@ -171,6 +213,24 @@ func (a *SearchApiService) Searchperpackage(ctx context.Context, query string, l
 	if localVarOptionals != nil && localVarOptionals.MatchMode.IsSet() {
 		localVarQueryParams.Add("match_mode", parameterToString(localVarOptionals.MatchMode.Value(), ""))
 	}
+	if localVarOptionals != nil && localVarOptionals.ContextBefore.IsSet() {
+		localVarQueryParams.Add("context_before", parameterToString(localVarOptionals.ContextBefore.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.ContextAfter.IsSet() {
+		localVarQueryParams.Add("context_after", parameterToString(localVarOptionals.ContextAfter.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.AllOccurrences.IsSet() {
+		localVarQueryParams.Add("all_occurrences", parameterToString(localVarOptionals.AllOccurrences.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.IgnoreWhitespace.IsSet() {
+		localVarQueryParams.Add("ignore_whitespace", parameterToString(localVarOptionals.IgnoreWhitespace.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.IgnoreLineBreaks.IsSet() {
+		localVarQueryParams.Add("ignore_line_breaks", parameterToString(localVarOptionals.IgnoreLineBreaks.Value(), ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.MaxResults.IsSet() {
+		localVarQueryParams.Add("max_results", parameterToString(localVarOptionals.MaxResults.Value(), ""))
+	}
 	// to determine the Content-Type header
 	localVarHttpContentTypes := []string{}
 
@@ -0,0 +1,23 @@
+/*
+ * Debian Code Search
+ *
+ * OpenAPI for https://codesearch.debian.net/
+ *
+ * API version: 1.4.0
+ * Contact: stapelberg@debian.org
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package swagger
+
+// Position of a match within a line, both as byte offsets and as rune (Unicode code point) offsets.
+type MatchRange struct {
+	// Byte offset of the first byte of the match.
+	Start int32 `json:"start"`
+	// Byte offset after the last byte of the match.
+	End int32 `json:"end"`
+	// Rune offset of the first rune of the match.
+	StartRune int32 `json:"start_rune"`
+	// Rune offset after the last rune of the match.
+	EndRune int32 `json:"end_rune"`
+}
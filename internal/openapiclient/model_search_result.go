@@ -18,10 +18,14 @@ type SearchResult struct {
 	Path string `json:"path"`
 	// Line number containing the search result.
 	Line int32 `json:"line"`
-	// Up to 2 full lines before the search result (see `context`).
+	// Up to `context_before` (default 2) full lines before the search result (see `context`).
 	ContextBefore []string `json:"context_before,omitempty"`
-	// The full line containing the search result.
+	// The full line containing the search result, as it appears in the file (i.e. not HTML-escaped).
 	Context string `json:"context"`
-	// Up to 2 full lines after the search result (see `context`).
+	// Positions of all matches of the query within `context` (only of this occurrence with `all_occurrences`), e.g. for highlighting.
+	Ranges []MatchRange `json:"ranges,omitempty"`
+	// Up to `context_after` (default 2) full lines after the search result (see `context`).
 	ContextAfter []string `json:"context_after,omitempty"`
+	// Paths of further files (e.g. vendored copies of a library in other packages) whose contents are identical to `path`, and which hence contain the same search result.
+	Duplicates []string `json:"duplicates,omitempty"`
 }
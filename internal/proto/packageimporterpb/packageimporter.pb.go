@@ -212,6 +212,10 @@ type MergeRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	// If true, only packages which were imported or garbage collected since the
+	// last full merge are merged into a (small) delta index.
+	Incremental bool `protobuf:"varint,1,opt,name=incremental,proto3" json:"incremental,omitempty"`
 }
 
 func (x *MergeRequest) Reset() {
@@ -246,6 +250,13 @@ func (*MergeRequest) Descriptor() ([]byte, []int) {
 	return file_packageimporter_proto_rawDescGZIP(), []int{4}
 }
 
+func (x *MergeRequest) GetIncremental() bool {
+	if x != nil {
+		return x.Incremental
+	}
+	return false
+}
+
 type MergeReply struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -369,6 +380,148 @@ func (*GarbageCollectReply) Descriptor() ([]byte, []int) {
 	return file_packageimporter_proto_rawDescGZIP(), []int{7}
 }
 
+type PackageSuites struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SourcePackage string   `protobuf:"bytes,1,opt,name=source_package,json=sourcePackage,proto3" json:"source_package,omitempty"` // e.g. “i3-wm_4.13”
+	Suite         []string `protobuf:"bytes,2,rep,name=suite,proto3" json:"suite,omitempty"`                                      // e.g. “sid”, “stable-backports”
+}
+
+func (x *PackageSuites) Reset() {
+	*x = PackageSuites{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_packageimporter_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PackageSuites) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PackageSuites) ProtoMessage() {}
+
+func (x *PackageSuites) ProtoReflect() protoreflect.Message {
+	mi := &file_packageimporter_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PackageSuites.ProtoReflect.Descriptor instead.
+func (*PackageSuites) Descriptor() ([]byte, []int) {
+	return file_packageimporter_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PackageSuites) GetSourcePackage() string {
+	if x != nil {
+		return x.SourcePackage
+	}
+	return ""
+}
+
+func (x *PackageSuites) GetSuite() []string {
+	if x != nil {
+		return x.Suite
+	}
+	return nil
+}
+
+type SetSuitesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// All packages of this package importer instance which are part of at least
+	// one of the fed suites. Replaces any previously set suites.
+	Package []*PackageSuites `protobuf:"bytes,1,rep,name=package,proto3" json:"package,omitempty"`
+}
+
+func (x *SetSuitesRequest) Reset() {
+	*x = SetSuitesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_packageimporter_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetSuitesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetSuitesRequest) ProtoMessage() {}
+
+func (x *SetSuitesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_packageimporter_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetSuitesRequest.ProtoReflect.Descriptor instead.
+func (*SetSuitesRequest) Descriptor() ([]byte, []int) {
+	return file_packageimporter_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SetSuitesRequest) GetPackage() []*PackageSuites {
+	if x != nil {
+		return x.Package
+	}
+	return nil
+}
+
+type SetSuitesReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *SetSuitesReply) Reset() {
+	*x = SetSuitesReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_packageimporter_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetSuitesReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetSuitesReply) ProtoMessage() {}
+
+func (x *SetSuitesReply) ProtoReflect() protoreflect.Message {
+	mi := &file_packageimporter_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetSuitesReply.ProtoReflect.Descriptor instead.
+func (*SetSuitesReply) Descriptor() ([]byte, []int) {
+	return file_packageimporter_proto_rawDescGZIP(), []int{10}
+}
+
 var File_packageimporter_proto protoreflect.FileDescriptor
 
 var file_packageimporter_proto_rawDesc = []byte{
@@ -387,41 +540,59 @@ var file_packageimporter_proto_rawDesc = []byte{
 	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e,
 	0x74, 0x65, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74,
 	0x65, 0x6e, 0x74, 0x22, 0x0d, 0x0a, 0x0b, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x70,
-	0x6c, 0x79, 0x22, 0x0e, 0x0a, 0x0c, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x22, 0x0c, 0x0a, 0x0a, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79,
-	0x22, 0x3e, 0x0a, 0x15, 0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65,
-	0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x6f, 0x75,
-	0x72, 0x63, 0x65, 0x5f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x0d, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
-	0x22, 0x15, 0x0a, 0x13, 0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65,
-	0x63, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x32, 0xe6, 0x02, 0x0a, 0x0f, 0x50, 0x61, 0x63, 0x6b,
-	0x61, 0x67, 0x65, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x12, 0x52, 0x0a, 0x08, 0x50,
-	0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x12, 0x22, 0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67,
-	0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x50, 0x61, 0x63, 0x6b,
-	0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x70, 0x61,
+	0x6c, 0x79, 0x22, 0x30, 0x0a, 0x0c, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x69, 0x6e, 0x63, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x61,
+	0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x69, 0x6e, 0x63, 0x72, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x61, 0x6c, 0x22, 0x0c, 0x0a, 0x0a, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x70,
+	0x6c, 0x79, 0x22, 0x3e, 0x0a, 0x15, 0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c,
+	0x6c, 0x65, 0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x25, 0x0a, 0x0e, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0d, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61,
+	0x67, 0x65, 0x22, 0x15, 0x0a, 0x13, 0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c,
+	0x6c, 0x65, 0x63, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x4c, 0x0a, 0x0d, 0x50, 0x61, 0x63,
+	0x6b, 0x61, 0x67, 0x65, 0x53, 0x75, 0x69, 0x74, 0x65, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x5f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x75, 0x69, 0x74, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x05, 0x73, 0x75, 0x69, 0x74, 0x65, 0x22, 0x4e, 0x0a, 0x10, 0x53, 0x65, 0x74, 0x53, 0x75,
+	0x69, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x07, 0x70,
+	0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x70,
+	0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62,
+	0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x53, 0x75, 0x69, 0x74, 0x65, 0x73, 0x52, 0x07,
+	0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x22, 0x10, 0x0a, 0x0e, 0x53, 0x65, 0x74, 0x53, 0x75,
+	0x69, 0x74, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x32, 0xbd, 0x03, 0x0a, 0x0f, 0x50, 0x61,
+	0x63, 0x6b, 0x61, 0x67, 0x65, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x12, 0x52, 0x0a,
+	0x08, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x12, 0x22, 0x2e, 0x70, 0x61, 0x63, 0x6b,
+	0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x50, 0x61,
+	0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e,
+	0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70,
+	0x62, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22,
+	0x00, 0x12, 0x4e, 0x0a, 0x06, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x20, 0x2e, 0x70, 0x61,
 	0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e,
-	0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12,
-	0x4e, 0x0a, 0x06, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x20, 0x2e, 0x70, 0x61, 0x63, 0x6b,
-	0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x49, 0x6d,
-	0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x70, 0x61,
+	0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e,
+	0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70,
+	0x62, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x28,
+	0x01, 0x12, 0x49, 0x0a, 0x05, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x12, 0x1f, 0x2e, 0x70, 0x61, 0x63,
+	0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x4d,
+	0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x70, 0x61,
 	0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e,
-	0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x28, 0x01, 0x12,
-	0x49, 0x0a, 0x05, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x12, 0x1f, 0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61,
-	0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x4d, 0x65, 0x72,
-	0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x70, 0x61, 0x63, 0x6b,
-	0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x4d, 0x65,
-	0x72, 0x67, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x64, 0x0a, 0x0e, 0x47, 0x61,
-	0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x12, 0x28, 0x2e, 0x70,
-	0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62,
-	0x2e, 0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x26, 0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
-	0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x47, 0x61, 0x72, 0x62, 0x61,
-	0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00,
-	0x42, 0x38, 0x5a, 0x36, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x44,
-	0x65, 0x62, 0x69, 0x61, 0x6e, 0x2f, 0x64, 0x63, 0x73, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e,
-	0x61, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
-	0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x4d, 0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x64, 0x0a, 0x0e,
+	0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x12, 0x28,
+	0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72,
+	0x70, 0x62, 0x2e, 0x47, 0x61, 0x72, 0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x26, 0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61,
+	0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x47, 0x61, 0x72,
+	0x62, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x22, 0x00, 0x12, 0x55, 0x0a, 0x09, 0x53, 0x65, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x73, 0x12,
+	0x23, 0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65,
+	0x72, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d,
+	0x70, 0x6f, 0x72, 0x74, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x74, 0x53, 0x75, 0x69, 0x74,
+	0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x42, 0x38, 0x5a, 0x36, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x44, 0x65, 0x62, 0x69, 0x61, 0x6e, 0x2f, 0x64,
+	0x63, 0x73, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65,
+	0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -436,7 +607,7 @@ func file_packageimporter_proto_rawDescGZIP() []byte {
 	return file_packageimporter_proto_rawDescData
 }
 
-var file_packageimporter_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_packageimporter_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_packageimporter_proto_goTypes = []interface{}{
 	(*PackagesRequest)(nil),       // 0: packageimporterpb.PackagesRequest
 	(*PackagesReply)(nil),         // 1: packageimporterpb.PackagesReply
@@ -446,21 +617,27 @@ var file_packageimporter_proto_goTypes = []interface{}{
 	(*MergeReply)(nil),            // 5: packageimporterpb.MergeReply
 	(*GarbageCollectRequest)(nil), // 6: packageimporterpb.GarbageCollectRequest
 	(*GarbageCollectReply)(nil),   // 7: packageimporterpb.GarbageCollectReply
+	(*PackageSuites)(nil),         // 8: packageimporterpb.PackageSuites
+	(*SetSuitesRequest)(nil),      // 9: packageimporterpb.SetSuitesRequest
+	(*SetSuitesReply)(nil),        // 10: packageimporterpb.SetSuitesReply
 }
 var file_packageimporter_proto_depIdxs = []int32{
-	0, // 0: packageimporterpb.PackageImporter.Packages:input_type -> packageimporterpb.PackagesRequest
-	2, // 1: packageimporterpb.PackageImporter.Import:input_type -> packageimporterpb.ImportRequest
-	4, // 2: packageimporterpb.PackageImporter.Merge:input_type -> packageimporterpb.MergeRequest
-	6, // 3: packageimporterpb.PackageImporter.GarbageCollect:input_type -> packageimporterpb.GarbageCollectRequest
-	1, // 4: packageimporterpb.PackageImporter.Packages:output_type -> packageimporterpb.PackagesReply
-	3, // 5: packageimporterpb.PackageImporter.Import:output_type -> packageimporterpb.ImportReply
-	5, // 6: packageimporterpb.PackageImporter.Merge:output_type -> packageimporterpb.MergeReply
-	7, // 7: packageimporterpb.PackageImporter.GarbageCollect:output_type -> packageimporterpb.GarbageCollectReply
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	8,  // 0: packageimporterpb.SetSuitesRequest.package:type_name -> packageimporterpb.PackageSuites
+	0,  // 1: packageimporterpb.PackageImporter.Packages:input_type -> packageimporterpb.PackagesRequest
+	2,  // 2: packageimporterpb.PackageImporter.Import:input_type -> packageimporterpb.ImportRequest
+	4,  // 3: packageimporterpb.PackageImporter.Merge:input_type -> packageimporterpb.MergeRequest
+	6,  // 4: packageimporterpb.PackageImporter.GarbageCollect:input_type -> packageimporterpb.GarbageCollectRequest
+	9,  // 5: packageimporterpb.PackageImporter.SetSuites:input_type -> packageimporterpb.SetSuitesRequest
+	1,  // 6: packageimporterpb.PackageImporter.Packages:output_type -> packageimporterpb.PackagesReply
+	3,  // 7: packageimporterpb.PackageImporter.Import:output_type -> packageimporterpb.ImportReply
+	5,  // 8: packageimporterpb.PackageImporter.Merge:output_type -> packageimporterpb.MergeReply
+	7,  // 9: packageimporterpb.PackageImporter.GarbageCollect:output_type -> packageimporterpb.GarbageCollectReply
+	10, // 10: packageimporterpb.PackageImporter.SetSuites:output_type -> packageimporterpb.SetSuitesReply
+	6,  // [6:11] is the sub-list for method output_type
+	1,  // [1:6] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_packageimporter_proto_init() }
@@ -565,6 +742,42 @@ func file_packageimporter_proto_init() {
 				return nil
 			}
 		}
+		file_packageimporter_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PackageSuites); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_packageimporter_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetSuitesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_packageimporter_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetSuitesReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -572,7 +785,7 @@ func file_packageimporter_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_packageimporter_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -24,6 +24,9 @@ type PackageImporterClient interface {
 	Import(ctx context.Context, opts ...grpc.CallOption) (PackageImporter_ImportClient, error)
 	Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*MergeReply, error)
 	GarbageCollect(ctx context.Context, in *GarbageCollectRequest, opts ...grpc.CallOption) (*GarbageCollectReply, error)
+	// SetSuites records which Debian suites contain which source packages, so
+	// that searches can be restricted to suites (suite: keyword).
+	SetSuites(ctx context.Context, in *SetSuitesRequest, opts ...grpc.CallOption) (*SetSuitesReply, error)
 }
 
 type packageImporterClient struct {
@@ -95,6 +98,15 @@ func (c *packageImporterClient) GarbageCollect(ctx context.Context, in *GarbageC
 	return out, nil
 }
 
+func (c *packageImporterClient) SetSuites(ctx context.Context, in *SetSuitesRequest, opts ...grpc.CallOption) (*SetSuitesReply, error) {
+	out := new(SetSuitesReply)
+	err := c.cc.Invoke(ctx, "/packageimporterpb.PackageImporter/SetSuites", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PackageImporterServer is the server API for PackageImporter service.
 // All implementations must embed UnimplementedPackageImporterServer
 // for forward compatibility
@@ -105,6 +117,9 @@ type PackageImporterServer interface {
 	Import(PackageImporter_ImportServer) error
 	Merge(context.Context, *MergeRequest) (*MergeReply, error)
 	GarbageCollect(context.Context, *GarbageCollectRequest) (*GarbageCollectReply, error)
+	// SetSuites records which Debian suites contain which source packages, so
+	// that searches can be restricted to suites (suite: keyword).
+	SetSuites(context.Context, *SetSuitesRequest) (*SetSuitesReply, error)
 	mustEmbedUnimplementedPackageImporterServer()
 }
 
@@ -124,6 +139,9 @@ func (UnimplementedPackageImporterServer) Merge(context.Context, *MergeRequest)
 func (UnimplementedPackageImporterServer) GarbageCollect(context.Context, *GarbageCollectRequest) (*GarbageCollectReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GarbageCollect not implemented")
 }
+func (UnimplementedPackageImporterServer) SetSuites(context.Context, *SetSuitesRequest) (*SetSuitesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSuites not implemented")
+}
 func (UnimplementedPackageImporterServer) mustEmbedUnimplementedPackageImporterServer() {}
 
 // UnsafePackageImporterServer may be embedded to opt out of forward compatibility for this service.
@@ -217,6 +235,24 @@ func _PackageImporter_GarbageCollect_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PackageImporter_SetSuites_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSuitesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PackageImporterServer).SetSuites(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/packageimporterpb.PackageImporter/SetSuites",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PackageImporterServer).SetSuites(ctx, req.(*SetSuitesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // PackageImporter_ServiceDesc is the grpc.ServiceDesc for PackageImporter service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -236,6 +272,10 @@ var PackageImporter_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GarbageCollect",
 			Handler:    _PackageImporter_GarbageCollect_Handler,
 		},
+		{
+			MethodName: "SetSuites",
+			Handler:    _PackageImporter_SetSuites_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
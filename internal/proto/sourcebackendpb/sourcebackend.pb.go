@@ -65,7 +65,7 @@ func (x SearchReply_Type) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use SearchReply_Type.Descriptor instead.
 func (SearchReply_Type) EnumDescriptor() ([]byte, []int) {
-	return file_sourcebackend_proto_rawDescGZIP(), []int{5, 0}
+	return file_sourcebackend_proto_rawDescGZIP(), []int{6, 0}
 }
 
 type FileRequest struct {
@@ -167,11 +167,37 @@ type SearchRequest struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
+	// An empty query searches file names: the backend returns one match (with
+	// line 0) per file which passes the keyword filters of rewritten_url.
 	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
 	// Rewritten URL (after RewriteQuery()) with all the parameters that
 	// are relevant for ranking.
 	RewrittenUrl string `protobuf:"bytes,2,opt,name=rewritten_url,json=rewrittenUrl,proto3" json:"rewritten_url,omitempty"`
 	Literal      bool   `protobuf:"varint,3,opt,name=literal,proto3" json:"literal,omitempty"`
+	// Number of lines of context to return before and after each match, 2 if
+	// unset. The source backend caps both at its -max_context_lines.
+	ContextBefore *uint32 `protobuf:"varint,4,opt,name=context_before,json=contextBefore,proto3,oneof" json:"context_before,omitempty"`
+	ContextAfter  *uint32 `protobuf:"varint,5,opt,name=context_after,json=contextAfter,proto3,oneof" json:"context_after,omitempty"`
+	// Return one match per occurrence (with only that occurrence in ranges)
+	// instead of one match per line.
+	AllOccurrences bool `protobuf:"varint,6,opt,name=all_occurrences,json=allOccurrences,proto3" json:"all_occurrences,omitempty"`
+	// Interpret the query as an identifier which matches in any identifier
+	// style, e.g. getUserName as get_user_name (see index.IdentifierRegexp).
+	// Takes precedence over literal.
+	Identifier bool `protobuf:"varint,7,opt,name=identifier,proto3" json:"identifier,omitempty"`
+	// Interpret the query as a literal in which every run of whitespace matches
+	// any run of whitespace (see index.WhitespaceRegexp).
+	IgnoreWhitespace bool `protobuf:"varint,8,opt,name=ignore_whitespace,json=ignoreWhitespace,proto3" json:"ignore_whitespace,omitempty"`
+	// Like ignore_whitespace, but whitespace also matches line breaks, i.e.
+	// matches can span lines. Implies ignore_whitespace.
+	IgnoreLineBreaks bool `protobuf:"varint,9,opt,name=ignore_line_breaks,json=ignoreLineBreaks,proto3" json:"ignore_line_breaks,omitempty"`
+	// If non-zero, only the best max_results matches (or occurrences, see
+	// all_occurrences) are sent, after all other matches. Matches are ranked by
+	// the final ranking which dcs-web computes (see ranking.FinalRank and
+	// ranking.MaxResultsFirstPathRank). Files are searched in the order of their
+	// pre-ranking, so that searching can stop once no remaining file can contain
+	// a better match.
+	MaxResults uint32 `protobuf:"varint,10,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`
 }
 
 func (x *SearchRequest) Reset() {
@@ -227,6 +253,131 @@ func (x *SearchRequest) GetLiteral() bool {
 	return false
 }
 
+func (x *SearchRequest) GetContextBefore() uint32 {
+	if x != nil && x.ContextBefore != nil {
+		return *x.ContextBefore
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetContextAfter() uint32 {
+	if x != nil && x.ContextAfter != nil {
+		return *x.ContextAfter
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetAllOccurrences() bool {
+	if x != nil {
+		return x.AllOccurrences
+	}
+	return false
+}
+
+func (x *SearchRequest) GetIdentifier() bool {
+	if x != nil {
+		return x.Identifier
+	}
+	return false
+}
+
+func (x *SearchRequest) GetIgnoreWhitespace() bool {
+	if x != nil {
+		return x.IgnoreWhitespace
+	}
+	return false
+}
+
+func (x *SearchRequest) GetIgnoreLineBreaks() bool {
+	if x != nil {
+		return x.IgnoreLineBreaks
+	}
+	return false
+}
+
+func (x *SearchRequest) GetMaxResults() uint32 {
+	if x != nil {
+		return x.MaxResults
+	}
+	return 0
+}
+
+// Position of a match within a line.
+type Range struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Byte offsets of the first byte and after the last byte.
+	Start uint32 `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   uint32 `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	// Rune (Unicode code point) offsets of the first rune and after the last
+	// rune.
+	StartRune uint32 `protobuf:"varint,3,opt,name=start_rune,json=startRune,proto3" json:"start_rune,omitempty"`
+	EndRune   uint32 `protobuf:"varint,4,opt,name=end_rune,json=endRune,proto3" json:"end_rune,omitempty"`
+}
+
+func (x *Range) Reset() {
+	*x = Range{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sourcebackend_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Range) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Range) ProtoMessage() {}
+
+func (x *Range) ProtoReflect() protoreflect.Message {
+	mi := &file_sourcebackend_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Range.ProtoReflect.Descriptor instead.
+func (*Range) Descriptor() ([]byte, []int) {
+	return file_sourcebackend_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Range) GetStart() uint32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Range) GetEnd() uint32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Range) GetStartRune() uint32 {
+	if x != nil {
+		return x.StartRune
+	}
+	return 0
+}
+
+func (x *Range) GetEndRune() uint32 {
+	if x != nil {
+		return x.EndRune
+	}
+	return 0
+}
+
+// All strings are sent as they appear in the file, i.e. not HTML-escaped.
 type Match struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -234,25 +385,34 @@ type Match struct {
 
 	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
 	Line uint32 `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
-	// Contents of line-2.
-	Ctxp2 string `protobuf:"bytes,3,opt,name=ctxp2,proto3" json:"ctxp2,omitempty"`
-	// Contents of line-1.
-	Ctxp1 string `protobuf:"bytes,4,opt,name=ctxp1,proto3" json:"ctxp1,omitempty"`
+	// Contents of the lines before line (up to SearchRequest.context_before).
+	ContextBefore []string `protobuf:"bytes,15,rep,name=context_before,json=contextBefore,proto3" json:"context_before,omitempty"`
 	// Contents of the line containing the match.
 	Context string `protobuf:"bytes,5,opt,name=context,proto3" json:"context,omitempty"`
-	// Contents of line+1.
-	Ctxn1 string `protobuf:"bytes,6,opt,name=ctxn1,proto3" json:"ctxn1,omitempty"`
-	// Contents of line+2.
-	Ctxn2    string  `protobuf:"bytes,7,opt,name=ctxn2,proto3" json:"ctxn2,omitempty"`
-	Pathrank float32 `protobuf:"fixed32,8,opt,name=pathrank,proto3" json:"pathrank,omitempty"`
-	Ranking  float32 `protobuf:"fixed32,9,opt,name=ranking,proto3" json:"ranking,omitempty"`
-	Package  string  `protobuf:"bytes,10,opt,name=package,proto3" json:"package,omitempty"`
+	// Positions of all matches within context (only of this match with
+	// SearchRequest.all_occurrences).
+	Ranges []*Range `protobuf:"bytes,14,rep,name=ranges,proto3" json:"ranges,omitempty"`
+	// Contents of the lines after line (up to SearchRequest.context_after).
+	ContextAfter []string `protobuf:"bytes,16,rep,name=context_after,json=contextAfter,proto3" json:"context_after,omitempty"`
+	Pathrank     float32  `protobuf:"fixed32,8,opt,name=pathrank,proto3" json:"pathrank,omitempty"`
+	Ranking      float32  `protobuf:"fixed32,9,opt,name=ranking,proto3" json:"ranking,omitempty"`
+	Package      string   `protobuf:"bytes,10,opt,name=package,proto3" json:"package,omitempty"`
+	// Debian suites (e.g. “sid”, “stable”) which contain package, if known.
+	Suites []string `protobuf:"bytes,11,rep,name=suites,proto3" json:"suites,omitempty"`
+	// Hash of the file contents, if known. Matches in files with identical
+	// contents (e.g. vendored copies of a library) share the hash, so that they
+	// can be collapsed into one result.
+	ContentHash []byte `protobuf:"bytes,12,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	// Paths of further files (within the same source backend) whose contents
+	// are identical to path, and which hence contain the same match. Only files
+	// which the keywords of the query (e.g. package:) select are listed.
+	Duplicates []string `protobuf:"bytes,13,rep,name=duplicates,proto3" json:"duplicates,omitempty"`
 }
 
 func (x *Match) Reset() {
 	*x = Match{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sourcebackend_proto_msgTypes[3]
+		mi := &file_sourcebackend_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -265,7 +425,7 @@ func (x *Match) String() string {
 func (*Match) ProtoMessage() {}
 
 func (x *Match) ProtoReflect() protoreflect.Message {
-	mi := &file_sourcebackend_proto_msgTypes[3]
+	mi := &file_sourcebackend_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -278,7 +438,7 @@ func (x *Match) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Match.ProtoReflect.Descriptor instead.
 func (*Match) Descriptor() ([]byte, []int) {
-	return file_sourcebackend_proto_rawDescGZIP(), []int{3}
+	return file_sourcebackend_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Match) GetPath() string {
@@ -295,18 +455,11 @@ func (x *Match) GetLine() uint32 {
 	return 0
 }
 
-func (x *Match) GetCtxp2() string {
-	if x != nil {
-		return x.Ctxp2
-	}
-	return ""
-}
-
-func (x *Match) GetCtxp1() string {
+func (x *Match) GetContextBefore() []string {
 	if x != nil {
-		return x.Ctxp1
+		return x.ContextBefore
 	}
-	return ""
+	return nil
 }
 
 func (x *Match) GetContext() string {
@@ -316,18 +469,18 @@ func (x *Match) GetContext() string {
 	return ""
 }
 
-func (x *Match) GetCtxn1() string {
+func (x *Match) GetRanges() []*Range {
 	if x != nil {
-		return x.Ctxn1
+		return x.Ranges
 	}
-	return ""
+	return nil
 }
 
-func (x *Match) GetCtxn2() string {
+func (x *Match) GetContextAfter() []string {
 	if x != nil {
-		return x.Ctxn2
+		return x.ContextAfter
 	}
-	return ""
+	return nil
 }
 
 func (x *Match) GetPathrank() float32 {
@@ -351,6 +504,27 @@ func (x *Match) GetPackage() string {
 	return ""
 }
 
+func (x *Match) GetSuites() []string {
+	if x != nil {
+		return x.Suites
+	}
+	return nil
+}
+
+func (x *Match) GetContentHash() []byte {
+	if x != nil {
+		return x.ContentHash
+	}
+	return nil
+}
+
+func (x *Match) GetDuplicates() []string {
+	if x != nil {
+		return x.Duplicates
+	}
+	return nil
+}
+
 type ProgressUpdate struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -363,7 +537,7 @@ type ProgressUpdate struct {
 func (x *ProgressUpdate) Reset() {
 	*x = ProgressUpdate{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sourcebackend_proto_msgTypes[4]
+		mi := &file_sourcebackend_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -376,7 +550,7 @@ func (x *ProgressUpdate) String() string {
 func (*ProgressUpdate) ProtoMessage() {}
 
 func (x *ProgressUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_sourcebackend_proto_msgTypes[4]
+	mi := &file_sourcebackend_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -389,7 +563,7 @@ func (x *ProgressUpdate) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProgressUpdate.ProtoReflect.Descriptor instead.
 func (*ProgressUpdate) Descriptor() ([]byte, []int) {
-	return file_sourcebackend_proto_rawDescGZIP(), []int{4}
+	return file_sourcebackend_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ProgressUpdate) GetFilesProcessed() uint64 {
@@ -419,7 +593,7 @@ type SearchReply struct {
 func (x *SearchReply) Reset() {
 	*x = SearchReply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sourcebackend_proto_msgTypes[5]
+		mi := &file_sourcebackend_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -432,7 +606,7 @@ func (x *SearchReply) String() string {
 func (*SearchReply) ProtoMessage() {}
 
 func (x *SearchReply) ProtoReflect() protoreflect.Message {
-	mi := &file_sourcebackend_proto_msgTypes[5]
+	mi := &file_sourcebackend_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -445,7 +619,7 @@ func (x *SearchReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchReply.ProtoReflect.Descriptor instead.
 func (*SearchReply) Descriptor() ([]byte, []int) {
-	return file_sourcebackend_proto_rawDescGZIP(), []int{5}
+	return file_sourcebackend_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *SearchReply) GetType() SearchReply_Type {
@@ -469,6 +643,259 @@ func (x *SearchReply) GetProgressUpdate() *ProgressUpdate {
 	return nil
 }
 
+type ExplainRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Rewritten URL (after RewriteQuery()) with all the parameters that
+	// are relevant for filtering.
+	RewrittenUrl string `protobuf:"bytes,2,opt,name=rewritten_url,json=rewrittenUrl,proto3" json:"rewritten_url,omitempty"`
+	Literal      bool   `protobuf:"varint,3,opt,name=literal,proto3" json:"literal,omitempty"`
+	// See SearchRequest.identifier.
+	Identifier bool `protobuf:"varint,4,opt,name=identifier,proto3" json:"identifier,omitempty"`
+	// See SearchRequest.ignore_whitespace.
+	IgnoreWhitespace bool `protobuf:"varint,5,opt,name=ignore_whitespace,json=ignoreWhitespace,proto3" json:"ignore_whitespace,omitempty"`
+	// See SearchRequest.ignore_line_breaks.
+	IgnoreLineBreaks bool `protobuf:"varint,6,opt,name=ignore_line_breaks,json=ignoreLineBreaks,proto3" json:"ignore_line_breaks,omitempty"`
+}
+
+func (x *ExplainRequest) Reset() {
+	*x = ExplainRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sourcebackend_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExplainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainRequest) ProtoMessage() {}
+
+func (x *ExplainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sourcebackend_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainRequest.ProtoReflect.Descriptor instead.
+func (*ExplainRequest) Descriptor() ([]byte, []int) {
+	return file_sourcebackend_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ExplainRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ExplainRequest) GetRewrittenUrl() string {
+	if x != nil {
+		return x.RewrittenUrl
+	}
+	return ""
+}
+
+func (x *ExplainRequest) GetLiteral() bool {
+	if x != nil {
+		return x.Literal
+	}
+	return false
+}
+
+func (x *ExplainRequest) GetIdentifier() bool {
+	if x != nil {
+		return x.Identifier
+	}
+	return false
+}
+
+func (x *ExplainRequest) GetIgnoreWhitespace() bool {
+	if x != nil {
+		return x.IgnoreWhitespace
+	}
+	return false
+}
+
+func (x *ExplainRequest) GetIgnoreLineBreaks() bool {
+	if x != nil {
+		return x.IgnoreLineBreaks
+	}
+	return false
+}
+
+type TrigramEntries struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Trigram string `protobuf:"bytes,1,opt,name=trigram,proto3" json:"trigram,omitempty"`
+	// Number of documents containing the trigram (docid section).
+	DocidEntries uint32 `protobuf:"varint,2,opt,name=docid_entries,json=docidEntries,proto3" json:"docid_entries,omitempty"`
+	// Number of occurrences of the trigram (pos section).
+	PosEntries uint32 `protobuf:"varint,3,opt,name=pos_entries,json=posEntries,proto3" json:"pos_entries,omitempty"`
+}
+
+func (x *TrigramEntries) Reset() {
+	*x = TrigramEntries{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sourcebackend_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TrigramEntries) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrigramEntries) ProtoMessage() {}
+
+func (x *TrigramEntries) ProtoReflect() protoreflect.Message {
+	mi := &file_sourcebackend_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrigramEntries.ProtoReflect.Descriptor instead.
+func (*TrigramEntries) Descriptor() ([]byte, []int) {
+	return file_sourcebackend_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TrigramEntries) GetTrigram() string {
+	if x != nil {
+		return x.Trigram
+	}
+	return ""
+}
+
+func (x *TrigramEntries) GetDocidEntries() uint32 {
+	if x != nil {
+		return x.DocidEntries
+	}
+	return 0
+}
+
+func (x *TrigramEntries) GetPosEntries() uint32 {
+	if x != nil {
+		return x.PosEntries
+	}
+	return 0
+}
+
+type ExplainReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The index.Query (AND/OR of trigrams) built from the regular expression.
+	QueryTree string `protobuf:"bytes,1,opt,name=query_tree,json=queryTree,proto3" json:"query_tree,omitempty"`
+	// All trigrams of query_tree, in sorted order.
+	Trigrams []*TrigramEntries `protobuf:"bytes,2,rep,name=trigrams,proto3" json:"trigrams,omitempty"`
+	// Number of candidate files after PostingQuery.
+	PostingQueryFiles uint64 `protobuf:"varint,3,opt,name=posting_query_files,json=postingQueryFiles,proto3" json:"posting_query_files,omitempty"`
+	// Number of candidate files after filtering by the literal fragments of the
+	// query (only when using the positional index, equal to
+	// posting_query_files otherwise).
+	FragmentFiles uint64 `protobuf:"varint,4,opt,name=fragment_files,json=fragmentFiles,proto3" json:"fragment_files,omitempty"`
+	// Number of files after ranking and FilterByKeywords, i.e. the files which
+	// Search would grep.
+	KeywordFiles uint64 `protobuf:"varint,5,opt,name=keyword_files,json=keywordFiles,proto3" json:"keyword_files,omitempty"`
+	// Whether Search answers this query from the positional index instead.
+	Positional bool `protobuf:"varint,6,opt,name=positional,proto3" json:"positional,omitempty"`
+}
+
+func (x *ExplainReply) Reset() {
+	*x = ExplainReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sourcebackend_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExplainReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainReply) ProtoMessage() {}
+
+func (x *ExplainReply) ProtoReflect() protoreflect.Message {
+	mi := &file_sourcebackend_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainReply.ProtoReflect.Descriptor instead.
+func (*ExplainReply) Descriptor() ([]byte, []int) {
+	return file_sourcebackend_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExplainReply) GetQueryTree() string {
+	if x != nil {
+		return x.QueryTree
+	}
+	return ""
+}
+
+func (x *ExplainReply) GetTrigrams() []*TrigramEntries {
+	if x != nil {
+		return x.Trigrams
+	}
+	return nil
+}
+
+func (x *ExplainReply) GetPostingQueryFiles() uint64 {
+	if x != nil {
+		return x.PostingQueryFiles
+	}
+	return 0
+}
+
+func (x *ExplainReply) GetFragmentFiles() uint64 {
+	if x != nil {
+		return x.FragmentFiles
+	}
+	return 0
+}
+
+func (x *ExplainReply) GetKeywordFiles() uint64 {
+	if x != nil {
+		return x.KeywordFiles
+	}
+	return 0
+}
+
+func (x *ExplainReply) GetPositional() bool {
+	if x != nil {
+		return x.Positional
+	}
+	return false
+}
+
 type ReplaceIndexRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -480,7 +907,7 @@ type ReplaceIndexRequest struct {
 func (x *ReplaceIndexRequest) Reset() {
 	*x = ReplaceIndexRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sourcebackend_proto_msgTypes[6]
+		mi := &file_sourcebackend_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -493,7 +920,7 @@ func (x *ReplaceIndexRequest) String() string {
 func (*ReplaceIndexRequest) ProtoMessage() {}
 
 func (x *ReplaceIndexRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sourcebackend_proto_msgTypes[6]
+	mi := &file_sourcebackend_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -506,7 +933,7 @@ func (x *ReplaceIndexRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReplaceIndexRequest.ProtoReflect.Descriptor instead.
 func (*ReplaceIndexRequest) Descriptor() ([]byte, []int) {
-	return file_sourcebackend_proto_rawDescGZIP(), []int{6}
+	return file_sourcebackend_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ReplaceIndexRequest) GetReplacementPath() string {
@@ -525,7 +952,7 @@ type ReplaceIndexReply struct {
 func (x *ReplaceIndexReply) Reset() {
 	*x = ReplaceIndexReply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sourcebackend_proto_msgTypes[7]
+		mi := &file_sourcebackend_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -538,7 +965,7 @@ func (x *ReplaceIndexReply) String() string {
 func (*ReplaceIndexReply) ProtoMessage() {}
 
 func (x *ReplaceIndexReply) ProtoReflect() protoreflect.Message {
-	mi := &file_sourcebackend_proto_msgTypes[7]
+	mi := &file_sourcebackend_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -551,7 +978,92 @@ func (x *ReplaceIndexReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReplaceIndexReply.ProtoReflect.Descriptor instead.
 func (*ReplaceIndexReply) Descriptor() ([]byte, []int) {
-	return file_sourcebackend_proto_rawDescGZIP(), []int{7}
+	return file_sourcebackend_proto_rawDescGZIP(), []int{11}
+}
+
+type ReplaceDeltaRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReplacementPath string `protobuf:"bytes,1,opt,name=replacement_path,json=replacementPath,proto3" json:"replacement_path,omitempty"`
+}
+
+func (x *ReplaceDeltaRequest) Reset() {
+	*x = ReplaceDeltaRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sourcebackend_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplaceDeltaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplaceDeltaRequest) ProtoMessage() {}
+
+func (x *ReplaceDeltaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sourcebackend_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplaceDeltaRequest.ProtoReflect.Descriptor instead.
+func (*ReplaceDeltaRequest) Descriptor() ([]byte, []int) {
+	return file_sourcebackend_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ReplaceDeltaRequest) GetReplacementPath() string {
+	if x != nil {
+		return x.ReplacementPath
+	}
+	return ""
+}
+
+type ReplaceDeltaReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ReplaceDeltaReply) Reset() {
+	*x = ReplaceDeltaReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sourcebackend_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplaceDeltaReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplaceDeltaReply) ProtoMessage() {}
+
+func (x *ReplaceDeltaReply) ProtoReflect() protoreflect.Message {
+	mi := &file_sourcebackend_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplaceDeltaReply.ProtoReflect.Descriptor instead.
+func (*ReplaceDeltaReply) Descriptor() ([]byte, []int) {
+	return file_sourcebackend_proto_rawDescGZIP(), []int{13}
 }
 
 var File_sourcebackend_proto protoreflect.FileDescriptor
@@ -564,74 +1076,164 @@ var file_sourcebackend_proto_rawDesc = []byte{
 	0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x22, 0x27, 0x0a, 0x09, 0x46, 0x69, 0x6c,
 	0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
 	0x74, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
-	0x74, 0x73, 0x22, 0x64, 0x0a, 0x0d, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x77,
-	0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0c, 0x72, 0x65, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x55, 0x72, 0x6c, 0x12, 0x18,
-	0x0a, 0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
-	0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x22, 0xf1, 0x01, 0x0a, 0x05, 0x4d, 0x61, 0x74,
-	0x63, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x74,
-	0x78, 0x70, 0x32, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x74, 0x78, 0x70, 0x32,
-	0x12, 0x14, 0x0a, 0x05, 0x63, 0x74, 0x78, 0x70, 0x31, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x05, 0x63, 0x74, 0x78, 0x70, 0x31, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78,
-	0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
-	0x12, 0x14, 0x0a, 0x05, 0x63, 0x74, 0x78, 0x6e, 0x31, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x05, 0x63, 0x74, 0x78, 0x6e, 0x31, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x74, 0x78, 0x6e, 0x32, 0x18,
-	0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x74, 0x78, 0x6e, 0x32, 0x12, 0x1a, 0x0a, 0x08,
+	0x74, 0x73, 0x22, 0xa4, 0x03, 0x0a, 0x0d, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65,
+	0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0c, 0x72, 0x65, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x55, 0x72, 0x6c, 0x12,
+	0x18, 0x0a, 0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x12, 0x2a, 0x0a, 0x0e, 0x63, 0x6f, 0x6e,
+	0x74, 0x65, 0x78, 0x74, 0x5f, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0d, 0x48, 0x00, 0x52, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x42, 0x65, 0x66, 0x6f,
+	0x72, 0x65, 0x88, 0x01, 0x01, 0x12, 0x28, 0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x0c,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x41, 0x66, 0x74, 0x65, 0x72, 0x88, 0x01, 0x01, 0x12,
+	0x27, 0x0a, 0x0f, 0x61, 0x6c, 0x6c, 0x5f, 0x6f, 0x63, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
+	0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x61, 0x6c, 0x6c, 0x4f, 0x63, 0x63,
+	0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x2b, 0x0a, 0x11, 0x69, 0x67, 0x6e, 0x6f,
+	0x72, 0x65, 0x5f, 0x77, 0x68, 0x69, 0x74, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x10, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x57, 0x68, 0x69, 0x74, 0x65,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x5f,
+	0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x62, 0x72, 0x65, 0x61, 0x6b, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x10, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x4c, 0x69, 0x6e, 0x65, 0x42, 0x72, 0x65,
+	0x61, 0x6b, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x61, 0x78, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x6d, 0x61, 0x78, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x73, 0x42, 0x11, 0x0a, 0x0f, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x5f, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x78, 0x74, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x22, 0x69, 0x0a, 0x05, 0x52, 0x61, 0x6e,
+	0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x5f, 0x72, 0x75, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09,
+	0x73, 0x74, 0x61, 0x72, 0x74, 0x52, 0x75, 0x6e, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x65, 0x6e, 0x64,
+	0x5f, 0x72, 0x75, 0x6e, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x65, 0x6e, 0x64,
+	0x52, 0x75, 0x6e, 0x65, 0x22, 0x88, 0x03, 0x0a, 0x05, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x12,
+	0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78,
+	0x74, 0x5f, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0d,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x42, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x2e, 0x0a, 0x06, 0x72, 0x61, 0x6e, 0x67, 0x65,
+	0x73, 0x18, 0x0e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52,
+	0x06, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x78, 0x74, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x10, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x41, 0x66, 0x74, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08,
 	0x70, 0x61, 0x74, 0x68, 0x72, 0x61, 0x6e, 0x6b, 0x18, 0x08, 0x20, 0x01, 0x28, 0x02, 0x52, 0x08,
 	0x70, 0x61, 0x74, 0x68, 0x72, 0x61, 0x6e, 0x6b, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x61, 0x6e, 0x6b,
 	0x69, 0x6e, 0x67, 0x18, 0x09, 0x20, 0x01, 0x28, 0x02, 0x52, 0x07, 0x72, 0x61, 0x6e, 0x6b, 0x69,
 	0x6e, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x0a, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x22, 0x5a, 0x0a, 0x0e,
-	0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x27,
-	0x0a, 0x0f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x5f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x50, 0x72,
-	0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x69, 0x6c, 0x65, 0x73,
-	0x5f, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x66, 0x69,
-	0x6c, 0x65, 0x73, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x22, 0xe4, 0x01, 0x0a, 0x0b, 0x53, 0x65, 0x61,
-	0x72, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x35, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62,
-	0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52,
-	0x65, 0x70, 0x6c, 0x79, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12,
-	0x2c, 0x0a, 0x05, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16,
-	0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62,
-	0x2e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x05, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x48, 0x0a,
-	0x0f, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65,
-	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62,
-	0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73,
-	0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73,
-	0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x22, 0x26, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12,
-	0x09, 0x0a, 0x05, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x50, 0x52,
-	0x4f, 0x47, 0x52, 0x45, 0x53, 0x53, 0x5f, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x10, 0x01, 0x22,
-	0x40, 0x0a, 0x13, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63,
-	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0f, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x50, 0x61, 0x74,
-	0x68, 0x22, 0x13, 0x0a, 0x11, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65,
-	0x78, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x32, 0xfb, 0x01, 0x0a, 0x0d, 0x53, 0x6f, 0x75, 0x72, 0x63,
-	0x65, 0x42, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x12, 0x42, 0x0a, 0x04, 0x46, 0x69, 0x6c, 0x65,
-	0x12, 0x1c, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64,
-	0x70, 0x62, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x75, 0x69, 0x74, 0x65, 0x73, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x73, 0x75,
+	0x69, 0x74, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f,
+	0x68, 0x61, 0x73, 0x68, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x6e, 0x74, 0x48, 0x61, 0x73, 0x68, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x75, 0x70, 0x6c, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x64, 0x75, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x4a, 0x04, 0x08, 0x03, 0x10, 0x04, 0x4a, 0x04, 0x08,
+	0x04, 0x10, 0x05, 0x4a, 0x04, 0x08, 0x06, 0x10, 0x07, 0x4a, 0x04, 0x08, 0x07, 0x10, 0x08, 0x22,
+	0x5a, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x12, 0x27, 0x0a, 0x0f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x5f, 0x70, 0x72, 0x6f, 0x63, 0x65,
+	0x73, 0x73, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x66, 0x69, 0x6c, 0x65,
+	0x73, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x69,
+	0x6c, 0x65, 0x73, 0x5f, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x0a, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x22, 0xe4, 0x01, 0x0a, 0x0b,
+	0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x35, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x61, 0x72,
+	0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x2c, 0x0a, 0x05, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x16, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e,
+	0x64, 0x70, 0x62, 0x2e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x05, 0x6d, 0x61, 0x74, 0x63, 0x68,
+	0x12, 0x48, 0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x5f, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x50, 0x72, 0x6f, 0x67,
+	0x72, 0x65, 0x73, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x67,
+	0x72, 0x65, 0x73, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x22, 0x26, 0x0a, 0x04, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x00, 0x12, 0x13, 0x0a,
+	0x0f, 0x50, 0x52, 0x4f, 0x47, 0x52, 0x45, 0x53, 0x53, 0x5f, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45,
+	0x10, 0x01, 0x22, 0xe0, 0x01, 0x0a, 0x0e, 0x45, 0x78, 0x70, 0x6c, 0x61, 0x69, 0x6e, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x72,
+	0x65, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x55, 0x72, 0x6c,
+	0x12, 0x18, 0x0a, 0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a,
+	0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x2b, 0x0a, 0x11, 0x69, 0x67,
+	0x6e, 0x6f, 0x72, 0x65, 0x5f, 0x77, 0x68, 0x69, 0x74, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x57, 0x68, 0x69,
+	0x74, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x69, 0x67, 0x6e, 0x6f, 0x72,
+	0x65, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x62, 0x72, 0x65, 0x61, 0x6b, 0x73, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x10, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x4c, 0x69, 0x6e, 0x65, 0x42,
+	0x72, 0x65, 0x61, 0x6b, 0x73, 0x22, 0x70, 0x0a, 0x0e, 0x54, 0x72, 0x69, 0x67, 0x72, 0x61, 0x6d,
+	0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x69, 0x67, 0x72,
+	0x61, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x69, 0x67, 0x72, 0x61,
+	0x6d, 0x12, 0x23, 0x0a, 0x0d, 0x64, 0x6f, 0x63, 0x69, 0x64, 0x5f, 0x65, 0x6e, 0x74, 0x72, 0x69,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x64, 0x6f, 0x63, 0x69, 0x64, 0x45,
+	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x6f, 0x73, 0x5f, 0x65, 0x6e,
+	0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x70, 0x6f, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x86, 0x02, 0x0a, 0x0c, 0x45, 0x78, 0x70, 0x6c,
+	0x61, 0x69, 0x6e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x71, 0x75, 0x65, 0x72,
+	0x79, 0x5f, 0x74, 0x72, 0x65, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x71, 0x75,
+	0x65, 0x72, 0x79, 0x54, 0x72, 0x65, 0x65, 0x12, 0x3b, 0x0a, 0x08, 0x74, 0x72, 0x69, 0x67, 0x72,
+	0x61, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x54, 0x72, 0x69, 0x67,
+	0x72, 0x61, 0x6d, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x52, 0x08, 0x74, 0x72, 0x69, 0x67,
+	0x72, 0x61, 0x6d, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x70, 0x6f, 0x73, 0x74, 0x69, 0x6e, 0x67, 0x5f,
+	0x71, 0x75, 0x65, 0x72, 0x79, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x11, 0x70, 0x6f, 0x73, 0x74, 0x69, 0x6e, 0x67, 0x51, 0x75, 0x65, 0x72, 0x79, 0x46,
+	0x69, 0x6c, 0x65, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74,
+	0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d, 0x66, 0x72,
+	0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x46, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x6b,
+	0x65, 0x79, 0x77, 0x6f, 0x72, 0x64, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x0c, 0x6b, 0x65, 0x79, 0x77, 0x6f, 0x72, 0x64, 0x46, 0x69, 0x6c, 0x65, 0x73,
+	0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c,
+	0x22, 0x40, 0x0a, 0x13, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x70, 0x6c, 0x61,
+	0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0f, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x50, 0x61,
+	0x74, 0x68, 0x22, 0x13, 0x0a, 0x11, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64,
+	0x65, 0x78, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x40, 0x0a, 0x13, 0x52, 0x65, 0x70, 0x6c, 0x61,
+	0x63, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x29,
+	0x0a, 0x10, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x61,
+	0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63,
+	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x50, 0x61, 0x74, 0x68, 0x22, 0x13, 0x0a, 0x11, 0x52, 0x65, 0x70,
+	0x6c, 0x61, 0x63, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x32, 0xa4,
+	0x03, 0x0a, 0x0d, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x42, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64,
+	0x12, 0x42, 0x0a, 0x04, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x1c, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62,
+	0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x70,
+	0x6c, 0x79, 0x22, 0x00, 0x12, 0x4a, 0x0a, 0x06, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x12, 0x1e,
 	0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62,
-	0x2e, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x4a, 0x0a, 0x06,
-	0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x12, 0x1e, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62,
-	0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62,
-	0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52,
-	0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x30, 0x01, 0x12, 0x5a, 0x0a, 0x0c, 0x52, 0x65, 0x70, 0x6c,
-	0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x24, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63,
-	0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x61,
-	0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22,
+	0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c,
 	0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62,
-	0x2e, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x52, 0x65, 0x70,
-	0x6c, 0x79, 0x22, 0x00, 0x42, 0x36, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x44, 0x65, 0x62, 0x69, 0x61, 0x6e, 0x2f, 0x64, 0x63, 0x73, 0x2f, 0x69, 0x6e,
-	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x6f, 0x75,
-	0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x30, 0x01,
+	0x12, 0x4b, 0x0a, 0x07, 0x45, 0x78, 0x70, 0x6c, 0x61, 0x69, 0x6e, 0x12, 0x1f, 0x2e, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x45, 0x78,
+	0x70, 0x6c, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x45,
+	0x78, 0x70, 0x6c, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x5a, 0x0a,
+	0x0c, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x24, 0x2e,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e,
+	0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b,
+	0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x49, 0x6e, 0x64,
+	0x65, 0x78, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x5a, 0x0a, 0x0c, 0x52, 0x65, 0x70,
+	0x6c, 0x61, 0x63, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x24, 0x2e, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x70, 0x6c,
+	0x61, 0x63, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x22, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70,
+	0x62, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x65,
+	0x70, 0x6c, 0x79, 0x22, 0x00, 0x42, 0x36, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x44, 0x65, 0x62, 0x69, 0x61, 0x6e, 0x2f, 0x64, 0x63, 0x73, 0x2f, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -647,33 +1249,45 @@ func file_sourcebackend_proto_rawDescGZIP() []byte {
 }
 
 var file_sourcebackend_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_sourcebackend_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_sourcebackend_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_sourcebackend_proto_goTypes = []interface{}{
 	(SearchReply_Type)(0),       // 0: sourcebackendpb.SearchReply.Type
 	(*FileRequest)(nil),         // 1: sourcebackendpb.FileRequest
 	(*FileReply)(nil),           // 2: sourcebackendpb.FileReply
 	(*SearchRequest)(nil),       // 3: sourcebackendpb.SearchRequest
-	(*Match)(nil),               // 4: sourcebackendpb.Match
-	(*ProgressUpdate)(nil),      // 5: sourcebackendpb.ProgressUpdate
-	(*SearchReply)(nil),         // 6: sourcebackendpb.SearchReply
-	(*ReplaceIndexRequest)(nil), // 7: sourcebackendpb.ReplaceIndexRequest
-	(*ReplaceIndexReply)(nil),   // 8: sourcebackendpb.ReplaceIndexReply
+	(*Range)(nil),               // 4: sourcebackendpb.Range
+	(*Match)(nil),               // 5: sourcebackendpb.Match
+	(*ProgressUpdate)(nil),      // 6: sourcebackendpb.ProgressUpdate
+	(*SearchReply)(nil),         // 7: sourcebackendpb.SearchReply
+	(*ExplainRequest)(nil),      // 8: sourcebackendpb.ExplainRequest
+	(*TrigramEntries)(nil),      // 9: sourcebackendpb.TrigramEntries
+	(*ExplainReply)(nil),        // 10: sourcebackendpb.ExplainReply
+	(*ReplaceIndexRequest)(nil), // 11: sourcebackendpb.ReplaceIndexRequest
+	(*ReplaceIndexReply)(nil),   // 12: sourcebackendpb.ReplaceIndexReply
+	(*ReplaceDeltaRequest)(nil), // 13: sourcebackendpb.ReplaceDeltaRequest
+	(*ReplaceDeltaReply)(nil),   // 14: sourcebackendpb.ReplaceDeltaReply
 }
 var file_sourcebackend_proto_depIdxs = []int32{
-	0, // 0: sourcebackendpb.SearchReply.type:type_name -> sourcebackendpb.SearchReply.Type
-	4, // 1: sourcebackendpb.SearchReply.match:type_name -> sourcebackendpb.Match
-	5, // 2: sourcebackendpb.SearchReply.progress_update:type_name -> sourcebackendpb.ProgressUpdate
-	1, // 3: sourcebackendpb.SourceBackend.File:input_type -> sourcebackendpb.FileRequest
-	3, // 4: sourcebackendpb.SourceBackend.Search:input_type -> sourcebackendpb.SearchRequest
-	7, // 5: sourcebackendpb.SourceBackend.ReplaceIndex:input_type -> sourcebackendpb.ReplaceIndexRequest
-	2, // 6: sourcebackendpb.SourceBackend.File:output_type -> sourcebackendpb.FileReply
-	6, // 7: sourcebackendpb.SourceBackend.Search:output_type -> sourcebackendpb.SearchReply
-	8, // 8: sourcebackendpb.SourceBackend.ReplaceIndex:output_type -> sourcebackendpb.ReplaceIndexReply
-	6, // [6:9] is the sub-list for method output_type
-	3, // [3:6] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	4,  // 0: sourcebackendpb.Match.ranges:type_name -> sourcebackendpb.Range
+	0,  // 1: sourcebackendpb.SearchReply.type:type_name -> sourcebackendpb.SearchReply.Type
+	5,  // 2: sourcebackendpb.SearchReply.match:type_name -> sourcebackendpb.Match
+	6,  // 3: sourcebackendpb.SearchReply.progress_update:type_name -> sourcebackendpb.ProgressUpdate
+	9,  // 4: sourcebackendpb.ExplainReply.trigrams:type_name -> sourcebackendpb.TrigramEntries
+	1,  // 5: sourcebackendpb.SourceBackend.File:input_type -> sourcebackendpb.FileRequest
+	3,  // 6: sourcebackendpb.SourceBackend.Search:input_type -> sourcebackendpb.SearchRequest
+	8,  // 7: sourcebackendpb.SourceBackend.Explain:input_type -> sourcebackendpb.ExplainRequest
+	11, // 8: sourcebackendpb.SourceBackend.ReplaceIndex:input_type -> sourcebackendpb.ReplaceIndexRequest
+	13, // 9: sourcebackendpb.SourceBackend.ReplaceDelta:input_type -> sourcebackendpb.ReplaceDeltaRequest
+	2,  // 10: sourcebackendpb.SourceBackend.File:output_type -> sourcebackendpb.FileReply
+	7,  // 11: sourcebackendpb.SourceBackend.Search:output_type -> sourcebackendpb.SearchReply
+	10, // 12: sourcebackendpb.SourceBackend.Explain:output_type -> sourcebackendpb.ExplainReply
+	12, // 13: sourcebackendpb.SourceBackend.ReplaceIndex:output_type -> sourcebackendpb.ReplaceIndexReply
+	14, // 14: sourcebackendpb.SourceBackend.ReplaceDelta:output_type -> sourcebackendpb.ReplaceDeltaReply
+	10, // [10:15] is the sub-list for method output_type
+	5,  // [5:10] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_sourcebackend_proto_init() }
@@ -719,7 +1333,7 @@ func file_sourcebackend_proto_init() {
 			}
 		}
 		file_sourcebackend_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Match); i {
+			switch v := v.(*Range); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -731,7 +1345,7 @@ func file_sourcebackend_proto_init() {
 			}
 		}
 		file_sourcebackend_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ProgressUpdate); i {
+			switch v := v.(*Match); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -743,7 +1357,7 @@ func file_sourcebackend_proto_init() {
 			}
 		}
 		file_sourcebackend_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SearchReply); i {
+			switch v := v.(*ProgressUpdate); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -755,7 +1369,7 @@ func file_sourcebackend_proto_init() {
 			}
 		}
 		file_sourcebackend_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ReplaceIndexRequest); i {
+			switch v := v.(*SearchReply); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -767,6 +1381,54 @@ func file_sourcebackend_proto_init() {
 			}
 		}
 		file_sourcebackend_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExplainRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sourcebackend_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TrigramEntries); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sourcebackend_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExplainReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sourcebackend_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplaceIndexRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sourcebackend_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ReplaceIndexReply); i {
 			case 0:
 				return &v.state
@@ -778,14 +1440,39 @@ func file_sourcebackend_proto_init() {
 				return nil
 			}
 		}
+		file_sourcebackend_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplaceDeltaRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sourcebackend_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplaceDeltaReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
+	file_sourcebackend_proto_msgTypes[2].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_sourcebackend_proto_rawDesc,
 			NumEnums:      1,
-			NumMessages:   8,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
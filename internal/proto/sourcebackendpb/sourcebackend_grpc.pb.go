@@ -22,10 +22,17 @@ type SourceBackendClient interface {
 	File(ctx context.Context, in *FileRequest, opts ...grpc.CallOption) (*FileReply, error)
 	// Search performs the given query and streams matches/progress updates.
 	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (SourceBackend_SearchClient, error)
+	// Explain returns the query plan and candidate file counts of a search,
+	// without performing it.
+	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainReply, error)
 	// Replaces the loaded index with the specified replacement index. On a file
 	// system level, the specified file is mv'ed to the file specified by
 	// -index_path.
 	ReplaceIndex(ctx context.Context, in *ReplaceIndexRequest, opts ...grpc.CallOption) (*ReplaceIndexReply, error)
+	// Loads the specified delta index (containing recently imported packages and
+	// tombstones for deleted ones) on top of the loaded index. On a file system
+	// level, the specified directory is symlinked to delta next to -index_path.
+	ReplaceDelta(ctx context.Context, in *ReplaceDeltaRequest, opts ...grpc.CallOption) (*ReplaceDeltaReply, error)
 }
 
 type sourceBackendClient struct {
@@ -77,6 +84,15 @@ func (x *sourceBackendSearchClient) Recv() (*SearchReply, error) {
 	return m, nil
 }
 
+func (c *sourceBackendClient) Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainReply, error) {
+	out := new(ExplainReply)
+	err := c.cc.Invoke(ctx, "/sourcebackendpb.SourceBackend/Explain", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sourceBackendClient) ReplaceIndex(ctx context.Context, in *ReplaceIndexRequest, opts ...grpc.CallOption) (*ReplaceIndexReply, error) {
 	out := new(ReplaceIndexReply)
 	err := c.cc.Invoke(ctx, "/sourcebackendpb.SourceBackend/ReplaceIndex", in, out, opts...)
@@ -86,6 +102,15 @@ func (c *sourceBackendClient) ReplaceIndex(ctx context.Context, in *ReplaceIndex
 	return out, nil
 }
 
+func (c *sourceBackendClient) ReplaceDelta(ctx context.Context, in *ReplaceDeltaRequest, opts ...grpc.CallOption) (*ReplaceDeltaReply, error) {
+	out := new(ReplaceDeltaReply)
+	err := c.cc.Invoke(ctx, "/sourcebackendpb.SourceBackend/ReplaceDelta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SourceBackendServer is the server API for SourceBackend service.
 // All implementations must embed UnimplementedSourceBackendServer
 // for forward compatibility
@@ -94,10 +119,17 @@ type SourceBackendServer interface {
 	File(context.Context, *FileRequest) (*FileReply, error)
 	// Search performs the given query and streams matches/progress updates.
 	Search(*SearchRequest, SourceBackend_SearchServer) error
+	// Explain returns the query plan and candidate file counts of a search,
+	// without performing it.
+	Explain(context.Context, *ExplainRequest) (*ExplainReply, error)
 	// Replaces the loaded index with the specified replacement index. On a file
 	// system level, the specified file is mv'ed to the file specified by
 	// -index_path.
 	ReplaceIndex(context.Context, *ReplaceIndexRequest) (*ReplaceIndexReply, error)
+	// Loads the specified delta index (containing recently imported packages and
+	// tombstones for deleted ones) on top of the loaded index. On a file system
+	// level, the specified directory is symlinked to delta next to -index_path.
+	ReplaceDelta(context.Context, *ReplaceDeltaRequest) (*ReplaceDeltaReply, error)
 	mustEmbedUnimplementedSourceBackendServer()
 }
 
@@ -111,9 +143,15 @@ func (UnimplementedSourceBackendServer) File(context.Context, *FileRequest) (*Fi
 func (UnimplementedSourceBackendServer) Search(*SearchRequest, SourceBackend_SearchServer) error {
 	return status.Errorf(codes.Unimplemented, "method Search not implemented")
 }
+func (UnimplementedSourceBackendServer) Explain(context.Context, *ExplainRequest) (*ExplainReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Explain not implemented")
+}
 func (UnimplementedSourceBackendServer) ReplaceIndex(context.Context, *ReplaceIndexRequest) (*ReplaceIndexReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReplaceIndex not implemented")
 }
+func (UnimplementedSourceBackendServer) ReplaceDelta(context.Context, *ReplaceDeltaRequest) (*ReplaceDeltaReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplaceDelta not implemented")
+}
 func (UnimplementedSourceBackendServer) mustEmbedUnimplementedSourceBackendServer() {}
 
 // UnsafeSourceBackendServer may be embedded to opt out of forward compatibility for this service.
@@ -166,6 +204,24 @@ func (x *sourceBackendSearchServer) Send(m *SearchReply) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _SourceBackend_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SourceBackendServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sourcebackendpb.SourceBackend/Explain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SourceBackendServer).Explain(ctx, req.(*ExplainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SourceBackend_ReplaceIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ReplaceIndexRequest)
 	if err := dec(in); err != nil {
@@ -184,6 +240,24 @@ func _SourceBackend_ReplaceIndex_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SourceBackend_ReplaceDelta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplaceDeltaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SourceBackendServer).ReplaceDelta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sourcebackendpb.SourceBackend/ReplaceDelta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SourceBackendServer).ReplaceDelta(ctx, req.(*ReplaceDeltaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SourceBackend_ServiceDesc is the grpc.ServiceDesc for SourceBackend service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -195,10 +269,18 @@ var SourceBackend_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "File",
 			Handler:    _SourceBackend_File_Handler,
 		},
+		{
+			MethodName: "Explain",
+			Handler:    _SourceBackend_Explain_Handler,
+		},
 		{
 			MethodName: "ReplaceIndex",
 			Handler:    _SourceBackend_ReplaceIndex_Handler,
 		},
+		{
+			MethodName: "ReplaceDelta",
+			Handler:    _SourceBackend_ReplaceDelta_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,305 @@
+// Package scope classifies the bytes of source code as code, comments or
+// string literals.
+//
+// Like package symbols, it uses lightweight lexers per language instead of a
+// full parser: the lexers only know about the syntax of comments and string
+// literals, so that they stay fast and robust against code which does not
+// compile. Rarely used constructs (e.g. here-documents or regular expression
+// literals) are treated as code.
+package scope
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+)
+
+// A Scope is the syntactic context of a position in source code.
+type Scope byte
+
+const (
+	Code Scope = iota
+	Comment
+	String
+)
+
+func (s Scope) String() string {
+	switch s {
+	case Code:
+		return "code"
+	case Comment:
+		return "comment"
+	case String:
+		return "string"
+	}
+	return "unknown"
+}
+
+// Parse returns the Scope whose String method returns name.
+func Parse(name string) (Scope, bool) {
+	for _, s := range []Scope{Code, Comment, String} {
+		if s.String() == name {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// A delim describes a comment or string literal syntax.
+type delim struct {
+	open, close string
+	escape      bool // a backslash escapes the next byte
+	multiline   bool // the region can span lines (otherwise it ends with the line)
+}
+
+type language struct {
+	comments []delim
+	strings  []delim // longer openings must come first, e.g. """ before "
+
+	// commentAfter (if non-nil) reports whether a comment can start after the
+	// byte prev (0 at the beginning of the content), e.g. not in shell’s “$#”.
+	commentAfter func(prev byte) bool
+
+	// pod enables Perl’s POD blocks, which start with a line “=word” and end
+	// after a line “=cut”, as comments.
+	pod bool
+}
+
+var (
+	cComments = []delim{
+		{open: "//", close: "\n"},
+		{open: "/*", close: "*/", multiline: true},
+	}
+
+	c = &language{
+		comments: cComments,
+		strings: []delim{
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+		},
+	}
+
+	golang = &language{
+		comments: cComments,
+		strings: []delim{
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+			{open: "`", close: "`", multiline: true},
+		},
+	}
+
+	python = &language{
+		comments: []delim{{open: "#", close: "\n"}},
+		strings: []delim{
+			{open: `"""`, close: `"""`, escape: true, multiline: true},
+			{open: `'''`, close: `'''`, escape: true, multiline: true},
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+		},
+	}
+
+	shell = &language{
+		comments: []delim{{open: "#", close: "\n"}},
+		strings: []delim{
+			{open: `"`, close: `"`, escape: true, multiline: true},
+			{open: `'`, close: `'`, multiline: true},
+		},
+		// Comments start at the beginning of a word only, unlike e.g. “$#” or
+		// “${#var}”.
+		commentAfter: func(prev byte) bool {
+			return prev == 0 || strings.IndexByte(" \t\n;&|()", prev) > -1
+		},
+	}
+
+	perl = &language{
+		comments: []delim{{open: "#", close: "\n"}},
+		strings: []delim{
+			{open: `"`, close: `"`, escape: true, multiline: true},
+			{open: `'`, close: `'`, escape: true, multiline: true},
+		},
+		// “$#array” is the last index of @array.
+		commentAfter: func(prev byte) bool { return prev != '$' },
+		pod:          true,
+	}
+
+	java = &language{
+		comments: cComments,
+		strings: []delim{
+			{open: `"""`, close: `"""`, escape: true, multiline: true},
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+		},
+	}
+
+	javascript = &language{
+		comments: cComments,
+		strings: []delim{
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+			{open: "`", close: "`", escape: true, multiline: true},
+		},
+	}
+)
+
+var languages = map[string]*language{
+	".c":    c,
+	".h":    c,
+	".cc":   c,
+	".cpp":  c,
+	".cxx":  c,
+	".hh":   c,
+	".hpp":  c,
+	".hxx":  c,
+	".m":    c,
+	".mm":   c,
+	".go":   golang,
+	".py":   python,
+	".sh":   shell,
+	".bash": shell,
+	".zsh":  shell,
+	".pl":   perl,
+	".pm":   perl,
+	".java": java,
+	".js":   javascript,
+	".mjs":  javascript,
+	".ts":   javascript,
+}
+
+// Supported reports whether Classify can classify the file called filename,
+// based on its extension.
+func Supported(filename string) bool {
+	return languages[strings.ToLower(path.Ext(filename))] != nil
+}
+
+// A span is a comment or string literal, covering the bytes [start, end).
+type span struct {
+	start, end int
+	scope      Scope
+}
+
+// A File is the classification of the content of a file.
+type File struct {
+	lineStarts []int  // offset of each line
+	spans      []span // sorted, non-overlapping
+}
+
+// Classify classifies content, which is the content (or a prefix of the
+// content) of the file called filename. It returns nil if the language of the
+// file is not supported.
+func Classify(filename string, content []byte) *File {
+	lang := languages[strings.ToLower(path.Ext(filename))]
+	if lang == nil {
+		return nil
+	}
+	f := &File{lineStarts: []int{0}}
+	for offset, b := range content {
+		if b == '\n' {
+			f.lineStarts = append(f.lineStarts, offset+1)
+		}
+	}
+	f.spans = lang.lex(content)
+	return f
+}
+
+// At returns the scope of the byte at column col (0-based, in bytes) of line
+// (1-based).
+func (f *File) At(line, col int) Scope {
+	if line < 1 || line > len(f.lineStarts) {
+		return Code
+	}
+	offset := f.lineStarts[line-1] + col
+	idx := sort.Search(len(f.spans), func(i int) bool { return f.spans[i].end > offset })
+	if idx < len(f.spans) && f.spans[idx].start <= offset {
+		return f.spans[idx].scope
+	}
+	return Code
+}
+
+func (lang *language) lex(content []byte) []span {
+	var spans []span
+	for i := 0; i < len(content); {
+		atLineStart := i == 0 || content[i-1] == '\n'
+		if lang.pod && atLineStart && isPOD(content[i:]) {
+			end := podEnd(content, i)
+			spans = append(spans, span{start: i, end: end, scope: Comment})
+			i = end
+			continue
+		}
+		if d, s, ok := lang.open(content, i); ok {
+			end := d.end(content, i+len(d.open))
+			spans = append(spans, span{start: i, end: end, scope: s})
+			i = end
+			continue
+		}
+		i++
+	}
+	return spans
+}
+
+// open returns the comment or string literal which starts at content[i:].
+func (lang *language) open(content []byte, i int) (delim, Scope, bool) {
+	rest := content[i:]
+	for _, d := range lang.comments {
+		if !bytes.HasPrefix(rest, []byte(d.open)) {
+			continue
+		}
+		var prev byte
+		if i > 0 {
+			prev = content[i-1]
+		}
+		if lang.commentAfter == nil || lang.commentAfter(prev) {
+			return d, Comment, true
+		}
+	}
+	for _, d := range lang.strings {
+		if bytes.HasPrefix(rest, []byte(d.open)) {
+			return d, String, true
+		}
+	}
+	return delim{}, Code, false
+}
+
+// end returns the end of the region of d whose content starts at i.
+// Unterminated regions end with the content (or the line, unless multiline).
+func (d delim) end(content []byte, i int) int {
+	for i < len(content) {
+		switch {
+		case d.escape && content[i] == '\\':
+			i += 2
+		case bytes.HasPrefix(content[i:], []byte(d.close)):
+			if d.close == "\n" {
+				return i // the newline is not part of the comment
+			}
+			return i + len(d.close)
+		case content[i] == '\n' && !d.multiline:
+			return i
+		default:
+			i++
+		}
+	}
+	return len(content)
+}
+
+// isPOD reports whether the line at the beginning of b starts a POD block.
+func isPOD(b []byte) bool {
+	return len(b) > 1 && b[0] == '=' && ('a' <= b[1] && b[1] <= 'z' || 'A' <= b[1] && b[1] <= 'Z')
+}
+
+// podEnd returns the end of the line “=cut” which ends the POD block starting
+// at i.
+func podEnd(content []byte, i int) int {
+	for {
+		idx := bytes.IndexByte(content[i:], '\n')
+		if idx == -1 {
+			return len(content)
+		}
+		i += idx + 1
+		if bytes.HasPrefix(content[i:], []byte("=cut")) {
+			if idx := bytes.IndexByte(content[i:], '\n'); idx > -1 {
+				return i + idx
+			}
+			return len(content)
+		}
+	}
+}
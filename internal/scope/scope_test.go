@@ -0,0 +1,123 @@
+package scope
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tt := range []struct {
+		filename string
+		content  string
+		// want maps words (which occur only once in content) to the scope of
+		// their first byte.
+		want map[string]Scope
+	}{
+		{
+			filename: "main.c",
+			content: `/* init: sets up
+ * everything */
+int init(void) {
+	// cleanup();
+	puts("cleanup(); // not a comment");
+	return '"' + quoted();
+}
+`,
+			want: map[string]Scope{
+				"init:":            Comment,
+				"everything":       Comment,
+				"init(void)":       Code,
+				"cleanup();\n":     Comment,
+				"puts":             Code,
+				`"cleanup(); //`:   String,
+				"// not a comment": String,
+				"quoted":           Code,
+			},
+		},
+		{
+			filename: "main.go",
+			content:  "func main() {\n\ts := `raw\n// still raw`\n\tfmt.Println(s) // print\n}\n",
+			want: map[string]Scope{
+				"main":        Code,
+				"raw\n":       String,
+				"still":       String,
+				"fmt.Println": Code,
+				"print":       Comment,
+			},
+		},
+		{
+			filename: "setup.py",
+			content:  "def f():\n    \"\"\"Docstring\n    # not a comment\n    \"\"\"\n    return 'x#y'  # comment\n",
+			want: map[string]Scope{
+				"def":           Code,
+				"Docstring":     String,
+				"not a comment": String,
+				"return":        Code,
+				"x#y":           String,
+				"# comment\n":   Comment,
+			},
+		},
+		{
+			filename: "build.sh",
+			content:  "echo $# ${#args} a#b # comment\necho 'it''s' \"$HOME\"\n",
+			want: map[string]Scope{
+				"$#":      Code,
+				"{#args}": Code,
+				"a#b":     Code,
+				"comment": Comment,
+				"it'":     String,
+				"$HOME":   String,
+			},
+		},
+		{
+			filename: "Foo.pm",
+			content:  "my $last = $#list; # last index\n\n=head1 NAME\n\nFoo\n\n=cut\n\nprint \"done\";\n",
+			want: map[string]Scope{
+				"$#list":     Code,
+				"last index": Comment,
+				"NAME":       Comment,
+				"Foo\n":      Comment,
+				"print":      Code,
+				"done":       String,
+			},
+		},
+	} {
+		t.Run(tt.filename, func(t *testing.T) {
+			f := Classify(tt.filename, []byte(tt.content))
+			if f == nil {
+				t.Fatalf("Classify(%q) = nil", tt.filename)
+			}
+			got := make(map[string]Scope)
+			for word := range tt.want {
+				offset := strings.Index(tt.content, word)
+				if offset == -1 {
+					t.Fatalf("%q not found in content", word)
+				}
+				line := strings.Count(tt.content[:offset], "\n") + 1
+				col := offset - (strings.LastIndex(tt.content[:offset], "\n") + 1)
+				got[word] = f.At(line, col)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("At: unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for filename, want := range map[string]bool{
+		"src/main.c":  true,
+		"lib/Foo.PM":  true,
+		"README":      false,
+		"Makefile.am": false,
+	} {
+		if got := Supported(filename); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", filename, got, want)
+		}
+		if got := Classify(filename, nil) != nil; got != want {
+			t.Errorf("Classify(%q) != nil = %v, want %v", filename, got, want)
+		}
+	}
+}
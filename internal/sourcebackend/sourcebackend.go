@@ -3,8 +3,9 @@ package sourcebackend
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"html"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
@@ -13,19 +14,37 @@ import (
 	"path"
 	"path/filepath"
 	"regexp/syntax"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Debian/dcs/internal/contentstore"
 	"github.com/Debian/dcs/internal/index"
 	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/Debian/dcs/internal/scope"
 	"github.com/Debian/dcs/ranking"
 	"github.com/Debian/dcs/regexp"
 	"github.com/google/renameio/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-func FilterByKeywords(rewritten *url.URL, files []ranking.ResultPath) []ranking.ResultPath {
+// sourcePackage returns the source package (e.g. “i3-wm_4.13-1”) of path,
+// the key of Suites. Paths without a directory are returned as a whole.
+func sourcePackage(path string) string {
+	pkg, _, _ := strings.Cut(path, "/")
+	return pkg
+}
+
+// FilterByKeywords removes all files which are excluded by the keywords in
+// rewritten (see search.RewriteQuery). The suite: and -suite: keywords are
+// evaluated using suites.
+func FilterByKeywords(rewritten *url.URL, files []ranking.ResultPath, suites Suites) []ranking.ResultPath {
 	// The "package:" keyword, if specified.
 	pkg := rewritten.Query().Get("package")
 	// The "-package:" keywords, if specified.
@@ -34,6 +53,10 @@ func FilterByKeywords(rewritten *url.URL, files []ranking.ResultPath) []ranking.
 	paths := rewritten.Query()["path"]
 	// The "-path" keywords, if specified.
 	npaths := rewritten.Query()["npath"]
+	// The "suite:" keywords, if specified.
+	wantSuites := rewritten.Query()["suite"]
+	// The "-suite:" keywords, if specified.
+	nsuites := rewritten.Query()["nsuite"]
 
 	// Filter the filenames if the "package:" keyword was specified.
 	if pkg != "" {
@@ -125,9 +148,152 @@ func FilterByKeywords(rewritten *url.URL, files []ranking.ResultPath) []ranking.
 		files = filtered
 	}
 
+	// Filter the filenames if the "suite:" keyword was specified. Multiple
+	// suites select the files of all of them.
+	if len(wantSuites) > 0 {
+		filtered := make(ranking.ResultPaths, 0, len(files))
+		for _, file := range files {
+			pkg := sourcePackage(file.Path)
+			for _, suite := range wantSuites {
+				if suites.Contains(pkg, suite) {
+					filtered = append(filtered, file)
+					break
+				}
+			}
+		}
+
+		files = filtered
+	}
+
+	for _, suite := range nsuites {
+		filtered := make(ranking.ResultPaths, 0, len(files))
+		for _, file := range files {
+			if suites.Contains(sourcePackage(file.Path), suite) {
+				continue
+			}
+
+			filtered = append(filtered, file)
+		}
+
+		files = filtered
+	}
+
+	// Filter the filenames if the "scope:" keywords were specified: only the
+	// matches in the languages which package scope supports can be
+	// classified.
+	if matchScopes(rewritten.Query()) != nil {
+		filtered := make(ranking.ResultPaths, 0, len(files))
+		for _, file := range files {
+			if scope.Supported(file.Path) {
+				filtered = append(filtered, file)
+			}
+		}
+
+		files = filtered
+	}
+
 	return files
 }
 
+// expandAliases returns files plus, for each file, one ranked copy per alias
+// of its docid (see index.Index.Aliases), so that keywords can select any
+// copy of a deduplicated file. The copies directly follow the consecutive
+// entries of their docid, which expandAliases copies as a whole.
+func expandAliases(ix *index.Index, files ranking.ResultPaths, opts *ranking.RankingOpts) (ranking.ResultPaths, error) {
+	expanded := make(ranking.ResultPaths, 0, len(files))
+	for len(files) > 0 {
+		n := 1
+		for n < len(files) && files[n].Docid == files[0].Docid && files[n].Path == files[0].Path {
+			n++
+		}
+		run := files[:n]
+		files = files[n:]
+		expanded = append(expanded, run...)
+		aliases, err := ix.Aliases(run[0].Docid)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range aliases {
+			copied := ranking.ResultPath{Path: alias, Docid: run[0].Docid}
+			copied.Rank(opts)
+			if copied.Ranking == -1 {
+				continue
+			}
+			for _, file := range run {
+				copied.Position = file.Position
+				expanded = append(expanded, copied)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// collapseAliases keeps only the best-ranked path of each docid in files (the
+// first one, on a tie), which is searched on behalf of all paths of the docid.
+// The other paths are returned by docid, to be reported as duplicates.
+func collapseAliases(files ranking.ResultPaths) (ranking.ResultPaths, map[uint32][]string) {
+	best := make(map[uint32]ranking.ResultPath)
+	for _, file := range files {
+		if b, ok := best[file.Docid]; !ok || file.Ranking > b.Ranking {
+			best[file.Docid] = file
+		}
+	}
+	collapsed := make(ranking.ResultPaths, 0, len(best))
+	duplicates := make(map[uint32][]string)
+	for idx, file := range files {
+		if file.Path == best[file.Docid].Path {
+			collapsed = append(collapsed, file)
+			continue
+		}
+		// Only the first entry of each path counts, positional queries
+		// return one entry per position.
+		if idx > 0 && files[idx-1].Path == file.Path && files[idx-1].Docid == file.Docid {
+			continue
+		}
+		duplicates[file.Docid] = append(duplicates[file.Docid], file.Path)
+	}
+	for _, paths := range duplicates {
+		sort.Strings(paths)
+	}
+	return collapsed, duplicates
+}
+
+// matchScopes returns the scopes to which the "scope:" and "-scope:" keywords
+// restrict the matches, or nil if neither was specified.
+func matchScopes(query url.Values) map[scope.Scope]bool {
+	want, nwant := query["matchscope"], query["nmatchscope"]
+	if len(want) == 0 && len(nwant) == 0 {
+		return nil
+	}
+	scopes := make(map[scope.Scope]bool)
+	if len(want) == 0 {
+		want = []string{"code", "comment", "string"}
+	}
+	for _, name := range want {
+		if s, ok := scope.Parse(name); ok {
+			scopes[s] = true
+		}
+	}
+	for _, name := range nwant {
+		if s, ok := scope.Parse(name); ok {
+			delete(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// rangesInScopes returns the ranges (within line) which start in one of
+// scopes.
+func rangesInScopes(f *scope.File, line int, ranges []regexp.Range, scopes map[scope.Scope]bool) []regexp.Range {
+	var result []regexp.Range
+	for _, r := range ranges {
+		if scopes[f.At(line, r.Start)] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
 type SourceReply struct {
 	// The number of the last used filename, needed for pagination
 	LastUsedFilename int
@@ -139,11 +305,137 @@ type Server struct {
 	// For forward compatibility
 	sourcebackendpb.UnimplementedSourceBackendServer
 
-	mu                 sync.Mutex
+	// Index is the index which the Server starts out with. ReplaceIndex and
+	// ReplaceDelta swap in new indexes without modifying this field.
 	Index              *index.Index
 	UnpackedPath       string
 	IndexPath          string
 	UsePositionalIndex bool
+
+	// CompressedPath (if non-empty) is the directory containing the compressed
+	// source packages (see package contentstore). Files which are not present
+	// in UnpackedPath are read from there.
+	CompressedPath string
+
+	// MaxContextLines caps the number of context lines which a SearchRequest
+	// can ask for (DefaultMaxContextLines if zero).
+	MaxContextLines int
+
+	// QueryTimeout is the time budget of a query: queries which take longer
+	// are aborted with a DeadlineExceeded error (no limit if zero).
+	QueryTimeout time.Duration
+
+	storeOnce sync.Once
+	store     *contentstore.Store
+
+	genOnce sync.Once
+	gen     atomic.Pointer[generation] // current index
+
+	loadedSuites atomic.Pointer[loadedSuites]
+}
+
+const (
+	// DefaultContextLines is the number of context lines before and after
+	// each match for SearchRequests which do not specify it.
+	DefaultContextLines = 2
+
+	// DefaultMaxContextLines is the default for Server.MaxContextLines.
+	DefaultMaxContextLines = 10
+)
+
+// readContext reads r into buf (growing it as needed) up to the end of the line
+// containing offset end and the following after context lines, or until EOF.
+func readContext(r io.Reader, buf []byte, end, after int) ([]byte, error) {
+	b := buf[:0]
+	scanned := end
+	newlines := 0
+	for {
+		if len(b) > scanned {
+			// The first newline at or after end terminates the line of the
+			// match, each further newline a context line.
+			for idx := bytes.IndexByte(b[scanned:], '\n'); idx != -1; idx = bytes.IndexByte(b[scanned:], '\n') {
+				scanned += idx + 1
+				if newlines++; newlines > after {
+					return b, nil
+				}
+			}
+			scanned = len(b)
+		}
+		if len(b) == cap(b) {
+			b = slices.Grow(b, max(end+512*(after+1)-len(b), 4096))
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// contextLines returns the number of context lines to return for the
+// (optional) SearchRequest field n.
+func (s *Server) contextLines(n *uint32) int {
+	if n == nil {
+		return DefaultContextLines
+	}
+	limit := s.MaxContextLines
+	if limit == 0 {
+		limit = DefaultMaxContextLines
+	}
+	return min(int(*n), limit)
+}
+
+// A generation is an index which was loaded into the Server. The Server holds
+// one reference to the current generation, and every in-flight query holds one
+// reference to the generation it started with, so that replacing the index
+// neither blocks on nor disturbs running queries: the index is closed once the
+// last reference is released.
+type generation struct {
+	ix   *index.Index
+	refs atomic.Int64
+}
+
+func newGeneration(ix *index.Index) *generation {
+	g := &generation{ix: ix}
+	g.refs.Store(1)
+	return g
+}
+
+func (g *generation) release() {
+	if g.refs.Add(-1) == 0 {
+		if err := g.ix.Close(); err != nil {
+			log.Printf("closing replaced index: %v", err)
+		}
+	}
+}
+
+func (s *Server) initGeneration() {
+	s.genOnce.Do(func() {
+		s.gen.Store(newGeneration(s.Index))
+	})
+}
+
+// acquire returns the current generation, which the caller must release.
+func (s *Server) acquire() *generation {
+	s.initGeneration()
+	for {
+		g := s.gen.Load()
+		if n := g.refs.Load(); n > 0 && g.refs.CompareAndSwap(n, n+1) {
+			return g
+		}
+		// g was replaced (and its last reference released) since we loaded
+		// it, so the next Load returns its replacement.
+	}
+}
+
+// swap atomically makes ix the current index. The previous index is closed
+// once its in-flight queries finish.
+func (s *Server) swap(ix *index.Index) {
+	s.initGeneration()
+	s.gen.Swap(newGeneration(ix)).release()
 }
 
 // Serves a single file for displaying it in /show
@@ -156,7 +448,7 @@ func (s *Server) File(ctx context.Context, in *sourcebackendpb.FileRequest) (*so
 		return nil, fmt.Errorf("Path traversal is bad, mhkay?")
 	}
 
-	contents, err := ioutil.ReadFile(absPath)
+	contents, err := s.contentStore().ReadFile(s.relPath(absPath))
 	if err != nil {
 		return nil, err
 	}
@@ -165,6 +457,24 @@ func (s *Server) File(ctx context.Context, in *sourcebackendpb.FileRequest) (*so
 	}, nil
 }
 
+// contentStore returns the store from which the contents of the files in the
+// index are read.
+func (s *Server) contentStore() *contentstore.Store {
+	s.storeOnce.Do(func() {
+		s.store = &contentstore.Store{
+			Unpacked:   s.UnpackedPath,
+			Compressed: s.CompressedPath,
+		}
+	})
+	return s.store
+}
+
+// relPath returns the path of the file at name (within UnpackedPath) relative
+// to UnpackedPath, e.g. “i3-wm_4.13-1/i3bar/src/xcb.c”.
+func (s *Server) relPath(name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, s.UnpackedPath), "/")
+}
+
 func sendProgressUpdate(stream sourcebackendpb.SourceBackend_SearchServer, connMu *sync.Mutex, filesProcessed, filesTotal int) error {
 	connMu.Lock()
 	defer connMu.Unlock()
@@ -178,8 +488,9 @@ func sendProgressUpdate(stream sourcebackendpb.SourceBackend_SearchServer, connM
 }
 
 type entry struct {
-	fn  string
-	pos uint32
+	fn    string
+	docid uint32
+	pos   uint32
 }
 
 func countNL(b []byte) int {
@@ -213,16 +524,12 @@ func (s *Server) ReplaceIndex(ctx context.Context, in *sourcebackendpb.ReplaceIn
 			newShard = filepath.Join(filepath.Dir(s.IndexPath), name)
 			// We verified the given argument refers to an index shard within
 			// this directory, so let’s load this shard.
-			oldIndex := s.Index
 			log.Printf("Trying to load %q\n", newShard)
 			newIndex, err := index.Open(newShard)
 			if err != nil {
 				return nil, err
 			}
-			s.mu.Lock()
-			s.Index = newIndex
-			s.mu.Unlock()
-			defer oldIndex.Close()
+			s.swap(newIndex)
 
 			if err := renameio.Symlink(newShard, s.IndexPath); err != nil {
 				return nil, err
@@ -231,8 +538,14 @@ func (s *Server) ReplaceIndex(ctx context.Context, in *sourcebackendpb.ReplaceIn
 			if err != nil {
 				return nil, err
 			}
+			// The new index contains all packages, so any delta index is now
+			// obsolete.
+			if err := os.Remove(DeltaPath(s.IndexPath)); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
 			for _, fi := range fis {
-				if !strings.HasPrefix(fi.Name(), "full.") {
+				if !strings.HasPrefix(fi.Name(), "full.") &&
+					!strings.HasPrefix(fi.Name(), "delta.") {
 					continue
 				}
 				if fi.Name() == name {
@@ -250,44 +563,373 @@ func (s *Server) ReplaceIndex(ctx context.Context, in *sourcebackendpb.ReplaceIn
 	return nil, fmt.Errorf("No such shard.")
 }
 
-func (s *Server) queryPositional(literal string) ([]entry, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	log.Printf("queryPositional(%q)", literal)
-	matches, err := s.Index.QueryPositional(literal)
+// searchFilenames answers queries which consist of keywords only (e.g.
+// “path:meson_options.txt”) by sending one match (with line 0 and no context)
+// per file which passes the keyword filters, without reading any files. If
+// maxResults is non-zero, only the best-ranked maxResults files are sent.
+func (s *Server) searchFilenames(ix *index.Index, rewritten *url.URL, rankingopts ranking.RankingOpts, maxResults int, stream sourcebackendpb.SourceBackend_SearchServer, connMu *sync.Mutex, logprefix string) error {
+	suites := s.suites()
+	// Filter in chunks so that only the matching files are held in memory.
+	// With maxResults, only the best maxResults of them are kept.
+	const chunkSize = 10000
+	var files ranking.ResultPaths
+	var matching int
+	chunk := make(ranking.ResultPaths, 0, chunkSize)
+	flush := func() {
+		filtered := FilterByKeywords(rewritten, chunk, suites)
+		matching += len(filtered)
+		files = append(files, filtered...)
+		chunk = chunk[:0]
+		if maxResults > 0 && len(files) > 2*maxResults {
+			// The order of ResultPaths is total, so truncating early keeps
+			// the same files as truncating after all chunks.
+			sort.Sort(files)
+			files = files[:maxResults]
+		}
+	}
+	if err := ix.Files(func(docid uint32, fn string) error {
+		result := ranking.ResultPath{Path: fn, Docid: docid}
+		result.Rank(&rankingopts)
+		if result.Ranking > -1 {
+			chunk = append(chunk, result)
+		}
+		if len(chunk) == chunkSize {
+			flush()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	flush()
+	sort.Sort(files)
+
+	log.Printf("%s filename search, %d matching files\n", logprefix, matching)
+
+	if err := sendProgressUpdate(stream, connMu, 0, len(files)); err != nil {
+		return fmt.Errorf("%s %v\n", logprefix, err)
+	}
+	send := files
+	if maxResults > 0 && len(send) > maxResults {
+		send = send[:maxResults]
+	}
+	for _, file := range send {
+		pkg := sourcePackage(file.Path)
+		connMu.Lock()
+		err := stream.Send(&sourcebackendpb.SearchReply{
+			Type: sourcebackendpb.SearchReply_MATCH,
+			Match: &sourcebackendpb.Match{
+				Path:     file.Path,
+				Package:  pkg,
+				Suites:   suites[pkg],
+				Pathrank: file.Ranking,
+				Ranking:  1,
+			},
+		})
+		connMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("%s %v\n", logprefix, err)
+		}
+	}
+	return sendProgressUpdate(stream, connMu, len(files), len(files))
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') &&
+			(i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// definitionLines returns the lines which define symbol, by docid (which
+// covers all copies of a deduplicated file).
+func definitionLines(ix *index.Index, symbol string) (map[uint32][]int, error) {
+	if symbol == "" {
+		return nil, nil
+	}
+	defs, err := ix.Definitions(symbol)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[uint32][]int)
+	for _, d := range defs {
+		lines[d.Docid] = append(lines[d.Docid], int(d.Line))
+	}
+	return lines, nil
+}
+
+// DeltaPath returns the path of the delta index symlink belonging to the index
+// at indexPath.
+func DeltaPath(indexPath string) string {
+	return filepath.Join(filepath.Dir(indexPath), "delta")
+}
+
+func (s *Server) ReplaceDelta(ctx context.Context, in *sourcebackendpb.ReplaceDeltaRequest) (*sourcebackendpb.ReplaceDeltaReply, error) {
+	names, err := readDirNames(filepath.Dir(s.IndexPath))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if name != in.ReplacementPath || !strings.HasPrefix(name, "delta.") {
+			continue
+		}
+		newDelta := filepath.Join(filepath.Dir(s.IndexPath), name)
+		// We verified the given argument refers to a delta index within this
+		// directory, so let’s load it on top of the current index.
+		log.Printf("Trying to load %q on top of %q\n", newDelta, s.IndexPath)
+		newIndex, err := index.OpenDelta(s.IndexPath, newDelta)
+		if err != nil {
+			return nil, err
+		}
+		s.swap(newIndex)
+
+		if err := renameio.Symlink(newDelta, DeltaPath(s.IndexPath)); err != nil {
+			return nil, err
+		}
+		for _, other := range names {
+			if !strings.HasPrefix(other, "delta.") || other == name {
+				continue
+			}
+			log.Printf("Removing old delta index %q", other)
+			if err := os.RemoveAll(filepath.Join(filepath.Dir(s.IndexPath), other)); err != nil {
+				return nil, err
+			}
+		}
+		return &sourcebackendpb.ReplaceDeltaReply{}, nil
+	}
+
+	return nil, fmt.Errorf("No such delta index.")
+}
+
+func readDirNames(dir string) ([]string, error) {
+	file, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Readdirnames(-1)
+}
+
+func queryPositional(ix *index.Index, literal string, foldCase bool) ([]entry, error) {
+	log.Printf("queryPositional(%q, foldCase=%v)", literal, foldCase)
+	queryPositional := ix.QueryPositional
+	if foldCase {
+		queryPositional = ix.QueryPositionalFold
+	}
+	matches, err := queryPositional(literal)
+	if errors.Is(err, index.ErrQueryTooBroad) {
+		// Not an internal error: dcs-web displays this message to the user.
+		return nil, status.Errorf(codes.InvalidArgument, "%q matches too many positions, please use a longer search term", literal)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("ix.QueryPositional(%q): %v", literal, err)
 	}
 	possible := make([]entry, len(matches))
 	for idx, match := range matches {
-		fn, err := s.Index.DocidMap.Lookup(match.Docid)
+		fn, err := ix.Lookup(match.Docid)
 		if err != nil {
-			return nil, fmt.Errorf("DocidMap.Lookup(%v): %v", match.Docid, err)
+			return nil, fmt.Errorf("Lookup(%v): %v", match.Docid, err)
 		}
 		possible[idx] = entry{
-			fn:  fn,
-			pos: match.Position,
+			fn:    fn,
+			docid: match.Docid,
+			pos:   match.Position,
 		}
 	}
 
 	return possible, nil
 }
 
-func (s *Server) query(query *index.Query) ([]string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	post := s.Index.PostingQuery(query)
-	possible := make([]string, len(post))
-	var err error
+// queryRequest is implemented by sourcebackendpb.SearchRequest and
+// sourcebackendpb.ExplainRequest.
+type queryRequest interface {
+	GetQuery() string
+	GetIdentifier() bool
+	GetIgnoreWhitespace() bool
+	GetIgnoreLineBreaks() bool
+}
+
+// ignoreWhitespace reports whether the query of in is a whitespace-insensitive
+// literal (see index.WhitespaceRegexp).
+func ignoreWhitespace(in queryRequest) bool {
+	return !in.GetIdentifier() && (in.GetIgnoreWhitespace() || in.GetIgnoreLineBreaks())
+}
+
+// parseQuery parses the query of a request. Identifiers are expanded to match
+// in any identifier style (see index.IdentifierRegexp), whitespace-insensitive
+// literals to match any whitespace (see index.WhitespaceRegexp).
+func parseQuery(in queryRequest, flags syntax.Flags) (*syntax.Regexp, error) {
+	if in.GetIdentifier() {
+		return index.IdentifierRegexp(in.GetQuery())
+	}
+	if ignoreWhitespace(in) {
+		return index.WhitespaceRegexp(in.GetQuery())
+	}
+	return syntax.Parse(in.GetQuery(), flags)
+}
+
+// trigramQuery returns the trigram query for re, the parsed query of in.
+func trigramQuery(in queryRequest, re *syntax.Regexp) *index.Query {
+	if ignoreWhitespace(in) {
+		return index.WhitespaceQuery(in.GetQuery())
+	}
+	return index.RegexpQuery(re)
+}
+
+// requiredFragments returns the required fragments of re, the parsed query of
+// in. Matches spanning lines can exceed the maximum line length which bounds
+// the distance between fragments (see index.FilterFragments), so none are
+// returned for them.
+func requiredFragments(in queryRequest, re *syntax.Regexp) []index.Fragment {
+	if ignoreWhitespace(in) && in.GetIgnoreLineBreaks() {
+		return nil
+	}
+	return index.RequiredFragments(re)
+}
+
+// parseAnd parses the regexps of the and: keywords (see search.RewriteQuery),
+// which must match within the same file as the query, and the number of lines
+// of the near: keyword (0 if not specified).
+func parseAnd(query url.Values, flags syntax.Flags) ([]*syntax.Regexp, int, error) {
+	var parts []*syntax.Regexp
+	for _, expr := range query["and"] {
+		part, err := syntax.Parse(expr, flags)
+		if err != nil {
+			return nil, 0, err
+		}
+		parts = append(parts, part)
+	}
+	var near int
+	if v := query.Get("near"); v != "" {
+		var err error
+		near, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return parts, near, nil
+}
+
+// postingQuery returns the candidates for query. fragments contains the
+// required fragments of the query and of each and: part (if the positional
+// index is used), near is the number of lines of the near: keyword.
+func postingQuery(ix *index.Index, query *index.Query, fragments [][]index.Fragment, near int) ([]entry, error) {
+	post := ix.PostingQuery(query)
+	// Narrow down the candidates to files in which the required literal
+	// fragments appear in the right order (and close enough to each other),
+	// so that fewer files need to be read by regexp.Grep.
+	for _, f := range fragments {
+		var err error
+		post, err = ix.FilterFragments(post, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if near > 0 {
+		var err error
+		post, err = ix.FilterNear(post, fragments, near)
+		if err != nil {
+			return nil, err
+		}
+	}
+	possible := make([]entry, len(post))
 	for idx, docid := range post {
-		possible[idx], err = s.Index.DocidMap.Lookup(docid)
+		fn, err := ix.Lookup(docid)
 		if err != nil {
 			return nil, err
 		}
+		possible[idx] = entry{fn: fn, docid: docid}
 	}
 	return possible, nil
 }
 
+// Explain returns the trigram query plan and the number of candidate files after
+// each filtering step of Search, which helps understanding why a query is slow.
+func (s *Server) Explain(ctx context.Context, in *sourcebackendpb.ExplainRequest) (*sourcebackendpb.ExplainReply, error) {
+	flags := syntax.Perl
+	if in.GetLiteral() {
+		flags |= syntax.Literal
+	}
+	re, err := parseQuery(in, flags)
+	if err != nil {
+		return nil, err
+	}
+	rewritten, err := url.Parse(in.RewrittenUrl)
+	if err != nil {
+		return nil, err
+	}
+	rankingopts := ranking.RankingOptsFromQuery(rewritten.Query())
+	andParts, near, err := parseAnd(rewritten.Query(), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	query := trigramQuery(in, re)
+	for _, part := range andParts {
+		query = query.And(index.RegexpQuery(part))
+	}
+	_, _, positional := index.PositionalLiteral(re)
+	reply := &sourcebackendpb.ExplainReply{
+		QueryTree:  query.String(),
+		Positional: s.UsePositionalIndex && positional && len(andParts) == 0,
+	}
+
+	g := s.acquire()
+	defer g.release()
+	for _, t := range query.Trigrams() {
+		tri := index.Trigram(uint32(t[0])<<16 | uint32(t[1])<<8 | uint32(t[2]))
+		docids, positions := g.ix.TrigramEntries(tri)
+		reply.Trigrams = append(reply.Trigrams, &sourcebackendpb.TrigramEntries{
+			Trigram:      t,
+			DocidEntries: docids,
+			PosEntries:   positions,
+		})
+	}
+	post := g.ix.PostingQuery(query)
+	reply.PostingQueryFiles = uint64(len(post))
+	if s.UsePositionalIndex {
+		fragments := [][]index.Fragment{requiredFragments(in, re)}
+		for _, part := range andParts {
+			fragments = append(fragments, index.RequiredFragments(part))
+		}
+		for _, f := range fragments {
+			post, err = g.ix.FilterFragments(post, f)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if near > 0 {
+			post, err = g.ix.FilterNear(post, fragments, near)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	reply.FragmentFiles = uint64(len(post))
+
+	files := make(ranking.ResultPaths, 0, len(post))
+	for _, docid := range post {
+		fn, err := g.ix.Lookup(docid)
+		if err != nil {
+			return nil, err
+		}
+		result := ranking.ResultPath{Path: fn, Docid: docid}
+		result.Rank(&rankingopts)
+		if result.Ranking > -1 {
+			files = append(files, result)
+		}
+	}
+	if files, err = expandAliases(g.ix, files, &rankingopts); err != nil {
+		return nil, err
+	}
+	files, _ = collapseAliases(FilterByKeywords(rewritten, files, s.suites()))
+	reply.KeywordFiles = uint64(len(files))
+	return reply, nil
+}
+
 // Reads a single JSON request from the TCP connection, performs the search and
 // sends results back over the TCP connection as they appear.
 func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendpb.SourceBackend_SearchServer) error {
@@ -298,7 +940,7 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 	if in.GetLiteral() {
 		flags |= syntax.Literal
 	}
-	re, err := syntax.Parse(in.Query, flags)
+	re, err := parseQuery(in, flags)
 	if err != nil {
 		return err
 	}
@@ -309,15 +951,47 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 		return err
 	}
 	rankingopts := ranking.RankingOptsFromQuery(rewritten.Query())
+	andParts, near, err := parseAnd(rewritten.Query(), flags)
+	if err != nil {
+		return err
+	}
+	scopes := matchScopes(rewritten.Query())
+	// With max_results, matches are sent once all files which could contain
+	// one of the best matches are searched.
+	var top *topResults
+	if in.GetMaxResults() > 0 {
+		top = newTopResults(int(in.GetMaxResults()), ranking.MaxPostRank(rankingopts))
+	}
+	bonus := maxPathBonus(rankingopts)
 
 	// TODO: analyze the query to see if fast path can be taken
 	// maybe by using a different worker?
-	simplified := re.Simplify()
-	caseSensitive := simplified.Flags&syntax.FoldCase != 0
-	queryPos := s.UsePositionalIndex && simplified.Op == syntax.OpLiteral && !caseSensitive
+	// The index generation remains open until this query is done, even if
+	// it is replaced in the meantime.
+	g := s.acquire()
+	defer g.release()
+
+	// Aborting queries which exceed their time budget prevents a single
+	// (e.g. adversarial) query from saturating the shard. The workers stop
+	// early when the client goes away, too.
+	ctx := stream.Context()
+	if s.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.QueryTimeout)
+		defer cancel()
+	}
+
+	if in.Query == "" {
+		return s.searchFilenames(g.ix, rewritten, rankingopts, int(in.GetMaxResults()), stream, connMu, logprefix)
+	}
+
+	literal, foldCase, positional := index.PositionalLiteral(re)
+	// Files need to be searched for each and: part, so the positional index
+	// (which locates the matches of the query only) cannot be used.
+	queryPos := s.UsePositionalIndex && positional && len(andParts) == 0
 	var files ranking.ResultPaths
 	if queryPos {
-		possible, err := s.queryPositional(string(simplified.Rune))
+		possible, err := queryPositional(g.ix, literal, foldCase)
 		if err != nil {
 			return err
 		}
@@ -325,6 +999,7 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 		for _, entry := range possible {
 			result := ranking.ResultPath{
 				Path:     entry.fn,
+				Docid:    entry.docid,
 				Position: int(entry.pos),
 			}
 			result.Rank(&rankingopts)
@@ -333,15 +1008,26 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 			}
 		}
 	} else {
-		possible, err := s.query(index.RegexpQuery(re))
+		query := trigramQuery(in, re)
+		var fragments [][]index.Fragment
+		if s.UsePositionalIndex {
+			fragments = append(fragments, requiredFragments(in, re))
+		}
+		for _, part := range andParts {
+			query = query.And(index.RegexpQuery(part))
+			if s.UsePositionalIndex {
+				fragments = append(fragments, index.RequiredFragments(part))
+			}
+		}
+		possible, err := postingQuery(g.ix, query, fragments, near)
 		if err != nil {
 			return err
 		}
 
 		// Rank all the paths.
 		files = make(ranking.ResultPaths, 0, len(possible))
-		for _, filename := range possible {
-			result := ranking.ResultPath{Path: filename}
+		for _, entry := range possible {
+			result := ranking.ResultPath{Path: entry.fn, Docid: entry.docid}
 			result.Rank(&rankingopts)
 			if result.Ranking > -1 {
 				files = append(files, result)
@@ -350,8 +1036,35 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 
 	}
 
-	// Filter all files that should be excluded.
-	files = FilterByKeywords(rewritten, files)
+	// The definitions of the sym: keyword restrict the files to search and the
+	// lines to return. Definitions of an identifier query are ranked higher.
+	symbol := rewritten.Query().Get("sym")
+	onlyDefinitions := symbol != ""
+	if symbol == "" && !foldCase && isIdentifier(literal) {
+		symbol = literal
+	}
+	definitions, err := definitionLines(g.ix, symbol)
+	if err != nil {
+		return err
+	}
+	if onlyDefinitions {
+		filtered := make(ranking.ResultPaths, 0, len(files))
+		for _, file := range files {
+			if _, ok := definitions[file.Docid]; ok {
+				filtered = append(filtered, file)
+			}
+		}
+		files = filtered
+	}
+
+	// Filter all files that should be excluded. Each copy of a deduplicated
+	// file is filtered on its own, but only the best remaining copy is
+	// searched and the others are reported as its duplicates.
+	if files, err = expandAliases(g.ix, files, &rankingopts); err != nil {
+		return err
+	}
+	suites := s.suites()
+	files, duplicates := collapseAliases(FilterByKeywords(rewritten, files, suites))
 
 	// While not strictly necessary, this will lead to better results being
 	// discovered (and returned!) earlier, so let’s spend a few cycles on
@@ -362,6 +1075,25 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 
 	log.Printf("%s regexp = %q, %d possible files\n", logprefix, re, len(files))
 
+	// The compiled regexp (including its lazily built DFA) is shared by all
+	// workers. With the positional index, it only locates the literal within
+	// the matching lines.
+	expr := in.Query
+	if queryPos || in.GetLiteral() || in.GetIdentifier() || ignoreWhitespace(in) {
+		expr = re.String()
+	}
+	grepRe, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	grepAnd := make([]*regexp.Regexp, len(andParts))
+	for idx, part := range andParts {
+		grepAnd[idx], err = regexp.Compile(part.String())
+		if err != nil {
+			return err
+		}
+	}
+
 	// Send the first progress update so that clients know how many files are
 	// going to be searched.
 	if err := sendProgressUpdate(stream, connMu, 0, len(files)); err != nil {
@@ -378,6 +1110,9 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 	// the grepping will naturally become slower.
 	progress := make(chan int)
 
+	// aborted is set once workers skip files because ctx is done.
+	var aborted atomic.Bool
+
 	var wg sync.WaitGroup
 
 	go func() {
@@ -389,7 +1124,9 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 			add := <-progress
 			cnt += add
 
-			if time.Since(lastProgressUpdate) > progressInterval {
+			// With max_results, the final update must follow the matches,
+			// see below.
+			if time.Since(lastProgressUpdate) > progressInterval && (top == nil || cnt < len(files)) {
 				if err := sendProgressUpdate(stream, connMu, cnt, len(files)); err != nil {
 					if !errorShown {
 						log.Printf("%s %v\n", logprefix, err)
@@ -403,8 +1140,11 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 			}
 		}
 
-		if err := sendProgressUpdate(stream, connMu, len(files), len(files)); err != nil {
-			log.Printf("%s %v\n", logprefix, err)
+		// An aborted query is not complete, see the error returned below.
+		if !aborted.Load() && top == nil {
+			if err := sendProgressUpdate(stream, connMu, len(files), len(files)); err != nil {
+				log.Printf("%s %v\n", logprefix, err)
+			}
 		}
 		close(progress)
 
@@ -412,28 +1152,54 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 	}()
 
 	querystr := ranking.NewQueryStr(in.Query)
+	ctxBefore := s.contextLines(in.ContextBefore)
+	ctxAfter := s.contextLines(in.ContextAfter)
 
 	numWorkers := 1000
 	if len(files) < numWorkers {
 		numWorkers = len(files)
 	}
+	// send sends m or, with max_results, keeps it until all files are
+	// searched.
+	send := func(m *sourcebackendpb.Match) error {
+		if top == nil {
+			return sendMatch(stream, connMu, m, in.AllOccurrences)
+		}
+		for _, o := range occurrences(m, in.AllOccurrences) {
+			top.add(o)
+		}
+		return nil
+	}
 	var workerFn func()
 	if queryPos {
 		work := make(chan []ranking.ResultPath)
 		go func() {
 			var last string
-			var bundle []ranking.ResultPath
+			var bundles [][]ranking.ResultPath
 			for _, fn := range files {
 				if fn.Path != last {
-					if len(bundle) > 0 {
-						work <- bundle
-						bundle = nil
-					}
+					bundles = append(bundles, nil)
 					last = fn.Path
 				}
-				bundle = append(bundle, fn)
+				bundles[len(bundles)-1] = append(bundles[len(bundles)-1], fn)
+			}
+			if top != nil {
+				// Search the best-ranked files first, so that searching can
+				// stop early.
+				sort.SliceStable(bundles, func(i, j int) bool {
+					return bundles[i][0].Ranking > bundles[j][0].Ranking
+				})
 			}
-			if len(bundle) > 0 {
+			for idx, bundle := range bundles {
+				if top != nil && top.complete(bundle[0].Ranking+bonus) {
+					// None of the remaining files can contain a better match.
+					var skipped int
+					for _, bundle := range bundles[idx:] {
+						skipped += len(bundle)
+					}
+					progress <- skipped
+					break
+				}
 				work <- bundle
 			}
 			close(work)
@@ -447,16 +1213,26 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 		workerFn = func() {
 			defer wg.Done()
 			buf := make([]byte, 0, 64*1024)
-			rqb := []byte(string(simplified.Rune))
+			rqb := []byte(literal)
+			equal := bytes.Equal
+			if foldCase {
+				equal = bytes.EqualFold
+			}
 
 			for bundle := range work {
+				if ctx.Err() != nil {
+					aborted.Store(true)
+					for range bundle {
+						progress <- 1
+					}
+					continue
+				}
 
-				// TODO: figure out how to safely clone a dcs/regexp
 				// Turns out open+read+close is significantly faster than
 				// mmap'ing a whole bunch of small files (most of our files are
 				// << 64 KB).
 				// https://eklausmeier.wordpress.com/2016/02/03/performance-comparison-mmap-versus-read-versus-fread/
-				f, err := os.Open(filepath.Join(s.UnpackedPath, bundle[0].Path))
+				f, err := s.contentStore().Open(bundle[0].Path)
 				if err != nil {
 					log.Printf("%s %v", logprefix, err)
 					for range bundle {
@@ -464,14 +1240,10 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 					}
 					continue
 				}
-				const extraBytes = 1024 // for context lines
 				// Assumption: bundle is ordered from low to high (if not, we
 				// need to traverse bundle).
-				max := bundle[len(bundle)-1].Position + len(rqb) + extraBytes
-				if max > cap(buf) {
-					buf = make([]byte, 0, max)
-				}
-				n, err := f.Read(buf[:max])
+				b, err := readContext(f, buf, bundle[len(bundle)-1].Position+len(rqb), ctxAfter)
+				f.Close()
 				if err != nil {
 					log.Printf("%s %v", logprefix, err)
 					for range bundle {
@@ -479,8 +1251,13 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 					}
 					continue
 				}
-				f.Close()
-				b := buf[:n]
+				buf = b[:0] // keep the grown buffer for the next bundle
+				// The scope of a match only depends on the content before it.
+				var classified *scope.File
+				if scopes != nil {
+					classified = scope.Classify(bundle[0].Path, b)
+				}
+				contentHash := g.ix.ContentHash(bundle[0].Docid)
 
 				lastPos := -1
 				for _, fn := range bundle {
@@ -497,40 +1274,49 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 						fn.Ranking += 0.0008 * querystr.Match(&sourcePkgName)
 					}
 
-					if fn.Position+len(rqb) > len(b) || !bytes.Equal(b[fn.Position:fn.Position+len(rqb)], rqb) {
+					if fn.Position+len(rqb) > len(b) || !equal(b[fn.Position:fn.Position+len(rqb)], rqb) {
 						continue
 					}
 					if lastPos > -1 && !bytes.ContainsRune(b[lastPos:fn.Position], '\n') {
-						continue // cap to one match per line, like grep()
+						// One match per line, like grep(). The ranges of the
+						// match cover any further occurrences on the line.
+						continue
 					}
 					//fmt.Printf("%s:%d\n", fn.Path, fn.Position)
 					lastPos = fn.Position
 
 					line := countNL(b[:fn.Position]) + 1
 					match := regexp.Match{
-						Path: fn.Path,
-						Line: line,
+						Path:       fn.Path,
+						Line:       line,
+						Definition: slices.Contains(definitions[fn.Docid], line),
 						//Context: string(line),
 					}
-					match.PathRank = ranking.PostRank(rankingopts, &match, &querystr)
-					five := index.FiveLines(b, fn.Position)
-					connMu.Lock()
-					if err := stream.Send(&sourcebackendpb.SearchReply{
-						Type: sourcebackendpb.SearchReply_MATCH,
-						Match: &sourcebackendpb.Match{
-							Path:     fn.Path,
-							Line:     uint32(line),
-							Package:  fn.Path[:strings.Index(fn.Path, "/")],
-							Ctxp2:    html.EscapeString(five[0]),
-							Ctxp1:    html.EscapeString(five[1]),
-							Context:  html.EscapeString(five[2]),
-							Ctxn1:    html.EscapeString(five[3]),
-							Ctxn2:    html.EscapeString(five[4]),
-							Pathrank: match.PathRank,
-							Ranking:  fn.Ranking,
-						},
+					if onlyDefinitions && !match.Definition {
+						continue
+					}
+					before, context, after := index.ContextLines(b, fn.Position, ctxBefore, ctxAfter)
+					ranges := grepRe.Ranges(context)
+					if classified != nil {
+						if ranges = rangesInScopes(classified, line, ranges, scopes); len(ranges) == 0 {
+							continue
+						}
+					}
+					match.Ranking = ranking.PostRank(rankingopts, &match, &querystr)
+					if err := send(&sourcebackendpb.Match{
+						Path:          fn.Path,
+						Line:          uint32(line),
+						Package:       fn.Path[:strings.Index(fn.Path, "/")],
+						Suites:        suites[sourcePackage(fn.Path)],
+						ContextBefore: before,
+						Context:       context,
+						Ranges:        pbRanges(ranges),
+						ContextAfter:  after,
+						Pathrank:      fn.Ranking,
+						Ranking:       match.Ranking,
+						ContentHash:   contentHash,
+						Duplicates:    duplicates[fn.Docid],
 					}); err != nil {
-						connMu.Unlock()
 						log.Printf("%s %v\n", logprefix, err)
 						// Drain the work channel, but without doing any work.
 						// This effectively exits the worker goroutine(s)
@@ -539,38 +1325,54 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 						}
 						break
 					}
-					connMu.Unlock()
 				}
 			}
 		}
 	} else {
+		// We add the additional 1 for the progress updater goroutine. It also
+		// needs to be done before we can return, otherwise it will try to use the
+		// (already closed) network connection, which is a fatal error.
+		wg.Add(len(files) + 1)
+
 		work := make(chan ranking.ResultPath)
 		go func() {
-			for _, file := range files {
+			for idx, file := range files {
+				if top != nil && top.complete(file.Ranking+bonus) {
+					// None of the remaining files can contain a better
+					// match, as files are sorted by their pre-ranking.
+					skipped := len(files) - idx
+					progress <- skipped
+					wg.Add(-skipped)
+					break
+				}
 				work <- file
 			}
 			close(work)
 		}()
 
-		// We add the additional 1 for the progress updater goroutine. It also
-		// needs to be done before we can return, otherwise it will try to use the
-		// (already closed) network connection, which is a fatal error.
-		wg.Add(len(files) + 1)
-
 		workerFn = func() {
-			re, err := regexp.Compile(in.Query)
-			if err != nil {
-				log.Printf("%s\n", err)
-				return
-			}
-
 			grep := regexp.Grep{
-				Regexp: re,
-				Stdout: os.Stdout,
-				Stderr: os.Stderr,
+				Regexp:        grepRe,
+				Stdout:        os.Stdout,
+				Stderr:        os.Stderr,
+				ContextBefore: ctxBefore,
+				ContextAfter:  ctxAfter,
+				And:           grepAnd,
+				Near:          near,
+				Multiline:     ignoreWhitespace(in) && in.GetIgnoreLineBreaks(),
+				Open: func(name string) (io.ReadCloser, error) {
+					return s.contentStore().Open(s.relPath(name))
+				},
 			}
 
 			for file := range work {
+				if ctx.Err() != nil {
+					aborted.Store(true)
+					progress <- 1
+					wg.Done()
+					continue
+				}
+
 				sourcePkgName := file.Path[file.SourcePkgIdx[0]:file.SourcePkgIdx[1]]
 				if rankingopts.Pathmatch {
 					file.Ranking += querystr.Match(&file.Path)
@@ -583,9 +1385,31 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 					file.Ranking += 0.0008 * querystr.Match(&sourcePkgName)
 				}
 
-				// TODO: figure out how to safely clone a dcs/regexp
 				matches := grep.File(path.Join(s.UnpackedPath, file.Path))
+				var contentHash []byte
+				if len(matches) > 0 {
+					contentHash = g.ix.ContentHash(file.Docid)
+				}
+				var classified *scope.File
+				if len(matches) > 0 && scopes != nil {
+					content, err := s.contentStore().ReadFile(file.Path)
+					if err != nil {
+						log.Printf("%s %v", logprefix, err)
+						matches = nil
+					}
+					classified = scope.Classify(file.Path, content)
+				}
 				for _, match := range matches {
+					path := match.Path[len(s.UnpackedPath):]
+					match.Definition = slices.Contains(definitions[file.Docid], match.Line)
+					if onlyDefinitions && !match.Definition {
+						continue
+					}
+					if classified != nil {
+						if match.Ranges = rangesInScopes(classified, match.Line, match.Ranges, scopes); len(match.Ranges) == 0 {
+							continue
+						}
+					}
 					match.Ranking = ranking.PostRank(rankingopts, &match, &querystr)
 					match.PathRank = file.Ranking
 					//match.Path = match.Path[len(*unpackedPath):]
@@ -595,24 +1419,20 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 
 					// TODO: ideally, we’d get sourcebackendpb.Match structs from grep.File(), let’s do that after profiling the decoding performance
 
-					path := match.Path[len(s.UnpackedPath):]
-					connMu.Lock()
-					if err := stream.Send(&sourcebackendpb.SearchReply{
-						Type: sourcebackendpb.SearchReply_MATCH,
-						Match: &sourcebackendpb.Match{
-							Path:     path,
-							Line:     uint32(match.Line),
-							Package:  path[:strings.Index(path, "/")],
-							Ctxp2:    match.Ctxp2,
-							Ctxp1:    match.Ctxp1,
-							Context:  match.Context,
-							Ctxn1:    match.Ctxn1,
-							Ctxn2:    match.Ctxn2,
-							Pathrank: match.PathRank,
-							Ranking:  match.Ranking,
-						},
+					if err := send(&sourcebackendpb.Match{
+						Path:          path,
+						Line:          uint32(match.Line),
+						Package:       path[:strings.Index(path, "/")],
+						Suites:        suites[sourcePackage(path)],
+						ContextBefore: match.ContextBefore,
+						Context:       match.Context,
+						Ranges:        pbRanges(match.Ranges),
+						ContextAfter:  match.ContextAfter,
+						Pathrank:      match.PathRank,
+						Ranking:       match.Ranking,
+						ContentHash:   contentHash,
+						Duplicates:    duplicates[file.Docid],
 					}); err != nil {
-						connMu.Unlock()
 						log.Printf("%s %v\n", logprefix, err)
 						// Drain the work channel, but without doing any work.
 						// This effectively exits the worker goroutine(s)
@@ -621,7 +1441,6 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 						}
 						break
 					}
-					connMu.Unlock()
 				}
 
 				progress <- 1
@@ -636,6 +1455,71 @@ func (s *Server) Search(in *sourcebackendpb.SearchRequest, stream sourcebackendp
 
 	wg.Wait()
 
+	if aborted.Load() {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("%s Aborted after exceeding the time budget of %v.\n", logprefix, s.QueryTimeout)
+			return status.Errorf(codes.DeadlineExceeded, "query exceeded the time budget of %v", s.QueryTimeout)
+		}
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	if top != nil {
+		for _, m := range top.sorted() {
+			if err := sendMatch(stream, connMu, m, false); err != nil {
+				return fmt.Errorf("%s %v\n", logprefix, err)
+			}
+		}
+		if err := sendProgressUpdate(stream, connMu, len(files), len(files)); err != nil {
+			return fmt.Errorf("%s %v\n", logprefix, err)
+		}
+	}
+
 	log.Printf("%s Sent all results.\n", logprefix)
 	return nil
 }
+
+// occurrences returns m or, if all is true, one copy of m per range of m, each
+// containing only that range.
+func occurrences(m *sourcebackendpb.Match, all bool) []*sourcebackendpb.Match {
+	if !all || len(m.Ranges) < 2 {
+		return []*sourcebackendpb.Match{m}
+	}
+	result := make([]*sourcebackendpb.Match, len(m.Ranges))
+	for idx, r := range m.Ranges {
+		o := proto.Clone(m).(*sourcebackendpb.Match)
+		o.Ranges = []*sourcebackendpb.Range{r}
+		result[idx] = o
+	}
+	return result
+}
+
+// sendMatch sends m, or one match per occurrence if allOccurrences is true.
+func sendMatch(stream sourcebackendpb.SourceBackend_SearchServer, connMu *sync.Mutex, m *sourcebackendpb.Match, allOccurrences bool) error {
+	connMu.Lock()
+	defer connMu.Unlock()
+	for _, o := range occurrences(m, allOccurrences) {
+		if err := stream.Send(&sourcebackendpb.SearchReply{
+			Type:  sourcebackendpb.SearchReply_MATCH,
+			Match: o,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pbRanges(ranges []regexp.Range) []*sourcebackendpb.Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	pb := make([]*sourcebackendpb.Range, len(ranges))
+	for idx, r := range ranges {
+		pb[idx] = &sourcebackendpb.Range{
+			Start:     uint32(r.Start),
+			End:       uint32(r.End),
+			StartRune: uint32(r.StartRune),
+			EndRune:   uint32(r.EndRune),
+		}
+	}
+	return pb
+}
@@ -0,0 +1,454 @@
+package sourcebackend
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Debian/dcs/internal/index"
+	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/Debian/dcs/ranking"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func createIndex(t *testing.T, dir string, files map[string]string) *index.Index {
+	t.Helper()
+	w, err := index.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fn := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddFile(fn, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := index.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+func TestSwapKeepsInFlightGeneration(t *testing.T) {
+	tmp := t.TempDir()
+	old := createIndex(t, filepath.Join(tmp, "full.1"), map[string]string{"old/a.c": "int main"})
+	replacement := createIndex(t, filepath.Join(tmp, "full.2"), map[string]string{"new/b.c": "int main"})
+
+	s := &Server{Index: old}
+	inflight := s.acquire()
+	s.swap(replacement)
+
+	// The in-flight query can still use the old index:
+	if got, err := inflight.ix.Lookup(0); err != nil || got != "old/a.c" {
+		t.Fatalf("Lookup(0) on in-flight generation = %q, %v; want old/a.c", got, err)
+	}
+	if got, want := inflight.refs.Load(), int64(1); got != want {
+		t.Fatalf("in-flight generation has %d references, want %d", got, want)
+	}
+
+	// New queries use the replacement:
+	g := s.acquire()
+	if got, err := g.ix.Lookup(0); err != nil || got != "new/b.c" {
+		t.Fatalf("Lookup(0) on current generation = %q, %v; want new/b.c", got, err)
+	}
+	g.release()
+
+	inflight.release()
+	if got := inflight.refs.Load(); got != 0 {
+		t.Fatalf("old generation has %d references after its last query, want 0", got)
+	}
+	s.gen.Load().release() // close the replacement, too
+}
+
+func TestFilterBySuite(t *testing.T) {
+	files := []ranking.ResultPath{
+		{Path: "i3-wm_4.22-2/src/main.c"},
+		{Path: "i3-wm_4.24-1/src/main.c"},
+		{Path: "zsh_5.9-4/Src/main.c"},
+		{Path: "main_1.0"}, // not within a source package directory
+	}
+	suites := Suites{
+		"i3-wm_4.22-2": {"stable"},
+		"i3-wm_4.24-1": {"testing", "sid"},
+	}
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"suite=stable", []string{"i3-wm_4.22-2/src/main.c"}},
+		{"suite=stable&suite=sid", []string{"i3-wm_4.22-2/src/main.c", "i3-wm_4.24-1/src/main.c"}},
+		{"nsuite=sid", []string{"i3-wm_4.22-2/src/main.c", "zsh_5.9-4/Src/main.c", "main_1.0"}},
+		{"suite=testing&nsuite=sid", nil},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			u, err := url.Parse("/search?q=main&" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, file := range FilterByKeywords(u, files, suites) {
+				got = append(got, file.Path)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FilterByKeywords: unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	m := &sourcebackendpb.Match{
+		Path:    "i3-wm_4.24-1/src/main.c",
+		Line:    42,
+		Context: "free(a); free(b);",
+		Ranges: []*sourcebackendpb.Range{
+			{Start: 0, End: 4, StartRune: 0, EndRune: 4},
+			{Start: 9, End: 13, StartRune: 9, EndRune: 13},
+		},
+	}
+
+	if got := occurrences(m, false); len(got) != 1 || got[0] != m {
+		t.Fatalf("occurrences(m, false) = %v, want [m]", got)
+	}
+
+	got := occurrences(m, true)
+	if len(got) != len(m.Ranges) {
+		t.Fatalf("occurrences(m, true) returned %d matches, want %d", len(got), len(m.Ranges))
+	}
+	for idx, o := range got {
+		if o.Path != m.Path || o.Line != m.Line || o.Context != m.Context {
+			t.Errorf("occurrence %d = %v, want a copy of %v", idx, o, m)
+		}
+		if diff := cmp.Diff([]*sourcebackendpb.Range{m.Ranges[idx]}, o.Ranges, protocmp.Transform()); diff != "" {
+			t.Errorf("occurrence %d: unexpected ranges (-want +got):\n%s", idx, diff)
+		}
+	}
+	if len(m.Ranges) != 2 {
+		t.Errorf("occurrences modified m.Ranges: %v", m.Ranges)
+	}
+}
+
+type searchStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	replies []*sourcebackendpb.SearchReply
+}
+
+func (s *searchStream) Context() context.Context { return s.ctx }
+
+func (s *searchStream) Send(reply *sourcebackendpb.SearchReply) error {
+	s.replies = append(s.replies, reply)
+	return nil
+}
+
+// writeUnpacked writes files into the unpacked directory dir.
+func writeUnpacked(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newSearchServer returns a Server which serves files from an index and an
+// unpacked directory.
+func newSearchServer(t *testing.T, files map[string]string) *Server {
+	t.Helper()
+	tmp := t.TempDir()
+	unpacked := filepath.Join(tmp, "unpacked")
+	writeUnpacked(t, unpacked, files)
+	return &Server{
+		Index:        createIndex(t, filepath.Join(tmp, "full.1"), files),
+		UnpackedPath: unpacked + "/",
+	}
+}
+
+// search runs req on s and returns all replies.
+func search(t *testing.T, s *Server, req *sourcebackendpb.SearchRequest) []*sourcebackendpb.SearchReply {
+	t.Helper()
+	stream := &searchStream{ctx: context.Background()}
+	if err := s.Search(req, stream); err != nil {
+		t.Fatalf("Search(%q): %v", req.GetRewrittenUrl(), err)
+	}
+	return stream.replies
+}
+
+// searchLines runs req on s and returns the sorted “path:line” of all matches.
+func searchLines(t *testing.T, s *Server, req *sourcebackendpb.SearchRequest) []string {
+	t.Helper()
+	var lines []string
+	for _, reply := range search(t, s, req) {
+		if m := reply.GetMatch(); m != nil {
+			lines = append(lines, fmt.Sprintf("%s:%d", m.GetPath(), m.GetLine()))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestSearchTimeout(t *testing.T) {
+	s := newSearchServer(t, map[string]string{
+		"i3-wm_4.24-1/src/main.c": "int main() {\n\treturn 0;\n}\n",
+		"zsh_5.9-4/Src/main.c":    "int main(int argc, char **argv) {\n}\n",
+	})
+	req := &sourcebackendpb.SearchRequest{
+		Query:        "int main",
+		RewrittenUrl: "/search?q=int+main",
+	}
+
+	replies := search(t, s, req)
+	var matches int
+	for _, reply := range replies {
+		if reply.GetType() == sourcebackendpb.SearchReply_MATCH {
+			matches++
+		}
+	}
+	if matches != 2 {
+		t.Errorf("Search returned %d matches, want 2", matches)
+	}
+	last := replies[len(replies)-1].GetProgressUpdate()
+	if last.GetFilesProcessed() != 2 || last.GetFilesTotal() != 2 {
+		t.Errorf("last progress update = %v, want 2 of 2 files processed", last)
+	}
+
+	s.QueryTimeout = time.Nanosecond
+	stream := &searchStream{ctx: context.Background()}
+	err := s.Search(req, stream)
+	if got, want := status.Code(err), codes.DeadlineExceeded; got != want {
+		t.Fatalf("Search with exceeded time budget = %v (code %v), want code %v", err, got, want)
+	}
+	for _, reply := range stream.replies {
+		if p := reply.GetProgressUpdate(); p != nil && p.GetFilesProcessed() == p.GetFilesTotal() && p.GetFilesTotal() > 0 {
+			t.Errorf("aborted query sent a final progress update: %v", p)
+		}
+	}
+}
+
+func TestSearchAnd(t *testing.T) {
+	s := newSearchServer(t, map[string]string{
+		"a_1/a.c": "fd = open(path);\nread(fd);\nclose(fd);\n",
+		"b_1/b.c": "fd = open(path);\n" + strings.Repeat("read(fd);\n", 10) + "close(fd);\n",
+		"c_1/c.c": "fd = open(path);\n",
+	})
+	for _, tt := range []struct {
+		rewritten string
+		want      []string
+	}{
+		{`q=open\(&and=close\(`, []string{"a_1/a.c:1", "a_1/a.c:3", "b_1/b.c:1", "b_1/b.c:12"}},
+		{`q=open\(&and=close\(&near=2`, []string{"a_1/a.c:1", "a_1/a.c:3"}},
+		{`q=open\(&and=write\(`, nil},
+	} {
+		for _, positional := range []bool{false, true} {
+			s.UsePositionalIndex = positional
+			got := searchLines(t, s, &sourcebackendpb.SearchRequest{
+				Query:        `open\(`,
+				RewrittenUrl: "/search?" + tt.rewritten,
+			})
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Search(%q) with UsePositionalIndex=%v: unexpected diff (-want +got):\n%s", tt.rewritten, positional, diff)
+			}
+		}
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	s := newSearchServer(t, map[string]string{
+		"a_1/a.c":    "int main() {\n\t// cleanup();\n\tputs(\"cleanup();\");\n\tcleanup();\n}\n",
+		"a_1/README": "Call cleanup();\n",
+	})
+	for _, tt := range []struct {
+		rewritten string
+		want      []string
+	}{
+		{`q=cleanup`, []string{"a_1/README:1", "a_1/a.c:2", "a_1/a.c:3", "a_1/a.c:4"}},
+		{`q=cleanup&matchscope=code`, []string{"a_1/a.c:4"}},
+		{`q=cleanup&matchscope=comment&matchscope=string`, []string{"a_1/a.c:2", "a_1/a.c:3"}},
+		{`q=cleanup&nmatchscope=code`, []string{"a_1/a.c:2", "a_1/a.c:3"}},
+	} {
+		for _, positional := range []bool{false, true} {
+			s.UsePositionalIndex = positional
+			got := searchLines(t, s, &sourcebackendpb.SearchRequest{
+				Query:        "cleanup();",
+				RewrittenUrl: "/search?" + tt.rewritten,
+				Literal:      true,
+			})
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Search(%q) with UsePositionalIndex=%v: unexpected diff (-want +got):\n%s", tt.rewritten, positional, diff)
+			}
+		}
+	}
+}
+
+func TestSearchIdentifier(t *testing.T) {
+	s := newSearchServer(t, map[string]string{
+		"a_1/a.c":  "char *get_user_name(void);\n",
+		"b_1/b.py": "def getUserName():\n    return GET_USERNAME\n",
+		"c_1/c.go": "func GetUserNames() {}\nfunc getUserTitle() {}\n",
+	})
+	got := searchLines(t, s, &sourcebackendpb.SearchRequest{
+		Query:        "getUserName",
+		RewrittenUrl: "/search?q=getUserName&identifier=1",
+		Identifier:   true,
+	})
+	want := []string{"a_1/a.c:1", "b_1/b.py:1", "b_1/b.py:2", "c_1/c.go:1"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Search: unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchIgnoreWhitespace(t *testing.T) {
+	s := newSearchServer(t, map[string]string{
+		"a_1/a.c": "if (err != NULL) {\n\treturn err;\n}\n",
+		"b_1/b.c": "if  (err\t!= NULL)\n{\n\treturn err;\n}\n",
+		"c_1/c.c": "if (err!=NULL) {\n\treturn err;\n}\n",
+	})
+	for _, tt := range []struct {
+		lineBreaks bool
+		want       []string
+	}{
+		{lineBreaks: false, want: []string{"a_1/a.c:1"}},
+		{lineBreaks: true, want: []string{"a_1/a.c:1", "b_1/b.c:1"}},
+	} {
+		for _, positional := range []bool{false, true} {
+			s.UsePositionalIndex = positional
+			got := searchLines(t, s, &sourcebackendpb.SearchRequest{
+				Query:            "if (err != NULL) {",
+				RewrittenUrl:     "/search?q=if+%28err+%21%3D+NULL%29+%7B&ignore_whitespace=1",
+				IgnoreWhitespace: true,
+				IgnoreLineBreaks: tt.lineBreaks,
+			})
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Search(lineBreaks=%v) with UsePositionalIndex=%v: unexpected diff (-want +got):\n%s", tt.lineBreaks, positional, diff)
+			}
+		}
+	}
+}
+
+func TestSearchMaxResults(t *testing.T) {
+	s := newSearchServer(t, map[string]string{
+		// The query matches the path, which ranks this file first.
+		"a_1/cleanup.c": "void cleanup(void);\n",
+		"b_1/main.c":    "int main() {\n\tcleanup();\n}\n",
+		"c_1/util.c":    "cleanup();\ncleanup();\n",
+	})
+	req := &sourcebackendpb.SearchRequest{
+		Query:        "cleanup",
+		RewrittenUrl: "/search?q=cleanup&max_results=1",
+		Literal:      true,
+		MaxResults:   1,
+	}
+	for _, positional := range []bool{false, true} {
+		s.UsePositionalIndex = positional
+		if diff := cmp.Diff([]string{"a_1/cleanup.c:1"}, searchLines(t, s, req)); diff != "" {
+			t.Errorf("Search with UsePositionalIndex=%v: unexpected diff (-want +got):\n%s", positional, diff)
+		}
+		// dcs-web considers the query done after the final progress update,
+		// so it must follow the matches.
+		replies := search(t, s, req)
+		last := replies[len(replies)-1].GetProgressUpdate()
+		if last == nil || last.GetFilesProcessed() != last.GetFilesTotal() {
+			t.Errorf("Search with UsePositionalIndex=%v: last reply = %v, want the final progress update", positional, replies[len(replies)-1])
+		}
+	}
+}
+
+func TestSearchContextLongLines(t *testing.T) {
+	// Lines close to the maximum line length of the index, so that the
+	// context lines span far more bytes than a fixed read-ahead would cover.
+	long := func(c string) string { return strings.Repeat(c, 1900) }
+	s := newSearchServer(t, map[string]string{
+		"a_1/a.c": "needle();\n" + long("a") + "\n" + long("b") + "\n" + long("c") + "\n" + long("d") + "\n",
+	})
+	s.UsePositionalIndex = true
+	after := uint32(3)
+	var got [][]string
+	for _, reply := range search(t, s, &sourcebackendpb.SearchRequest{
+		Query:        "needle",
+		RewrittenUrl: "/search?q=needle",
+		ContextAfter: &after,
+	}) {
+		if m := reply.GetMatch(); m != nil {
+			got = append(got, m.GetContextAfter())
+		}
+	}
+	want := [][]string{{long("a"), long("b"), long("c")}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Search: ContextAfter: unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchDuplicates(t *testing.T) {
+	tmp := t.TempDir()
+	const shared = "func Shared() {\n\treturn\n}\n"
+	shards := []map[string]string{
+		{"aaa_1/x.go": shared},
+		{"bbb_1/x.go": shared, "ccc_1/y.go": "func Other() { Shared() }\n"},
+	}
+	unpacked := filepath.Join(tmp, "unpacked")
+	var dirs []string
+	for idx, files := range shards {
+		writeUnpacked(t, unpacked, files)
+		dir := filepath.Join(tmp, fmt.Sprintf("shard%d", idx))
+		createIndex(t, dir, files).Close()
+		dirs = append(dirs, dir)
+	}
+	merged := filepath.Join(tmp, "full.1")
+	if err := index.ConcatN(merged, dirs); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := index.Open(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Index: ix, UnpackedPath: unpacked + "/"}
+
+	for _, tt := range []struct {
+		rewritten string
+		want      []string
+	}{
+		{`q=Shared`, []string{"aaa_1/x.go:1 [bbb_1/x.go]", "ccc_1/y.go:1 []"}},
+		// Each copy is filtered on its own:
+		{`q=Shared&package=bbb`, []string{"bbb_1/x.go:1 []"}},
+		{`q=Shared&npackage=aaa`, []string{"bbb_1/x.go:1 []", "ccc_1/y.go:1 []"}},
+		{`q=Shared&npackage=aaa&npackage=bbb`, []string{"ccc_1/y.go:1 []"}},
+	} {
+		for _, positional := range []bool{false, true} {
+			s.UsePositionalIndex = positional
+			var got []string
+			for _, reply := range search(t, s, &sourcebackendpb.SearchRequest{
+				Query:        "Shared",
+				RewrittenUrl: "/search?" + tt.rewritten,
+			}) {
+				if m := reply.GetMatch(); m != nil {
+					got = append(got, fmt.Sprintf("%s:%d %v", m.GetPath(), m.GetLine(), m.GetDuplicates()))
+				}
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Search(%q) with UsePositionalIndex=%v: unexpected diff (-want +got):\n%s", tt.rewritten, positional, diff)
+			}
+		}
+	}
+}
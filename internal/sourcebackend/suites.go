@@ -0,0 +1,84 @@
+package sourcebackend
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/google/renameio/v2"
+)
+
+// Suites maps source packages (e.g. “i3-wm_4.13-1”) to the Debian suites
+// (e.g. “sid”, “stable”) which contain them.
+type Suites map[string][]string
+
+// Contains reports whether suite contains pkg.
+func (s Suites) Contains(pkg, suite string) bool {
+	return slices.Contains(s[pkg], suite)
+}
+
+// SuitesPath returns the path of the suites file belonging to the index at
+// indexPath. It is written by dcs-package-importer.
+func SuitesPath(indexPath string) string {
+	return filepath.Join(filepath.Dir(indexPath), "suites.json")
+}
+
+// ReadSuites reads the suites file at path. A missing file results in empty
+// Suites: packages are then not part of any suite.
+func ReadSuites(path string) (Suites, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var s Suites
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WriteSuites atomically replaces the suites file at path with s.
+func WriteSuites(path string, s Suites) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(path, b, 0644)
+}
+
+type loadedSuites struct {
+	modTime time.Time
+	suites  Suites
+}
+
+// suites returns the contents of the suites file next to the index, re-reading
+// it whenever dcs-package-importer replaced it.
+func (s *Server) suites() Suites {
+	if s.IndexPath == "" {
+		return nil
+	}
+	path := SuitesPath(s.IndexPath)
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if l := s.loadedSuites.Load(); l != nil && l.modTime.Equal(st.ModTime()) {
+		return l.suites
+	}
+	suites, err := ReadSuites(path)
+	if err != nil {
+		log.Printf("reading suites: %v", err)
+		if l := s.loadedSuites.Load(); l != nil {
+			return l.suites
+		}
+		return nil
+	}
+	s.loadedSuites.Store(&loadedSuites{modTime: st.ModTime(), suites: suites})
+	return suites
+}
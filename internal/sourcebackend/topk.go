@@ -0,0 +1,119 @@
+package sourcebackend
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+
+	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/Debian/dcs/ranking"
+)
+
+// maxPathBonus returns the maximum amount by which the workers of Search add
+// to the pre-ranking of a file (see ranking.ResultPath.Rank) when the query
+// matches its path or source package name. ranking.QueryStr.Match returns at
+// most 1.
+func maxPathBonus(opts ranking.RankingOpts) float32 {
+	var bonus float32
+	if opts.Pathmatch {
+		bonus += 1
+	}
+	if opts.Sourcepkgmatch {
+		bonus += 1
+	}
+	if opts.Weighted {
+		bonus += 0.1460 + 0.0008
+	}
+	return bonus
+}
+
+// topResults collects the best max matches of a query with max_results (see
+// sourcebackendpb.SearchRequest). Matches are ranked like dcs-web ranks them
+// (see finalRank). It is safe for concurrent use.
+type topResults struct {
+	max         int
+	maxPostRank float32 // see ranking.MaxPostRank
+
+	mu      sync.Mutex
+	matches matchHeap
+}
+
+func newTopResults(max int, maxPostRank float32) *topResults {
+	return &topResults{
+		max:         max,
+		maxPostRank: maxPostRank,
+		matches:     make(matchHeap, 0, max),
+	}
+}
+
+// finalRank returns the ranking which dcs-web computes for a match with
+// pathrank and post-ranking of a query with max_results.
+func finalRank(pathrank, postrank float32) float32 {
+	return ranking.FinalRank(pathrank, postrank, ranking.MaxResultsFirstPathRank)
+}
+
+// add adds m, unless there already are max better matches.
+func (t *topResults) add(m *sourcebackendpb.Match) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.matches) < t.max {
+		heap.Push(&t.matches, m)
+		return
+	}
+	if t.matches.better(m, t.matches[0]) {
+		t.matches[0] = m
+		heap.Fix(&t.matches, 0)
+	}
+}
+
+// complete reports whether matches with a pathrank of at most bound cannot
+// make it into the results anymore, regardless of their post-ranking.
+func (t *topResults) complete(bound float32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.matches) < t.max {
+		return false
+	}
+	worst := t.matches[0]
+	return finalRank(worst.Pathrank, worst.Ranking) > finalRank(bound, t.maxPostRank)
+}
+
+// sorted returns the matches, best first.
+func (t *topResults) sorted() []*sourcebackendpb.Match {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := append([]*sourcebackendpb.Match(nil), t.matches...)
+	sort.Slice(result, func(i, j int) bool { return t.matches.better(result[i], result[j]) })
+	return result
+}
+
+// matchHeap is a min-heap of matches: the worst match comes first.
+type matchHeap []*sourcebackendpb.Match
+
+func (h matchHeap) better(a, b *sourcebackendpb.Match) bool {
+	if fa, fb := finalRank(a.Pathrank, a.Ranking), finalRank(b.Pathrank, b.Ranking); fa != fb {
+		return fa > fb
+	}
+	// On a tie, use the path and line (like ranking.ResultPaths) so that the
+	// results do not depend on the order in which the workers finish.
+	if a.Path != b.Path {
+		return a.Path > b.Path
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	// Occurrences on the same line (all_occurrences).
+	return len(a.Ranges) > 0 && len(b.Ranges) > 0 && a.Ranges[0].Start < b.Ranges[0].Start
+}
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return h.better(h[j], h[i]) }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)        { *h = append(*h, x.(*sourcebackendpb.Match)) }
+
+func (h *matchHeap) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
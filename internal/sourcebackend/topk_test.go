@@ -0,0 +1,62 @@
+package sourcebackend
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Debian/dcs/internal/proto/sourcebackendpb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTopResults(t *testing.T) {
+	top := newTopResults(2, 1)
+	for _, m := range []*sourcebackendpb.Match{
+		{Path: "a_1/a.c", Line: 1, Pathrank: 1.5, Ranking: 1},
+		{Path: "b_1/b.c", Line: 1, Pathrank: 2, Ranking: 0.5},
+		{Path: "c_1/c.c", Line: 1, Pathrank: 1, Ranking: 1},
+	} {
+		top.add(m)
+	}
+	// a_1/a.c could still be beaten by a file with a pathrank of 1.9.
+	if top.complete(1.9) {
+		t.Errorf("complete(1.9) = true, want false")
+	}
+	top.add(&sourcebackendpb.Match{Path: "b_1/b.c", Line: 2, Pathrank: 2, Ranking: 0.75})
+	if !top.complete(1.9) {
+		t.Errorf("complete(1.9) = false, want true")
+	}
+	// Matches with the same pathrank could still have a better ranking.
+	if top.complete(2) {
+		t.Errorf("complete(2) = true, want false")
+	}
+	var got []string
+	for _, m := range top.sorted() {
+		got = append(got, fmt.Sprintf("%s:%d", m.GetPath(), m.GetLine()))
+	}
+	if diff := cmp.Diff([]string{"b_1/b.c:2", "b_1/b.c:1"}, got); diff != "" {
+		t.Errorf("sorted: unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestTopResultsClosePathranks(t *testing.T) {
+	// With the definition boost, post-rankings range up to 2.
+	top := newTopResults(1, 2)
+	top.add(&sourcebackendpb.Match{Path: "a_1/a.c", Line: 1, Pathrank: 2, Ranking: 0.5})
+	// A slightly lower pathrank is outweighed by a better post-ranking, like
+	// in the final ranking computed by dcs-web.
+	top.add(&sourcebackendpb.Match{Path: "b_1/b.c", Line: 1, Pathrank: 1.98, Ranking: 1})
+	// A definition in a file with a pathrank of 1.97 would still be better.
+	if top.complete(1.97) {
+		t.Errorf("complete(1.97) = true, want false")
+	}
+	if !top.complete(1.85) {
+		t.Errorf("complete(1.85) = false, want true")
+	}
+	var got []string
+	for _, m := range top.sorted() {
+		got = append(got, fmt.Sprintf("%s:%d", m.GetPath(), m.GetLine()))
+	}
+	if diff := cmp.Diff([]string{"b_1/b.c:1"}, got); diff != "" {
+		t.Errorf("sorted: unexpected diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,272 @@
+// Package symbols finds the definitions of functions, types, macros and
+// constants in source code.
+//
+// Like ctags, it uses lightweight, line-based parsers (mostly regular
+// expressions) per language instead of a full parser, trading precision for
+// speed and robustness against code which does not compile.
+package symbols
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Kind is the kind of a definition. The values are used in index files.
+type Kind byte
+
+const (
+	Function Kind = 'f'
+	Type     Kind = 't'
+	Macro    Kind = 'm'
+	Constant Kind = 'c'
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Function:
+		return "function"
+	case Type:
+		return "type"
+	case Macro:
+		return "macro"
+	case Constant:
+		return "constant"
+	}
+	return "unknown"
+}
+
+// A Symbol is the definition of Name in line Line (1-based).
+type Symbol struct {
+	Name string
+	Kind Kind
+	Line int
+}
+
+type rule struct {
+	re   *regexp.Regexp // submatch 1 is the name
+	kind Kind
+}
+
+type language struct {
+	rules []rule
+
+	// blocks maps lines which open a block of grouped declarations (e.g. Go’s
+	// “const (”) to the kind of the declarations, whose name is the first word
+	// of each line until the block is closed by a line “)”.
+	blocks map[string]Kind
+
+	// keywords are never returned as names, e.g. “if” in “if (x) {”.
+	keywords map[string]bool
+}
+
+func rules(kind Kind, exprs ...string) []rule {
+	r := make([]rule, len(exprs))
+	for i, expr := range exprs {
+		r[i] = rule{re: regexp.MustCompile(expr), kind: kind}
+	}
+	return r
+}
+
+func concat(r ...[]rule) []rule {
+	var result []rule
+	for _, rr := range r {
+		result = append(result, rr...)
+	}
+	return result
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	c = &language{
+		rules: concat(
+			rules(Macro, `^\s*#\s*define\s+([A-Za-z_]\w*)`),
+			rules(Type,
+				`^\s*(?:typedef\s+)?(?:struct|union|enum|class)\s+([A-Za-z_]\w*)\s*(?:\{|:[^:]|$)`,
+				`^\s*typedef\b.*\(\s*\*\s*([A-Za-z_]\w*)\s*\)\s*\(`,
+				`^\s*typedef\b[^(]*\b([A-Za-z_]\w*)\s*;`,
+				`^\s*}\s*([A-Za-z_]\w*)\s*;`), // typedef struct { … } name;
+			// Function definitions start in the first column and do not end in
+			// a semicolon (which would make them a declaration). The return
+			// type may be on the previous line (GNU style).
+			rules(Function, `^(?:[A-Za-z_][\w\s\*&<>,]*?[\s\*&])?(?:[A-Za-z_]\w*::)*(~?[A-Za-z_]\w*)\s*\([^;]*$`),
+		),
+		keywords: set("if", "else", "for", "while", "do", "switch", "case", "return", "sizeof", "typedef", "struct", "union", "enum", "class", "static", "extern", "define", "defined", "__attribute__"),
+	}
+
+	golang = &language{
+		rules: concat(
+			rules(Function, `^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+			rules(Type, `^type\s+([A-Za-z_]\w*)`),
+			rules(Constant, `^const\s+([A-Za-z_]\w*)`),
+		),
+		blocks: map[string]Kind{
+			"const (": Constant,
+			"type (":  Type,
+		},
+	}
+
+	python = &language{
+		rules: concat(
+			rules(Function, `^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+			rules(Type, `^\s*class\s+([A-Za-z_]\w*)`),
+			rules(Constant, `^([A-Z][A-Z0-9_]*)\s*(?::[^=]*)?=[^=]`),
+		),
+	}
+
+	shell = &language{
+		rules: rules(Function,
+			`^\s*function\s+([A-Za-z_][\w.:-]*)`,
+			`^\s*([A-Za-z_][\w.:-]*)\s*\(\)`),
+	}
+
+	perl = &language{
+		rules: concat(
+			rules(Function, `^\s*sub\s+([\w:]+)`),
+			rules(Type, `^\s*package\s+([\w:]+)`),
+			rules(Constant, `^\s*use\s+constant\s+([A-Za-z_]\w*)`),
+		),
+	}
+
+	java = &language{
+		rules: concat(
+			rules(Type, `^\s*(?:(?:public|protected|private|abstract|static|final|sealed|strictfp)\s+)*(?:class|interface|enum|record|@interface)\s+([A-Za-z_]\w*)`),
+			rules(Constant, `^\s*(?:(?:public|protected|private)\s+)?static\s+final\s+[\w<>\[\],.? ]+\s+([A-Z_][A-Z0-9_]*)\s*=`),
+			rules(Function, `^\s+(?:(?:public|protected|private|static|final|abstract|synchronized|native|default)\s+)+(?:<[^>]*>\s+)?[\w<>\[\],.?]+\s+([A-Za-z_]\w*)\s*\(`),
+		),
+		keywords: set("if", "for", "while", "switch", "return", "new", "catch", "synchronized"),
+	}
+
+	javascript = &language{
+		rules: concat(
+			rules(Function,
+				`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`,
+				`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s*)?(?:function\b|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`),
+			rules(Type, `^\s*(?:export\s+)?(?:default\s+)?class\s+([A-Za-z_$][\w$]*)`),
+			rules(Constant, `^\s*(?:export\s+)?const\s+([A-Z_$][A-Z0-9_$]*)\s*=`),
+		),
+	}
+
+	ruby = &language{
+		rules: concat(
+			rules(Function, `^\s*def\s+(?:self\.)?([A-Za-z_]\w*[?!=]?)`),
+			rules(Type, `^\s*(?:class|module)\s+(?:\w+::)*([A-Z]\w*)`),
+			rules(Constant, `^\s*([A-Z][A-Z0-9_]*)\s*=[^=]`),
+		),
+	}
+
+	rust = &language{
+		rules: concat(
+			rules(Function, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:(?:const|async|unsafe|extern\s+"[^"]*")\s+)*fn\s+([A-Za-z_]\w*)`),
+			rules(Type, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|union)\s+([A-Za-z_]\w*)`),
+			rules(Constant, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+([A-Z_][A-Z0-9_]*)\s*:`),
+			rules(Macro, `^\s*macro_rules!\s*([A-Za-z_]\w*)`),
+		),
+	}
+)
+
+var languages = map[string]*language{
+	".c":    c,
+	".h":    c,
+	".cc":   c,
+	".cpp":  c,
+	".cxx":  c,
+	".hh":   c,
+	".hpp":  c,
+	".hxx":  c,
+	".m":    c,
+	".mm":   c,
+	".go":   golang,
+	".py":   python,
+	".sh":   shell,
+	".bash": shell,
+	".zsh":  shell,
+	".pl":   perl,
+	".pm":   perl,
+	".java": java,
+	".js":   javascript,
+	".mjs":  javascript,
+	".ts":   javascript,
+	".rb":   ruby,
+	".rs":   rust,
+}
+
+// Supported reports whether Extract can find definitions in the file called
+// filename, based on its extension.
+func Supported(filename string) bool {
+	return languages[strings.ToLower(path.Ext(filename))] != nil
+}
+
+// maxLineLen is the length above which lines are skipped: they are unlikely to
+// contain definitions (e.g. minified JavaScript) and slow to match.
+const maxLineLen = 500
+
+// Extract returns the definitions in content, which is the content of the
+// file called filename, in the order in which they appear.
+func Extract(filename string, content []byte) []Symbol {
+	lang := languages[strings.ToLower(path.Ext(filename))]
+	if lang == nil {
+		return nil
+	}
+	var result []Symbol
+	var block Kind
+	for lineno := 1; len(content) > 0; lineno++ {
+		line := content
+		if idx := bytes.IndexByte(content, '\n'); idx > -1 {
+			line, content = content[:idx], content[idx+1:]
+		} else {
+			content = nil
+		}
+		if len(line) > maxLineLen {
+			continue
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+
+		if block != 0 {
+			if bytes.Equal(bytes.TrimSpace(line), []byte(")")) {
+				block = 0
+				continue
+			}
+			if fields := bytes.Fields(line); len(fields) > 0 && isIdentifier(fields[0]) {
+				result = append(result, Symbol{Name: string(fields[0]), Kind: block, Line: lineno})
+			}
+			continue
+		}
+		if kind, ok := lang.blocks[string(bytes.TrimSpace(line))]; ok {
+			block = kind
+			continue
+		}
+
+		for _, r := range lang.rules {
+			m := r.re.FindSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := string(m[1])
+			if lang.keywords[name] {
+				continue
+			}
+			result = append(result, Symbol{Name: name, Kind: r.kind, Line: lineno})
+			break
+		}
+	}
+	return result
+}
+
+func isIdentifier(b []byte) bool {
+	for i, r := range string(b) {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') &&
+			(i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return len(b) > 0
+}
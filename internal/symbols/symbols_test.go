@@ -0,0 +1,137 @@
+package symbols
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExtract(t *testing.T) {
+	for _, tt := range []struct {
+		filename string
+		content  string
+		want     []Symbol
+	}{
+		{
+			filename: "main.c",
+			content: `#include <stdio.h>
+#define MAX_WINDOWS 23
+
+typedef struct {
+	int x;
+} point_t;
+
+struct con {
+	int id;
+};
+
+typedef void (*callback_t)(int);
+
+static int helper(void);
+
+static int
+helper(void) {
+	if (foo(1)) {
+		return 1;
+	}
+	return 0;
+}
+
+int main(int argc, char *argv[]) {
+	return helper();
+}
+`,
+			want: []Symbol{
+				{Name: "MAX_WINDOWS", Kind: Macro, Line: 2},
+				{Name: "point_t", Kind: Type, Line: 6},
+				{Name: "con", Kind: Type, Line: 8},
+				{Name: "callback_t", Kind: Type, Line: 12},
+				{Name: "helper", Kind: Function, Line: 17},
+				{Name: "main", Kind: Function, Line: 24},
+			},
+		},
+
+		{
+			filename: "x.go",
+			content: `package x
+
+const (
+	Answer = 42
+	// a comment
+	other
+)
+
+type Server struct{}
+
+func (s *Server) Search() {}
+
+func New() *Server { return nil }
+`,
+			want: []Symbol{
+				{Name: "Answer", Kind: Constant, Line: 4},
+				{Name: "other", Kind: Constant, Line: 6},
+				{Name: "Server", Kind: Type, Line: 9},
+				{Name: "Search", Kind: Function, Line: 11},
+				{Name: "New", Kind: Function, Line: 13},
+			},
+		},
+
+		{
+			filename: "tool.py",
+			content: `MAX_DEPTH = 3
+
+class Walker(object):
+    async def walk(self):
+        if MAX_DEPTH == 3:
+            pass
+`,
+			want: []Symbol{
+				{Name: "MAX_DEPTH", Kind: Constant, Line: 1},
+				{Name: "Walker", Kind: Type, Line: 3},
+				{Name: "walk", Kind: Function, Line: 4},
+			},
+		},
+
+		{
+			filename: "run.sh",
+			content:  "usage() {\n\techo usage\n}\nfunction cleanup {\n\trm -f x\n}\n",
+			want: []Symbol{
+				{Name: "usage", Kind: Function, Line: 1},
+				{Name: "cleanup", Kind: Function, Line: 4},
+			},
+		},
+
+		{
+			filename: "Foo.java",
+			content: `public class Foo {
+    public static final int LIMIT = 10;
+
+    private static String render(int n) {
+        if (n > LIMIT) {
+            return "";
+        }
+        return "x";
+    }
+}
+`,
+			want: []Symbol{
+				{Name: "Foo", Kind: Type, Line: 1},
+				{Name: "LIMIT", Kind: Constant, Line: 2},
+				{Name: "render", Kind: Function, Line: 4},
+			},
+		},
+
+		{
+			filename: "README",
+			content:  "def foo():\n",
+			want:     nil,
+		},
+	} {
+		t.Run(tt.filename, func(t *testing.T) {
+			got := Extract(tt.filename, []byte(tt.content))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Extract: unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package turbopfor
+
+// The pure Go implementation is always compiled, so that the tests can compare
+// it against the cgo implementation.
+var (
+	GoP4nenc32     = func(input []uint32) []byte { return encodeGo(p4nenc32, input) }
+	GoP4nenc256v32 = func(input []uint32) []byte { return encodeGo(p4nenc256v32, input) }
+	GoP4nd1enc32   = func(input []uint32) []byte { return encodeGo(p4nd1enc32, input) }
+	GoP4ndec32     = p4ndec32
+	GoP4ndec256v32 = p4ndec256v32
+	GoP4nd1dec32   = p4nd1dec32
+)
+
+func encodeGo(enc func([]uint32, []byte) int, input []uint32) []byte {
+	buffer := make([]byte, EncodingSize(len(input)))
+	return buffer[:enc(input, buffer)]
+}
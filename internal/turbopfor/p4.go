@@ -0,0 +1,469 @@
+package turbopfor
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// This file contains a Go implementation of the subset of TurboPFor which dcs
+// uses, which is used instead of the C library when building without cgo or
+// with the purego build tag (see turbopfor_purego.go). It produces and
+// consumes the same byte format as the C library:
+//
+// Each block starts with a header byte, whose upper two bits select the
+// encoding and whose lower six bits contain the bit width b of the values:
+//
+//	00: b-bit values are bitpacked, there are no exceptions.
+//	10: a second header byte contains the bit width bx of the exceptions. An
+//	    exception bitmap follows, then the bitpacked exceptions (high bits),
+//	    then the bitpacked values (low bits).
+//	01: a second header byte contains the number of exceptions. The bitpacked
+//	    values (low bits) follow, then the variable byte encoded exceptions,
+//	    then one byte per exception with its position.
+//	11: all values are equal, the value is stored in (b+7)/8 bytes.
+//
+// The 256v32 variants use a vertical bit packing layout (8 lanes of 32 bit)
+// for the low bits, which the C library produces with AVX2 instructions.
+
+const (
+	vbOfs1 = 177
+	vbOfs2 = 16561
+	vbOfs3 = 540849
+	vbBa2  = 241
+	vbBa3  = 249
+)
+
+func bsr32(x uint32) uint { return uint(bits.Len32(x)) }
+
+func pad8(nbits int) int { return (nbits + 7) / 8 }
+
+func vbSize(x uint32) int {
+	switch {
+	case x < vbOfs1:
+		return 1
+	case x < vbOfs2:
+		return 2
+	case x < vbOfs3:
+		return 3
+	default:
+		return 1 + pad8(int(bsr32(x)))
+	}
+}
+
+func vbPut(out []byte, x uint32) int {
+	switch {
+	case x < vbOfs1:
+		out[0] = byte(x)
+		return 1
+	case x < vbOfs2:
+		x -= vbOfs1
+		out[0] = byte(vbOfs1 + (x >> 8))
+		out[1] = byte(x)
+		return 2
+	case x < vbOfs3:
+		x -= vbOfs2
+		out[0] = byte(vbBa2 + (x >> 16))
+		binary.LittleEndian.PutUint16(out[1:], uint16(x))
+		return 3
+	default:
+		b := pad8(int(bsr32(x)))
+		out[0] = byte(vbBa3 + b - 3)
+		for i := 0; i < b; i++ {
+			out[1+i] = byte(x >> (8 * i))
+		}
+		return 1 + b
+	}
+}
+
+func vbGet(in []byte) (uint32, int) {
+	v := uint32(in[0])
+	switch {
+	case v < vbOfs1:
+		return v, 1
+	case v < vbBa2:
+		return (v-vbOfs1)<<8 + uint32(in[1]) + vbOfs1, 2
+	case v < vbBa3:
+		return (v-vbBa2)<<16 + uint32(binary.LittleEndian.Uint16(in[1:])) + vbOfs2, 3
+	default:
+		b := int(v) - vbBa3 + 3
+		var x uint32
+		for i := 0; i < b; i++ {
+			x |= uint32(in[1+i]) << (8 * i)
+		}
+		return x, 1 + b
+	}
+}
+
+// bitpack32 packs the lowest b bits of each value into a contiguous, least
+// significant bit first stream and returns the number of bytes written.
+func bitpack32(in []uint32, b uint, out []byte) int {
+	size := pad8(len(in) * int(b))
+	if b == 0 {
+		return 0
+	}
+	var acc uint64
+	var nacc uint
+	o := 0
+	for _, v := range in {
+		acc |= uint64(v&mask(b)) << nacc
+		nacc += b
+		for nacc >= 8 {
+			out[o] = byte(acc)
+			o++
+			acc >>= 8
+			nacc -= 8
+		}
+	}
+	if nacc > 0 {
+		out[o] = byte(acc)
+	}
+	return size
+}
+
+func bitunpack32(in []byte, b uint, out []uint32) int {
+	size := pad8(len(out) * int(b))
+	if b == 0 {
+		clear(out)
+		return 0
+	}
+	var acc uint64
+	var nacc uint
+	i := 0
+	for idx := range out {
+		for nacc < b {
+			acc |= uint64(in[i]) << nacc
+			i++
+			nacc += 8
+		}
+		out[idx] = uint32(acc) & mask(b)
+		acc >>= b
+		nacc -= b
+	}
+	return size
+}
+
+// bitpack256v32 packs 256 values into 8 lanes of 32 bit: value i is stored in
+// lane i%8, and each lane is a least significant bit first stream of 32 bit
+// words, interleaved with the other lanes.
+func bitpack256v32(in []uint32, b uint, out []byte) int {
+	if b == 0 {
+		return 0
+	}
+	for lane := 0; lane < 8; lane++ {
+		var acc uint64
+		var nacc uint
+		word := 0
+		for k := 0; k < 32; k++ {
+			acc |= uint64(in[k*8+lane]&mask(b)) << nacc
+			nacc += b
+			if nacc >= 32 {
+				binary.LittleEndian.PutUint32(out[(word*8+lane)*4:], uint32(acc))
+				word++
+				acc >>= 32
+				nacc -= 32
+			}
+		}
+	}
+	return 32 * int(b)
+}
+
+func bitunpack256v32(in []byte, b uint, out []uint32) int {
+	if b == 0 {
+		clear(out[:256])
+		return 0
+	}
+	for lane := 0; lane < 8; lane++ {
+		var acc uint64
+		var nacc uint
+		word := 0
+		for k := 0; k < 32; k++ {
+			if nacc < b {
+				acc |= uint64(binary.LittleEndian.Uint32(in[(word*8+lane)*4:])) << nacc
+				word++
+				nacc += 32
+			}
+			out[k*8+lane] = uint32(acc) & mask(b)
+			acc >>= b
+			nacc -= b
+		}
+	}
+	return 32 * int(b)
+}
+
+func mask(b uint) uint32 {
+	if b >= 32 {
+		return 0xffffffff
+	}
+	return 1<<b - 1
+}
+
+const (
+	modePlain = iota
+	modeBitmap
+	modeVbyte
+	modeConst
+)
+
+// p4bits determines the bit width and the exception encoding which result in
+// the smallest output for in.
+func p4bits(in []uint32) (b, bx uint, mode int) {
+	var cnt [33]int
+	var or uint32
+	allEqual := true
+	for _, v := range in {
+		or |= v
+		cnt[bsr32(v)]++
+		if v != in[0] {
+			allEqual = false
+		}
+	}
+	b = bsr32(or)
+	if allEqual && b > 0 {
+		return b, 0, modeConst
+	}
+	n := len(in)
+	best := 1 + pad8(n*int(b))
+	mode = modePlain
+	bestB := b
+	var bestBx uint
+	// x is the number of values which do not fit into i bits.
+	x := 0
+	for i := int(b) - 1; i >= 0; i-- {
+		x += cnt[i+1]
+		bitmap := 2 + pad8(n) + pad8(x*int(b-uint(i))) + pad8(n*i)
+		if bitmap < best {
+			best, mode, bestB, bestBx = bitmap, modeBitmap, uint(i), b-uint(i)
+		}
+		if x > 255 {
+			continue
+		}
+		vb := 2 + pad8(n*i) + x
+		for _, v := range in {
+			if bsr32(v) > uint(i) {
+				vb += vbSize(v >> uint(i))
+			}
+		}
+		if vb < best {
+			best, mode, bestB, bestBx = vb, modeVbyte, uint(i), 0
+		}
+	}
+	return bestB, bestBx, mode
+}
+
+func p4enc(in []uint32, out []byte, pack func([]uint32, uint, []byte) int) int {
+	if len(in) == 0 {
+		return 0
+	}
+	b, bx, mode := p4bits(in)
+	switch mode {
+	case modeConst:
+		out[0] = 0xc0 | byte(b)
+		v := in[0]
+		nb := pad8(int(b))
+		for i := 0; i < nb; i++ {
+			out[1+i] = byte(v >> (8 * i))
+		}
+		return 1 + nb
+
+	case modePlain:
+		out[0] = byte(b)
+		return 1 + pack(in, b, out[1:])
+
+	case modeBitmap:
+		out[0] = 0x80 | byte(b)
+		out[1] = byte(bx)
+		o := 2
+		xmap := out[o : o+pad8(len(in))]
+		clear(xmap)
+		o += len(xmap)
+		var ex []uint32
+		for i, v := range in {
+			if v>>b != 0 {
+				xmap[i/8] |= 1 << (i % 8)
+				ex = append(ex, v>>b)
+			}
+		}
+		o += bitpack32(ex, bx, out[o:])
+		o += pack(in, b, out[o:])
+		return o
+
+	default: // modeVbyte
+		out[0] = 0x40 | byte(b)
+		var ex []uint32
+		var pos []byte
+		for i, v := range in {
+			if v>>b != 0 {
+				ex = append(ex, v>>b)
+				pos = append(pos, byte(i))
+			}
+		}
+		out[1] = byte(len(ex))
+		o := 2
+		o += pack(in, b, out[o:])
+		for _, v := range ex {
+			o += vbPut(out[o:], v)
+		}
+		o += copy(out[o:], pos)
+		return o
+	}
+}
+
+func p4dec(in []byte, out []uint32, unpack func([]byte, uint, []uint32) int) int {
+	if len(out) == 0 {
+		return 0
+	}
+	h := in[0]
+	b := uint(h & 0x3f)
+	switch h >> 6 {
+	case 3: // constant
+		nb := pad8(int(b))
+		var v uint32
+		for i := 0; i < nb; i++ {
+			v |= uint32(in[1+i]) << (8 * i)
+		}
+		v &= mask(b)
+		for i := range out {
+			out[i] = v
+		}
+		return 1 + nb
+
+	case 0: // plain
+		return 1 + unpack(in[1:], b, out)
+
+	case 2: // bitmap exceptions
+		bx := uint(in[1])
+		o := 2
+		xmap := in[o : o+pad8(len(out))]
+		o += len(xmap)
+		var xn int
+		for _, m := range xmap {
+			xn += bits.OnesCount8(m)
+		}
+		ex := make([]uint32, xn)
+		o += bitunpack32(in[o:], bx, ex)
+		o += unpack(in[o:], b, out)
+		k := 0
+		for i := range out {
+			if xmap[i/8]&(1<<(i%8)) != 0 {
+				out[i] |= ex[k] << b
+				k++
+			}
+		}
+		return o
+
+	default: // variable byte exceptions
+		xn := int(in[1])
+		o := 2
+		o += unpack(in[o:], b, out)
+		ex := make([]uint32, xn)
+		for k := range ex {
+			v, n := vbGet(in[o:])
+			ex[k] = v
+			o += n
+		}
+		for k := range ex {
+			out[in[o+k]] |= ex[k] << b
+		}
+		return o + xn
+	}
+}
+
+func p4enc32(in []uint32, out []byte) int {
+	return p4enc(in, out, bitpack32)
+}
+
+func p4dec32(in []byte, out []uint32) int {
+	return p4dec(in, out, bitunpack32)
+}
+
+func p4enc256v32(in []uint32, out []byte) int {
+	if len(in) != 256 {
+		return p4enc32(in, out)
+	}
+	return p4enc(in, out, bitpack256v32)
+}
+
+func p4dec256v32(in []byte, out []uint32) int {
+	if len(out) != 256 {
+		return p4dec32(in, out)
+	}
+	return p4dec(in, out, bitunpack256v32)
+}
+
+func p4nenc32(in []uint32, out []byte) int {
+	o := 0
+	for len(in) > 0 {
+		n := min(len(in), 128)
+		o += p4enc32(in[:n], out[o:])
+		in = in[n:]
+	}
+	return o
+}
+
+func p4ndec32(in []byte, out []uint32) int {
+	o := 0
+	for len(out) > 0 {
+		n := min(len(out), 128)
+		o += p4dec32(in[o:], out[:n])
+		out = out[n:]
+	}
+	return o
+}
+
+func p4nenc256v32(in []uint32, out []byte) int {
+	o := 0
+	for len(in) >= 256 {
+		o += p4enc256v32(in[:256], out[o:])
+		in = in[256:]
+	}
+	return o + p4enc32(in, out[o:])
+}
+
+func p4ndec256v32(in []byte, out []uint32) int {
+	o := 0
+	for len(out) >= 256 {
+		o += p4dec256v32(in[o:], out[:256])
+		out = out[256:]
+	}
+	return o + p4dec32(in[o:], out)
+}
+
+// p4nzenc32 encodes the zigzag-encoded deltas of in.
+func p4nzenc32(in []uint32, out []byte) int {
+	tmp := make([]uint32, len(in))
+	var prev uint32
+	for i, v := range in {
+		d := int32(v - prev)
+		tmp[i] = uint32(d<<1) ^ uint32(d>>31)
+		prev = v
+	}
+	return p4nenc32(tmp, out)
+}
+
+// p4nd1enc32 encodes a strictly increasing sequence: the first value is stored
+// variable byte encoded, followed by the deltas minus one of the remaining
+// values.
+func p4nd1enc32(in []uint32, out []byte) int {
+	if len(in) == 0 {
+		return 0
+	}
+	o := vbPut(out, in[0])
+	tmp := make([]uint32, len(in)-1)
+	for i := 1; i < len(in); i++ {
+		tmp[i-1] = in[i] - in[i-1] - 1
+	}
+	return o + p4nenc32(tmp, out[o:])
+}
+
+func p4nd1dec32(in []byte, out []uint32) int {
+	if len(out) == 0 {
+		return 0
+	}
+	start, o := vbGet(in)
+	out[0] = start
+	o += p4ndec32(in[o:], out[1:])
+	for i := 1; i < len(out); i++ {
+		out[i] += out[i-1] + 1
+	}
+	return o
+}
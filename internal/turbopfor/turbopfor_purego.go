@@ -0,0 +1,69 @@
+//go:build purego || !cgo
+
+package turbopfor
+
+// See turbopfor.go for the cgo version of this file, which this file must
+// match in behavior (see TestPureGo).
+
+// Corresponding to p4nbound256v32, see turbopfor.go.
+func EncodingSize(n int) int {
+	return ((n + 255) / 256) + (n+32)*4
+}
+
+// Corresponding to p4nbound32, see turbopfor.go.
+func DecodingSize(n int) int {
+	return ((n + 127) / 128) + (n+32)*4
+}
+
+func P4nenc32(input []uint32) []byte {
+	buffer := make([]byte, EncodingSize(len(input)))
+	return buffer[:p4nenc32(input, buffer)]
+}
+
+func P4enc32(input []uint32) []byte {
+	buffer := make([]byte, EncodingSize(len(input)))
+	return buffer[:p4enc32(input, buffer)]
+}
+
+func P4enc256v32(input []uint32, output []byte) int {
+	return p4enc256v32(input[:min(len(input), 256)], output)
+}
+
+func P4nenc256v32Buf(buffer []byte, input []uint32) []byte {
+	return buffer[:p4nenc256v32(input, buffer)]
+}
+
+func P4nenc256v32(input []uint32) []byte {
+	buffer := make([]byte, EncodingSize(len(input)))
+	return P4nenc256v32Buf(buffer, input)
+}
+
+func P4nzenc32(input []uint32) []byte {
+	buffer := make([]byte, EncodingSize(len(input)))
+	return buffer[:p4nzenc32(input, buffer)]
+}
+
+func P4nd1enc32(input []uint32) []byte {
+	buffer := make([]byte, EncodingSize(len(input)))
+	return buffer[:p4nd1enc32(input, buffer)]
+}
+
+func P4dec32(input []byte, output []uint32) (read int) {
+	return p4dec32(input, output)
+}
+
+func P4ndec32(input []byte, output []uint32) (read int) {
+	return p4ndec32(input, output)
+}
+
+func P4dec256v32(input []byte, output []uint32) (read int) {
+	return p4dec256v32(input, output)
+}
+
+func P4ndec256v32(input []byte, output []uint32) (read int) {
+	return p4ndec256v32(input, output)
+}
+
+func P4nd1dec32(input []byte, output []uint32) (read int) {
+	return p4nd1dec32(input, output)
+}
@@ -168,3 +168,62 @@ func TestChunkedDecode(t *testing.T) {
 		t.Fatalf("got %x\nwant %x", got, input)
 	}
 }
+
+// TestPureGo verifies that the pure Go implementation (turbopfor_purego.go)
+// produces the same encoding as the C library and decodes it. When built
+// without cgo, this test only verifies that encoding and decoding round-trip.
+func TestPureGo(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, generator := range []struct {
+		name string
+		gen  func() uint32
+	}{
+		{"constant", func() uint32 { return 42 }},
+		{"small", func() uint32 { return uint32(rnd.Intn(16)) }},
+		{"exceptions", func() uint32 {
+			if rnd.Intn(20) == 0 {
+				return uint32(rnd.Intn(1 << 20))
+			}
+			return uint32(rnd.Intn(64))
+		}},
+		{"random", rnd.Uint32},
+	} {
+		name, gen := generator.name, generator.gen
+		for _, n := range []int{1, 31, 32, 127, 128, 129, 255, 256, 257, 600} {
+			input := make([]uint32, n, n+32)
+			for idx := range input {
+				input[idx] = gen()
+			}
+			sorted := make([]uint32, n, n+32)
+			var last uint32
+			for idx := range sorted {
+				last += 1 + input[idx]%1000
+				sorted[idx] = last
+			}
+
+			for _, enc := range []struct {
+				name   string
+				input  []uint32
+				cgo    func([]uint32) []byte
+				purego func([]uint32) []byte
+				dec    func([]byte, []uint32) int
+			}{
+				{"P4nenc32", input, turbopfor.P4nenc32, turbopfor.GoP4nenc32, turbopfor.GoP4ndec32},
+				{"P4nenc256v32", input, turbopfor.P4nenc256v32, turbopfor.GoP4nenc256v32, turbopfor.GoP4ndec256v32},
+				{"P4nd1enc32", sorted, turbopfor.P4nd1enc32, turbopfor.GoP4nd1enc32, turbopfor.GoP4nd1dec32},
+			} {
+				want := enc.cgo(enc.input)
+				if got := enc.purego(enc.input); !bytes.Equal(got, want) {
+					t.Fatalf("%s/%d: %s: got %x\nwant %x", name, n, enc.name, got, want)
+				}
+				decoded := make([]uint32, n)
+				if got, want := enc.dec(want, decoded), len(want); got != want {
+					t.Fatalf("%s/%d: decoding %s: read %d bytes, want %d", name, n, enc.name, got, want)
+				}
+				if !reflect.DeepEqual(decoded, enc.input) {
+					t.Fatalf("%s/%d: decoding %s: got %v\nwant %v", name, n, enc.name, decoded, enc.input)
+				}
+			}
+		}
+	}
+}
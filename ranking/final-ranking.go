@@ -0,0 +1,32 @@
+// vim:ts=4:sw=4:noexpandtab
+
+// The final ranking happens on dcs-web, once the source backends returned
+// their results, and combines the pre-ranking (pathrank) with the
+// post-ranking of each result.
+package ranking
+
+// MaxResultsFirstPathRank is the firstPathRank (see FinalRank) of queries with
+// max_results. The source backends select the best results of such queries by
+// their final ranking, which therefore cannot depend on the first result which
+// dcs-web happens to receive. 1 is the pre-ranking of a file without any
+// ranking signals (see ResultPath.Rank).
+const MaxResultsFirstPathRank = 1
+
+// FinalRank returns the ranking by which dcs-web orders the results of a query.
+// Both rankings are percentages, so they are added, but the post-ranking is
+// made less significant by multiplying it with 1/10 of firstPathRank, the
+// pathrank of the first result of the query. We used to use the maximum
+// pathrank instead, but that requires delaying the search until all results
+// are there. firstPathRank is a good enough approximation (but different
+// enough for each query that it cannot be hardcoded).
+func FinalRank(pathrank, postrank, firstPathRank float32) float32 {
+	return pathrank + ((firstPathRank * 0.1) * postrank)
+}
+
+// MaxPostRank returns the maximum post-ranking which PostRank returns with opts.
+func MaxPostRank(opts RankingOpts) float32 {
+	if opts.Definition || opts.Weighted {
+		return 2 // see the definition boost in PostRank
+	}
+	return 1
+}
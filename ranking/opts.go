@@ -41,6 +41,9 @@ type RankingOpts struct {
 	// match the line?
 	Linematch bool
 
+	// post-ranking: does the line define the searched symbol?
+	Definition bool
+
 	// meta: turns on all rankings and uses 'optimal' weights (as determined in
 	// the thesis).
 	Weighted bool
@@ -134,6 +137,7 @@ func RankingOptsFromQuery(query url.Values) RankingOpts {
 	result.Sourcepkgmatch = boolFromQuery(query, "sourcepkgmatch")
 	result.Scope = boolFromQuery(query, "scope")
 	result.Linematch = boolFromQuery(query, "linematch")
+	result.Definition = boolFromQuery(query, "definition")
 	// Special case: weighted is the default, so assume true if unset.
 	if _, ok := query["weighted"]; !ok {
 		result.Weighted = true
@@ -51,5 +51,15 @@ func PostRank(opts RankingOpts, match *regexp.Match, querystr *QueryStr) float32
 		}
 	}
 
+	if (opts.Definition || opts.Weighted) && match.Definition {
+		// Ranking: Definitions are more important than usages. As the other
+		// post-rankings cannot exceed 1 (and are positive for lines indented
+		// by fewer than 100 spaces), adding 1 ranks a definition above all
+		// usages within the same file. A multiplicative boost would not: an
+		// indented definition without a word boundary match would still rank
+		// below an unindented usage.
+		totalRanking += 1
+	}
+
 	return totalRanking
 }
@@ -0,0 +1,33 @@
+package ranking
+
+import (
+	"testing"
+
+	"github.com/Debian/dcs/regexp"
+)
+
+func TestPostRankDefinition(t *testing.T) {
+	opts := RankingOpts{Weighted: true}
+	querystr := NewQueryStr("ReadFile")
+
+	// The best possible usage: unindented, matching at the start of the line.
+	usage := &regexp.Match{Context: "ReadFile(path)"}
+	// The worst reasonable definition: indented and without a word boundary
+	// match.
+	definition := &regexp.Match{
+		Context:    "\t\t\t\tfunc osReadFile(path string) ([]byte, error) {",
+		Definition: true,
+	}
+
+	usageRanking := PostRank(opts, usage, &querystr)
+	definitionRanking := PostRank(opts, definition, &querystr)
+	if definitionRanking <= usageRanking {
+		t.Errorf("PostRank(definition) = %v, want > PostRank(usage) = %v", definitionRanking, usageRanking)
+	}
+
+	// Without the definition ranking option, definitions are not boosted.
+	opts = RankingOpts{Scope: true, Linematch: true}
+	if got, want := PostRank(opts, definition, &querystr), PostRank(opts, usage, &querystr); got >= want {
+		t.Errorf("PostRank(definition) = %v without boost, want < PostRank(usage) = %v", got, want)
+	}
+}
@@ -46,6 +46,7 @@ func ReadRankingData(path string) error {
 // and sorting each path.
 type ResultPath struct {
 	Path         string
+	Docid        uint32 // within the index which returned Path
 	Position     int
 	SourcePkgIdx [2]int
 	Ranking      float32
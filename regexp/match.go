@@ -9,22 +9,41 @@ import (
 	"encoding/binary"
 	"flag"
 	"fmt"
-	"html"
 	"io"
 	"os"
 	"regexp/syntax"
+	"slices"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 
 	"github.com/google/codesearch/sparse"
 )
 
+// MaxStates is the maximum number of DFA states which a Regexp caches.
+// Regular expressions like (a|b)*a(a|b){20} have exponentially many DFA
+// states, so once the cache is full, it is flushed and states are computed
+// anew as needed. Changing MaxStates affects Regexps compiled afterwards.
+var MaxStates = 10000
+
 // A matcher holds the state for running regular expression search.
+//
+// A matcher is safe for concurrent use: the DFA states are computed lazily
+// (while holding mu) and shared by all goroutines, which follow the
+// transitions between already computed states without locking. Goroutines
+// which are in the middle of a match when the cache is flushed keep using the
+// flushed states until the match is done.
 type matcher struct {
-	prog      *syntax.Prog       // compiled program
-	dstate    map[string]*dstate // dstate cache
-	start     *dstate            // start state
-	startLine *dstate            // start state for beginning of line
-	z1, z2    nstate             // two temporary nstates
+	prog      *syntax.Prog           // compiled program
+	maxStates int                    // see MaxStates
+	start     atomic.Pointer[dstate] // start state
+	startLine atomic.Pointer[dstate] // start state for beginning of line
+
+	mu      sync.Mutex
+	dstate  map[string]*dstate // dstate cache, guarded by mu
+	z1, z2  nstate             // two temporary nstates, guarded by mu
+	flushes int                // number of cache flushes, guarded by mu
 }
 
 // An nstate corresponds to an NFA state.
@@ -45,12 +64,13 @@ const (
 	flagWord                   // last byte was word byte
 )
 
-// A dstate corresponds to a DFA state.
+// A dstate corresponds to a DFA state. Only next is modified once the dstate
+// was added to the dstate cache.
 type dstate struct {
-	next     [256]*dstate // next state, per byte
-	enc      string       // encoded nstate
-	matchNL  bool         // match when next byte is \n
-	matchEOT bool         // match in this state at end of text
+	next     [256]atomic.Pointer[dstate] // next state, per byte
+	enc      string                      // encoded nstate
+	matchNL  bool                        // match when next byte is \n
+	matchEOT bool                        // match in this state at end of text
 }
 
 func (z *nstate) String() string {
@@ -120,7 +140,7 @@ func init() {
 	dmatch.enc = z.enc()
 	for i := range dmatch.next {
 		if i != '\n' {
-			dmatch.next[i] = &dmatch
+			dmatch.next[i].Store(&dmatch)
 		}
 	}
 }
@@ -128,21 +148,29 @@ func init() {
 // init initializes the matcher.
 func (m *matcher) init(prog *syntax.Prog) error {
 	m.prog = prog
-	m.dstate = make(map[string]*dstate)
+	m.maxStates = MaxStates
 
 	m.z1.q.Init(uint32(len(prog.Inst)))
 	m.z2.q.Init(uint32(len(prog.Inst)))
 
-	m.addq(&m.z1.q, uint32(prog.Start), syntax.EmptyBeginLine|syntax.EmptyBeginText)
+	m.reset()
+	return nil
+}
+
+// reset empties the dstate cache and computes new start states. The caller
+// must hold mu (or have exclusive access to m).
+func (m *matcher) reset() {
+	m.dstate = make(map[string]*dstate)
+
+	m.z1.q.Reset()
+	m.addq(&m.z1.q, uint32(m.prog.Start), syntax.EmptyBeginLine|syntax.EmptyBeginText)
 	m.z1.flag = flagBOL | flagBOT
-	m.start = m.cache(&m.z1)
+	m.start.Store(m.cache(&m.z1))
 
 	m.z1.q.Reset()
-	m.addq(&m.z1.q, uint32(prog.Start), syntax.EmptyBeginLine)
+	m.addq(&m.z1.q, uint32(m.prog.Start), syntax.EmptyBeginLine)
 	m.z1.flag = flagBOL
-	m.startLine = m.cache(&m.z1)
-
-	return nil
+	m.startLine.Store(m.cache(&m.z1))
 }
 
 // stepEmpty steps runq to nextq expanding according to flag.
@@ -207,6 +235,26 @@ func (m *matcher) addq(q *sparse.Set, id uint32, flag syntax.EmptyOp) {
 
 const endText = -1
 
+// step returns the next DFA state if we're in d reading the byte c, computing
+// it if necessary.
+func (m *matcher) step(d *dstate, c byte) *dstate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Another goroutine might have computed the state in the meantime.
+	if d1 := d.next[c].Load(); d1 != nil {
+		return d1
+	}
+	if m.maxStates > 0 && len(m.dstate) >= m.maxStates {
+		// d remains valid: it is no longer in the cache, but its encoding
+		// is all computeNext needs.
+		m.flushes++
+		m.reset()
+	}
+	d1 := m.computeNext(d, int(c))
+	d.next[c].Store(d1)
+	return d1
+}
+
 // computeNext computes the next DFA state if we're in d reading c (an input byte or endText).
 func (m *matcher) computeNext(d *dstate, c int) *dstate {
 	this, next := &m.z1, &m.z2
@@ -281,24 +329,25 @@ func (m *matcher) cache(z *nstate) *dstate {
 func (m *matcher) match(b []byte, beginText, endText bool) (end int) {
 	//	fmt.Printf("%v\n", m.prog)
 
-	d := m.startLine
+	d := m.startLine.Load()
 	if beginText {
-		d = m.start
+		d = m.start.Load()
 	}
 	//	m.z1.dec(d.enc)
 	//	fmt.Printf("%v (%v)\n", &m.z1, d==&dmatch)
 	for i, c := range b {
-		d1 := d.next[c]
+		d1 := d.next[c].Load()
 		if d1 == nil {
 			if c == '\n' {
 				if d.matchNL {
 					return i
 				}
-				d1 = m.startLine
+				// Not cached in d.next, so that matches do not
+				// keep using flushed states across lines.
+				d1 = m.startLine.Load()
 			} else {
-				d1 = m.computeNext(d, int(c))
+				d1 = m.step(d, c)
 			}
-			d.next[c] = d1
 		}
 		d = d1
 		//		m.z1.dec(d.enc)
@@ -311,23 +360,24 @@ func (m *matcher) match(b []byte, beginText, endText bool) (end int) {
 }
 
 func (m *matcher) matchString(b string, beginText, endText bool) (end int) {
-	d := m.startLine
+	d := m.startLine.Load()
 	if beginText {
-		d = m.start
+		d = m.start.Load()
 	}
 	for i := 0; i < len(b); i++ {
 		c := b[i]
-		d1 := d.next[c]
+		d1 := d.next[c].Load()
 		if d1 == nil {
 			if c == '\n' {
 				if d.matchNL {
 					return i
 				}
-				d1 = m.startLine
+				// Not cached in d.next, so that matches do not
+				// keep using flushed states across lines.
+				d1 = m.startLine.Load()
 			} else {
-				d1 = m.computeNext(d, int(c))
+				d1 = m.step(d, c)
 			}
-			d.next[c] = d1
 		}
 		d = d1
 	}
@@ -351,7 +401,7 @@ func isWordByte(c int) bool {
 
 // TODO:
 type Grep struct {
-	Regexp *Regexp   // regexp to search for
+	Regexp *Regexp   // regexp to search for, can be shared between Greps
 	Stdout io.Writer // output target
 	Stderr io.Writer // error target
 
@@ -362,6 +412,27 @@ type Grep struct {
 
 	Match bool
 
+	// ContextBefore and ContextAfter are the number of lines before and after
+	// each matching line which Reader returns as context.
+	ContextBefore int
+	ContextAfter  int
+
+	// And are further regexps which must all match within the same file for
+	// Reader to return any matches, in which case the lines matching any of
+	// the regexps are returned. If Near is positive, only the lines which
+	// have a match of each other regexp within Near lines are returned.
+	And  []*Regexp
+	Near int
+
+	// Multiline lets matches of Regexp (not of And) span lines, e.g. \s
+	// matches line breaks, at the cost of reading files completely. Matches are
+	// returned in their first line, with a range up to the end of that line.
+	// Note that ^ and $ match at the beginning and end of the file only.
+	Multiline bool
+
+	// Open opens the files for File. If nil, os.Open is used.
+	Open func(name string) (io.ReadCloser, error)
+
 	buf []byte
 }
 
@@ -373,7 +444,11 @@ func (g *Grep) AddFlags() {
 }
 
 func (g *Grep) File(name string) []Match {
-	f, err := os.Open(name)
+	open := g.Open
+	if open == nil {
+		open = func(name string) (io.ReadCloser, error) { return os.Open(name) }
+	}
+	f, err := open(name)
 	if err != nil {
 		fmt.Fprintf(g.Stderr, "%s\n", err)
 		return []Match{}
@@ -401,38 +476,74 @@ type Match struct {
 	Path string
 	Line int
 
-	// contents of line (Line - 2)
-	Ctxp2 string
-	// contents of line (Line - 1)
-	Ctxp1 string
-	// XXX: The following will most likely change after we figure out how
-	// to highlight the found text properly :).
+	// contents of the lines before Line (up to Grep.ContextBefore lines)
+	ContextBefore []string `json:"context_before"`
+	// contents of line (Line)
 	Context string
-	// contents of line (Line + 1)
-	Ctxn1 string
-	// contents of line (Line + 2)
-	Ctxn2 string
+	// positions of the matches within Context
+	Ranges []Range
+	// contents of the lines after Line (up to Grep.ContextAfter lines)
+	ContextAfter []string `json:"context_after"`
 
 	// This will be filled in by the source backend
 	PathRank float32
 	Ranking  float32
+
+	// Whether Line defines the searched symbol (filled in by the source
+	// backend, used for ranking).
+	Definition bool
+
+	// Paths of the files with identical contents, which hence contain the
+	// same match (filled in by the query manager).
+	Duplicates []string
+}
+
+// nextLine returns the first line of b (without its newline) and the remainder
+// of b.
+func nextLine(b []byte) (line string, rest []byte) {
+	idx := bytes.IndexByte(b, '\n')
+	if idx == -1 {
+		return string(b), nil
+	}
+	return string(b[:idx]), b[idx+1:]
+}
+
+// linesBefore returns up to n lines before pos (which must be the start of a
+// line), starting with the line furthest away from pos, and whether there
+// were fewer than n lines.
+func linesBefore(b []byte, pos, n int) (lines []string, short bool) {
+	for len(lines) < n && pos > 0 {
+		prevStart := bytes.LastIndex(b[:pos-1], nl) + 1
+		lines = append(lines, string(b[prevStart:pos-1]))
+		pos = prevStart
+	}
+	slices.Reverse(lines)
+	return lines, len(lines) < n
 }
 
 func (g *Grep) Reader(r io.Reader, name string) []Match {
+	if len(g.And) > 0 {
+		return g.readerAnd(r, name)
+	}
+	if g.Multiline {
+		return g.readerMultiline(r, name)
+	}
 	var result []Match
 	if g.buf == nil {
 		// 1024KB
 		g.buf = make([]byte, 1<<20)
 	}
 	var (
-		buf         = g.buf[:0]
-		lineno      = 1
-		bufLineNo   = 0
-		beginText   = true
-		endText     = false
+		buf       = g.buf[:0]
+		lineno    = 1
+		beginText = true
+		endText   = false
+		// the last lines before buf (up to ContextBefore lines), in case the
+		// next match needs them
+		last []string
+		// the number of lines which the after context of the last match
+		// still needs
 		needContext = 0
-		lastp1      = ""
-		lastp2      = ""
 	)
 	for {
 		n, err := io.ReadFull(r, buf[len(buf):cap(buf)])
@@ -445,25 +556,15 @@ func (g *Grep) Reader(r io.Reader, name string) []Match {
 			endText = true
 		}
 		chunkStart := 0
-		bufLineNo = 0
-		//fmt.Printf("need to add %d context lines to the last match\n", needContext)
 		if needContext > 0 {
-			lineEnd := bytes.Index(buf[:end], nl)
-			if lineEnd != -1 {
-				result[len(result)-1].Ctxn1 = string(buf[:lineEnd])
-				//fmt.Printf("afterwards: ctxn1 = *%s*\n", result[len(result)-1].Ctxn1)
-				if needContext > 1 {
-					nextLineEnd := bytes.Index(buf[lineEnd+1:end], nl)
-					if nextLineEnd != -1 {
-						result[len(result)-1].Ctxn2 = string(buf[lineEnd+1 : lineEnd+1+nextLineEnd])
-						//fmt.Printf("afterwards: ctxn2 = *%s*\n", result[len(result)-1].Ctxn2)
-					}
-				}
+			m := &result[len(result)-1]
+			for rest := buf[:end]; needContext > 0 && len(rest) > 0; needContext-- {
+				var line string
+				line, rest = nextLine(rest)
+				m.ContextAfter = append(m.ContextAfter, line)
 			}
-			needContext = 0
 		}
 
-		//fmt.Printf("looking at line *%s*\n", buf[0:end])
 		for chunkStart < end {
 			m1 := g.Regexp.Match(buf[chunkStart:end], beginText, endText) + chunkStart
 			beginText = false
@@ -471,57 +572,34 @@ func (g *Grep) Reader(r io.Reader, name string) []Match {
 				break
 			}
 			g.Match = true
+			// The matching line is buf[lineStart:m1], the next line starts at
+			// lineEnd.
 			lineStart := bytes.LastIndex(buf[chunkStart:m1], nl) + 1 + chunkStart
 			lineEnd := m1 + 1
-			if lineEnd > end {
-				lineEnd = end
-			}
-			//fmt.Printf("matching line: %s", buf[lineStart:lineEnd])
 
 			lineno += countNL(buf[chunkStart:lineStart])
-			line := html.EscapeString(string(buf[lineStart : lineEnd-1]))
+			line := string(buf[lineStart:m1])
 			match := Match{
 				Path:    name,
 				Line:    lineno,
-				Context: string(line),
+				Context: line,
+				Ranges:  g.Regexp.Ranges(line),
 			}
-			// Let’s find the previous two lines, if possible.
-			bufLineNo = countNL(buf[:lineStart])
-			if bufLineNo >= 1 {
-				prev1Start := bytes.LastIndex(buf[:lineStart-1], nl) + 1
-				match.Ctxp1 = html.EscapeString(string(buf[prev1Start : lineStart-1]))
-				if bufLineNo >= 2 {
-					prev2Start := bytes.LastIndex(buf[:prev1Start-1], nl) + 1
-					match.Ctxp2 = html.EscapeString(string(buf[prev2Start : prev1Start-1]))
-				} else {
-					match.Ctxp2 = lastp1
-				}
-			} else {
-				match.Ctxp1 = lastp1
-				match.Ctxp2 = lastp2
+			before, short := linesBefore(buf, lineStart, g.ContextBefore)
+			if short {
+				// The remaining lines are before buf.
+				missing := min(g.ContextBefore-len(before), len(last))
+				before = append(slices.Clip(last[len(last)-missing:]), before...)
 			}
-			needContext = 0
+			match.ContextBefore = before
+			needContext = g.ContextAfter
 			if lineEnd < end {
-				//fmt.Printf("lineEnd = %d, end = %d\n", lineEnd, end)
-				next1Start := bytes.Index(buf[lineEnd:end], nl)
-				//fmt.Printf("next1Start = %d\n", next1Start)
-				if next1Start != -1 {
-					next1Start = next1Start + lineEnd + 1
-					match.Ctxn1 = html.EscapeString(string(buf[lineEnd : next1Start-1]))
-					if next1Start < end {
-						next2Start := bytes.Index(buf[next1Start:end], nl)
-						if next2Start != -1 {
-							match.Ctxn2 = html.EscapeString(string(buf[next1Start : next1Start+next2Start]))
-						}
-					} else {
-						needContext = 1
-					}
+				for rest := buf[lineEnd:end]; needContext > 0 && len(rest) > 0; needContext-- {
+					var line string
+					line, rest = nextLine(rest)
+					match.ContextAfter = append(match.ContextAfter, line)
 				}
-			} else {
-				needContext = 2
 			}
-			//fmt.Printf("ctxn1 = *%s*\n", match.Ctxn1)
-			//fmt.Printf("ctxn2 = *%s*\n", match.Ctxn2)
 			result = append(result, match)
 			lineno++
 			chunkStart = lineEnd
@@ -530,21 +608,15 @@ func (g *Grep) Reader(r io.Reader, name string) []Match {
 			lineno += countNL(buf[chunkStart:end])
 		}
 
-		// We are about to read again, so let’s store the last two lines in
-		// case the next match needs them.
-		if bufLineNo == 0 {
-			// This could be because there was no match and we didn’t count, so
-			// make sure we count.
-			bufLineNo = countNL(buf[:end])
-			//fmt.Printf("lineno = %d\n", bufLineNo)
-		}
-		if bufLineNo > 1 {
-			prev1Start := bytes.LastIndex(buf[:end-1], nl) + 1
-			lastp1 = html.EscapeString(string(buf[prev1Start : end-1]))
-			if bufLineNo > 2 {
-				prev2Start := bytes.LastIndex(buf[:prev1Start-1], nl) + 1
-				lastp2 = html.EscapeString(string(buf[prev2Start : prev1Start-1]))
+		// We are about to read again, so let’s store the last lines in case
+		// the next match needs them.
+		if err == nil && g.ContextBefore > 0 {
+			lines, short := linesBefore(buf, end, g.ContextBefore)
+			if short {
+				lines = append(last, lines...)
+				lines = lines[max(0, len(lines)-g.ContextBefore):]
 			}
+			last = lines
 		}
 
 		// Copy the remaining elements to the front (everything after the next newline)
@@ -559,3 +631,125 @@ func (g *Grep) Reader(r io.Reader, name string) []Match {
 	}
 	return result
 }
+
+// readerAnd implements Reader for Greps with And regexps: the contents are
+// searched for each regexp in turn, and the matches are merged.
+func (g *Grep) readerAnd(r io.Reader, name string) []Match {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(g.Stderr, "%s: %v\n", name, err)
+		return nil
+	}
+	parts := make([][]Match, 0, 1+len(g.And))
+	for idx, re := range append([]*Regexp{g.Regexp}, g.And...) {
+		part := *g
+		part.Regexp = re
+		part.And = nil
+		part.Multiline = g.Multiline && idx == 0
+		matches := part.Reader(bytes.NewReader(b), name)
+		g.buf = part.buf
+		if len(matches) == 0 {
+			return nil
+		}
+		parts = append(parts, matches)
+	}
+	result := mergeParts(parts, g.Near)
+	g.Match = len(result) > 0
+	return result
+}
+
+// mergeParts merges the matches of several regexps (each sorted by line) into
+// one match per line, keeping only the lines which have a match of each other
+// regexp within near lines if near is positive.
+func mergeParts(parts [][]Match, near int) []Match {
+	var result []Match
+	for idx, matches := range parts {
+		for _, m := range matches {
+			if near > 0 && !nearAll(parts, idx, m.Line, near) {
+				continue
+			}
+			result = append(result, m)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Line < result[j].Line })
+	merged := result[:0]
+	for _, m := range result {
+		if len(merged) > 0 && merged[len(merged)-1].Line == m.Line {
+			prev := &merged[len(merged)-1]
+			prev.Ranges = append(prev.Ranges, m.Ranges...)
+			sort.SliceStable(prev.Ranges, func(i, j int) bool { return prev.Ranges[i].Start < prev.Ranges[j].Start })
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// nearAll reports whether each of parts (other than parts[skip]) contains a
+// match within near lines of line.
+func nearAll(parts [][]Match, skip, line, near int) bool {
+	for idx, matches := range parts {
+		if idx == skip {
+			continue
+		}
+		j := sort.Search(len(matches), func(j int) bool { return matches[j].Line >= line-near })
+		if j == len(matches) || matches[j].Line > line+near {
+			return false
+		}
+	}
+	return true
+}
+
+// readerMultiline implements Reader for Multiline Greps.
+func (g *Grep) readerMultiline(r io.Reader, name string) []Match {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(g.Stderr, "%s: %v\n", name, err)
+		return nil
+	}
+	var (
+		result []Match
+		lineno = 1
+		pos    = 0 // lineno is the line of b[pos]
+	)
+	for _, loc := range g.Regexp.lineRegexp().FindAllIndex(b, -1) {
+		start, end := loc[0], loc[1]
+		lineno += countNL(b[pos:start])
+		pos = start
+		lineStart := bytes.LastIndexByte(b[:start], '\n') + 1
+		lineEnd := len(b)
+		if idx := bytes.IndexByte(b[start:], '\n'); idx > -1 {
+			lineEnd = start + idx
+		}
+		line := b[lineStart:lineEnd]
+		startRune := utf8.RuneCount(line[:start-lineStart])
+		rng := Range{
+			Start:     start - lineStart,
+			End:       min(end, lineEnd) - lineStart,
+			StartRune: startRune,
+			EndRune:   startRune + utf8.RuneCount(b[start:min(end, lineEnd)]),
+		}
+		if len(result) > 0 && result[len(result)-1].Line == lineno {
+			m := &result[len(result)-1]
+			m.Ranges = append(m.Ranges, rng)
+			continue
+		}
+		match := Match{
+			Path:    name,
+			Line:    lineno,
+			Context: string(line),
+			Ranges:  []Range{rng},
+		}
+		match.ContextBefore, _ = linesBefore(b, lineStart, g.ContextBefore)
+		if lineEnd < len(b) {
+			for rest := b[lineEnd+1:]; len(match.ContextAfter) < g.ContextAfter && len(rest) > 0; {
+				var line string
+				line, rest = nextLine(rest)
+				match.ContextAfter = append(match.ContextAfter, line)
+			}
+		}
+		result = append(result, match)
+	}
+	g.Match = len(result) > 0
+	return result
+}
@@ -1,10 +1,23 @@
 package regexp
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
 
+// nth returns lines[i] (or, for negative i, lines[len(lines)+i]), or "" if i is
+// out of range.
+func nth(lines []string, i int) string {
+	if i < 0 {
+		i += len(lines)
+	}
+	if i < 0 || i >= len(lines) {
+		return ""
+	}
+	return lines[i]
+}
+
 func TestMatchContextAfter(t *testing.T) {
 	bufferSize := 1 << 20
 	// The context data which is placed "after the fold", that is after one
@@ -36,17 +49,17 @@ func TestMatchContextAfter(t *testing.T) {
 		t.Fatalf("Compile(%#q): %v", "fnord", err)
 	}
 
-	g := Grep{}
+	g := Grep{ContextBefore: 2, ContextAfter: 2}
 	g.Regexp = re
 	matches := g.Reader(strings.NewReader(string(buffer)), "input")
 	if len(matches) != 1 {
 		t.Fatalf("Expected precisely one match, got %d", len(matches))
 	}
-	if matches[0].Ctxn1 != "ctx1" {
-		t.Errorf("Context +1 wrong: %s", matches[0].Ctxn1)
+	if nth(matches[0].ContextAfter, 0) != "ctx1" {
+		t.Errorf("Context +1 wrong: %s", nth(matches[0].ContextAfter, 0))
 	}
-	if matches[0].Ctxn2 != "ba" {
-		t.Errorf("Context +2 wrong: %s", matches[0].Ctxn2)
+	if nth(matches[0].ContextAfter, 1) != "ba" {
+		t.Errorf("Context +2 wrong: %s", nth(matches[0].ContextAfter, 1))
 	}
 
 	re, err = Compile("ba")
@@ -58,11 +71,11 @@ func TestMatchContextAfter(t *testing.T) {
 	if len(matches) != 1 {
 		t.Fatalf("Expected precisely one match, got %d", len(matches))
 	}
-	if matches[0].Ctxp1 != "ctx1" {
-		t.Errorf("Context -1 wrong: %s", matches[0].Ctxp1)
+	if nth(matches[0].ContextBefore, -1) != "ctx1" {
+		t.Errorf("Context -1 wrong: %s", nth(matches[0].ContextBefore, -1))
 	}
-	if matches[0].Ctxp2 != "fnord" {
-		t.Errorf("Context -2 wrong: %s", matches[0].Ctxp2)
+	if nth(matches[0].ContextBefore, -2) != "fnord" {
+		t.Errorf("Context -2 wrong: %s", nth(matches[0].ContextBefore, -2))
 	}
 }
 
@@ -97,16 +110,178 @@ func TestMatchContextBefore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Compile(%#q): %v", "ba", err)
 	}
-	g := Grep{}
+	g := Grep{ContextBefore: 2, ContextAfter: 2}
 	g.Regexp = re
 	matches := g.Reader(strings.NewReader(string(buffer)), "input")
 	if len(matches) != 1 {
 		t.Fatalf("Expected precisely one match, got %d", len(matches))
 	}
-	if matches[0].Ctxp1 != "ctx1" {
-		t.Errorf("Context -1 wrong: %s", matches[0].Ctxp1)
+	if nth(matches[0].ContextBefore, -1) != "ctx1" {
+		t.Errorf("Context -1 wrong: %s", nth(matches[0].ContextBefore, -1))
+	}
+	if nth(matches[0].ContextBefore, -2) != "fnord" {
+		t.Errorf("Context -2 wrong: %s", nth(matches[0].ContextBefore, -2))
+	}
+}
+
+func TestMatchRanges(t *testing.T) {
+	re, err := Compile("<[a-z]+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := Grep{Regexp: re}
+	matches := g.Reader(strings.NewReader("nothing\n// ä <b>fett</b> & <i>\n"), "input")
+	if len(matches) != 1 {
+		t.Fatalf("Expected precisely one match, got %d", len(matches))
+	}
+	m := matches[0]
+	// The context must not be HTML-escaped, so that the ranges refer to it.
+	if want := "// ä <b>fett</b> & <i>"; m.Context != want {
+		t.Errorf("Context = %q, want %q", m.Context, want)
+	}
+	want := []Range{
+		{Start: 6, End: 8, StartRune: 5, EndRune: 7},
+		{Start: 20, End: 22, StartRune: 19, EndRune: 21},
+	}
+	if !reflect.DeepEqual(m.Ranges, want) {
+		t.Errorf("Ranges = %+v, want %+v", m.Ranges, want)
+	}
+	for _, r := range m.Ranges {
+		if got := m.Context[r.Start:r.End]; got != "<b" && got != "<i" {
+			t.Errorf("Context[%d:%d] = %q, want a match", r.Start, r.End, got)
+		}
+	}
+}
+
+func TestMatchContextLines(t *testing.T) {
+	re, err := Compile("match")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const input = "one\ntwo\n\nthree\nmatch 1\nfour\nmatch 2\nfive"
+	for _, tt := range []struct {
+		before, after int
+		want          [][2][]string // before and after context per match
+	}{
+		{
+			before: 0,
+			after:  0,
+			want: [][2][]string{
+				{nil, nil},
+				{nil, nil},
+			},
+		},
+		{
+			before: 1,
+			after:  3,
+			want: [][2][]string{
+				{{"three"}, {"four", "match 2", "five"}},
+				{{"four"}, {"five"}},
+			},
+		},
+		{
+			before: 10,
+			after:  1,
+			want: [][2][]string{
+				{{"one", "two", "", "three"}, {"four"}},
+				{{"one", "two", "", "three", "match 1", "four"}, {"five"}},
+			},
+		},
+	} {
+		g := Grep{Regexp: re, ContextBefore: tt.before, ContextAfter: tt.after}
+		matches := g.Reader(strings.NewReader(input), "input")
+		if len(matches) != len(tt.want) {
+			t.Fatalf("got %d matches, want %d", len(matches), len(tt.want))
+		}
+		for idx, m := range matches {
+			got := [2][]string{m.ContextBefore, m.ContextAfter}
+			if !reflect.DeepEqual(got, tt.want[idx]) {
+				t.Errorf("-B %d -A %d: match %d: got context %q, want %q", tt.before, tt.after, idx, got, tt.want[idx])
+			}
+		}
+	}
+}
+
+func TestMatchAnd(t *testing.T) {
+	compile := func(expr string) *Regexp {
+		re, err := Compile(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return re
+	}
+	const input = "fd := open(path)\nread(fd)\nclose(fd)\n\n\n\nopen(other)\n"
+	for _, tt := range []struct {
+		and   []string
+		near  int
+		lines []int
+	}{
+		{and: []string{`close\(`}, lines: []int{1, 3, 7}},
+		{and: []string{`close\(`}, near: 2, lines: []int{1, 3}},
+		{and: []string{`close\(`, `read\(`}, near: 1, lines: []int{2}},
+		{and: []string{`write\(`}, lines: nil},
+	} {
+		g := Grep{Regexp: compile(`open\(`)}
+		for _, expr := range tt.and {
+			g.And = append(g.And, compile(expr))
+		}
+		g.Near = tt.near
+		var lines []int
+		for _, m := range g.Reader(strings.NewReader(input), "input") {
+			lines = append(lines, m.Line)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("and %q near %d: got lines %v, want %v", tt.and, tt.near, lines, tt.lines)
+		}
+	}
+
+	// A line matching several regexps is returned once, with all ranges:
+	g := Grep{Regexp: compile(`open`), And: []*Regexp{compile(`path`), compile(`fd`)}}
+	matches := g.Reader(strings.NewReader(input), "input")
+	if len(matches) != 4 {
+		t.Fatalf("got %d matches, want 4", len(matches))
+	}
+	var got []string
+	for _, r := range matches[0].Ranges {
+		got = append(got, matches[0].Context[r.Start:r.End])
+	}
+	if want := []string{"fd", "open", "path"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("line 1: got ranges %q, want %q", got, want)
+	}
+}
+
+func TestMatchMultiline(t *testing.T) {
+	re, err := Compile(`if\s+\(x\)\s+\{`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const input = "// if (x) {\nif (x)\n{\n\tbreak;\n}\nif  (x) { if (x)\t{\n"
+	g := Grep{Regexp: re, Multiline: true, ContextBefore: 1, ContextAfter: 1}
+	matches := g.Reader(strings.NewReader(input), "input")
+	if !g.Match {
+		t.Errorf("g.Match = false, want true")
+	}
+	var lines []int
+	var ranges [][]string
+	for _, m := range matches {
+		lines = append(lines, m.Line)
+		var got []string
+		for _, r := range m.Ranges {
+			got = append(got, m.Context[r.Start:r.End])
+		}
+		ranges = append(ranges, got)
+	}
+	if want := []int{1, 2, 6}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got lines %v, want %v", lines, want)
+	}
+	want := [][]string{{"if (x) {"}, {"if (x)"}, {"if  (x) {", "if (x)\t{"}}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("got ranges %q, want %q", ranges, want)
+	}
+	if got, want := matches[1].ContextBefore, []string{"// if (x) {"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("line 2: got context before %q, want %q", got, want)
 	}
-	if matches[0].Ctxp2 != "fnord" {
-		t.Errorf("Context -2 wrong: %s", matches[0].Ctxp2)
+	if got, want := matches[1].ContextAfter, []string{"{"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("line 2: got context after %q, want %q", got, want)
 	}
 }
@@ -6,18 +6,38 @@
 // use in grep-like programs.
 package regexp
 
-import "regexp/syntax"
+import (
+	stdregexp "regexp"
+	"regexp/syntax"
+	"sync"
+	"unicode/utf8"
+)
 
 func bug() {
 	panic("codesearch/regexp: internal error")
 }
 
 // Regexp is the representation of a compiled regular expression.
-// A Regexp is NOT SAFE for concurrent use by multiple goroutines.
+// A Regexp is safe for concurrent use by multiple goroutines.
 type Regexp struct {
 	Syntax *syntax.Regexp
 	expr   string // original expression
 	m      matcher
+
+	// line locates the matches within a line, see Ranges. It is only
+	// compiled when needed (see lineRegexp), as most regexps (e.g. of the
+	// package: keyword) are only used for matching.
+	lineOnce sync.Once
+	line     *stdregexp.Regexp
+}
+
+// lineRegexp returns the standard library version of the regexp.
+func (r *Regexp) lineRegexp() *stdregexp.Regexp {
+	r.lineOnce.Do(func() {
+		// Compile already parsed expr with the same (Perl) syntax.
+		r.line = stdregexp.MustCompile(r.expr)
+	})
+	return r.line
 }
 
 // String returns the source text used to compile the regular expression.
@@ -57,3 +77,35 @@ func (r *Regexp) Match(b []byte, beginText, endText bool) (end int) {
 func (r *Regexp) MatchString(s string, beginText, endText bool) (end int) {
 	return r.m.matchString(s, beginText, endText)
 }
+
+// A Range is the position of a match within a line.
+type Range struct {
+	Start int `json:"start"` // byte offset of the first byte
+	End   int `json:"end"`   // byte offset after the last byte
+
+	StartRune int `json:"start_rune"` // rune offset of the first rune
+	EndRune   int `json:"end_rune"`   // rune offset after the last rune
+}
+
+// Ranges returns the positions of all (non-overlapping) matches within line,
+// which must not contain a newline.
+func (r *Regexp) Ranges(line string) []Range {
+	locs := r.lineRegexp().FindAllStringIndex(line, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+	ranges := make([]Range, 0, len(locs))
+	var last, lastRune int
+	for _, loc := range locs {
+		startRune := lastRune + utf8.RuneCountInString(line[last:loc[0]])
+		endRune := startRune + utf8.RuneCountInString(line[loc[0]:loc[1]])
+		ranges = append(ranges, Range{
+			Start:     loc[0],
+			End:       loc[1],
+			StartRune: startRune,
+			EndRune:   endRune,
+		})
+		last, lastRune = loc[1], endRune
+	}
+	return ranges
+}
@@ -7,8 +7,11 @@ package regexp
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"reflect"
+	stdregexp "regexp"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -163,6 +166,75 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestMatchConcurrent(t *testing.T) {
+	// All goroutines share one Regexp (and hence one lazily built DFA), like
+	// the workers of the source backend do.
+	for _, tt := range matchTests {
+		re, err := Compile("(?m)" + tt.re)
+		if err != nil {
+			t.Errorf("Compile(%#q): %v", tt.re, err)
+			continue
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if lines := grep(re, []byte(tt.s)); !reflect.DeepEqual(lines, tt.m) {
+					t.Errorf("grep(%#q, %q) = %v, want %v", tt.re, tt.s, lines, tt.m)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestMaxStates(t *testing.T) {
+	defer func(old int) { MaxStates = old }(MaxStates)
+	MaxStates = 100
+
+	// The DFA for this regexp has more than 2^20 states.
+	const expr = `(a|b)*a(a|b){20}`
+	re, err := Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := stdregexp.MustCompile(expr)
+	rnd := rand.New(rand.NewSource(1))
+	var matches int
+	for i := 0; i < 100; i++ {
+		line := make([]byte, 200)
+		for j := range line {
+			line[j] = "ab"[rnd.Intn(2)]
+		}
+		// Lines whose “a”s are all within the last 20 bytes do not match.
+		if i%2 == 0 {
+			copy(line, strings.Repeat("b", len(line)-20))
+		}
+		got := re.Match(line, true, true) != -1
+		if got {
+			matches++
+		}
+		if want := std.Match(line); got != want {
+			t.Fatalf("Match(%q) = %v, want %v", line, got, want)
+		}
+	}
+
+	if matches != 50 {
+		t.Errorf("%d of 100 lines matched, want 50", matches)
+	}
+
+	re.m.mu.Lock()
+	defer re.m.mu.Unlock()
+	if re.m.flushes == 0 {
+		t.Errorf("DFA cache was not flushed")
+	}
+	// A single step can add a few states beyond MaxStates.
+	if got, limit := len(re.m.dstate), 2*MaxStates; got > limit {
+		t.Errorf("DFA cache contains %d states, want at most %d", got, limit)
+	}
+}
+
 func grep(re *Regexp, b []byte) []int {
 	var m []int
 	lineno := 1
@@ -125,12 +125,15 @@ function track(ev) {
 function addSearchResult(results, result) {
     var context = [];
 
-    // NB: All of the following context lines are already HTML-escaped by the server.
-    context.push(result.ctxp2);
-    context.push(result.ctxp1);
-    context.push('<strong>' + result.context + '</strong>');
-    context.push(result.ctxn1);
-    context.push(result.ctxn2);
+    // NB: The server sends the context lines as they appear in the file, so
+    // they need to be HTML-escaped.
+    $.each(result.context_before || [], function(idx, line) {
+        context.push(escapeForHTML(line));
+    });
+    context.push('<strong>' + highlightMatches(result.context, result.ranges) + '</strong>');
+    $.each(result.context_after || [], function(idx, line) {
+        context.push(escapeForHTML(line));
+    });
     // Remove any empty context lines (e.g. when the match is close to the
     // beginning or end of the file).
     context = $.grep(context, function(elm, idx) { return $.trim(elm) != ""; });
@@ -142,8 +145,28 @@ function addSearchResult(results, result) {
     var sourcePackage = result.path.substring(0, delimiter);
     var rest = result.path.substring(delimiter);
 
+    // Results in files with identical contents (e.g. vendored copies of a
+    // library) are collapsed by the server and listed on request.
+    var duplicates = '';
+    if (result.duplicates && result.duplicates.length > 0) {
+        var otherPackages = {};
+        var numOtherPackages = 0;
+        var links = $.map(result.duplicates, function(path) {
+            var pkg = path.substring(0, path.indexOf("_"));
+            if (pkg != sourcePackage && !otherPackages[pkg]) {
+                otherPackages[pkg] = true;
+                numOtherPackages++;
+            }
+            return '<li><a href="/show?file=' + encodeURIComponent(path) + '&line=' + result.line + '"><code>' + escapeForHTML(path) + '</code></a></li>';
+        });
+        var summary = (numOtherPackages > 0 ?
+                       'also in ' + numOtherPackages + ' other packages' :
+                       'also in ' + result.duplicates.length + ' other files');
+        duplicates = '<details><summary>' + summary + '</summary><ul>' + links.join('') + '</ul></details>';
+    }
+
     // Append the new search result, then sort the results.
-    var el = $('<li data-ranking="' + result.ranking + '"><a onclick="track(event);" href="/show?file=' + encodeURIComponent(result.path) + '&line=' + result.line + '"><code><strong>' + sourcePackage + '</strong>' + escapeForHTML(rest) + '</code></a><br><pre>' + context + '</pre><small>PathRank: ' + result.pathrank + ', Final: ' + result.ranking + '</small></li>');
+    var el = $('<li data-ranking="' + result.ranking + '"><a onclick="track(event);" href="/show?file=' + encodeURIComponent(result.path) + '&line=' + result.line + '"><code><strong>' + sourcePackage + '</strong>' + escapeForHTML(rest) + '</code></a><br><pre>' + context + '</pre>' + duplicates + '<small>PathRank: ' + result.pathrank + ', Final: ' + result.ranking + '</small></li>');
     $(el).children('a').attr('data-path', result.path).attr('data-line', result.line);
     results.append(el);
     $('ul#results').append($('ul#results>li').detach().sort(function(a, b) {
@@ -311,6 +334,30 @@ function escapeForHTML(input) {
     return $('<div/>').text(input).html();
 }
 
+// Returns the HTML-escaped line with the matches at ranges (see the “ranges”
+// field of search results) highlighted.
+function highlightMatches(line, ranges) {
+    if (!ranges || ranges.length === 0) {
+        return escapeForHTML(line);
+    }
+    // The ranges contain rune (Unicode code point) offsets, whereas JavaScript
+    // strings are indexed by UTF-16 code units.
+    var runes = Array.from(line);
+    var html = '';
+    var last = 0;
+    $.each(ranges, function(idx, range) {
+        var start = range.start_rune || 0;
+        var end = range.end_rune || 0;
+        if (start < last || end > runes.length) {
+            return;
+        }
+        html += escapeForHTML(runes.slice(last, start).join(''));
+        html += '<mark>' + escapeForHTML(runes.slice(start, end).join('')) + '</mark>';
+        last = end;
+    });
+    return html + escapeForHTML(runes.slice(last).join(''));
+}
+
 function getDefault(searchparams, name, def) {
     var val = searchparams.get(name);
     return (val === null ? def : val);
@@ -440,6 +487,8 @@ function onEvent(e) {
             error(false, true, msg.ErrorType, "The results may be incomplete, not all Debian Code Search servers are okay right now.");
         } else if (msg.ErrorType == "cancelled") {
             error(false, true, msg.ErrorType, "This query has been cancelled by the server administrator (to preserve overall service health).");
+        } else if (msg.ErrorType == "querytimeout") {
+            error(false, true, msg.ErrorType, "The results are incomplete: this query took too long and was aborted. Try a more specific query.");
         } else if (msg.ErrorType == "failed") {
             error(false, true, msg.ErrorType, "This query failed due to an unexpected internal server error.");
         } else if (msg.ErrorType == "invalidquery") {